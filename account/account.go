@@ -0,0 +1,35 @@
+// Package account defines the routes where authenticated users manage their own account
+package account
+
+import (
+	"errors"
+	"net"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/emaildomain"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+)
+
+type Service struct {
+	env infra.ILair
+	// domains checks new emails against the registration rules
+	domains *emaildomain.Checker
+}
+
+func NewService(env infra.ILair) *Service {
+	return &Service{env: env, domains: emaildomain.New(net.DefaultResolver)}
+}
+
+// currentUser loads the authenticated user of the current request
+func (svc Service) currentUser(c *gin.Context) (models.User, error) {
+	id, exists := c.Get(middleware.UserIDKey)
+	if !exists {
+		return models.User{}, errors.New("no authenticated user")
+	}
+	user := models.User{}
+	user.ID = id.(uint)
+	res := svc.env.GetDb().Where(&user).First(&user)
+	return user, res.Error
+}
@@ -0,0 +1,113 @@
+package account
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/lockout"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+	"github.com/uberswe/golang-base-project/twofactor"
+)
+
+// ConfirmPageData holds the additional data needed to render the page where users confirm their password or a second
+// factor code
+type ConfirmPageData struct {
+	routes.PageData
+	Next      string
+	TwoFactor bool
+}
+
+// confirmNext returns the local path to continue to once confirmed so that the value can not be used as an open redirect
+func confirmNext(c *gin.Context) string {
+	next := c.Query("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/account"
+	}
+	return next
+}
+
+func (svc Service) renderConfirm(c *gin.Context, pd routes.PageData, user models.User, status int) {
+	pd.Title = pd.Trans("Confirm your identity")
+	c.HTML(status, "confirm.gohtml", ConfirmPageData{
+		PageData:  pd,
+		Next:      confirmNext(c),
+		TwoFactor: user.HasTwoFactor(),
+	})
+}
+
+// Confirm renders the page where users confirm their password or a second factor code before changing the security of
+// their account
+func (svc Service) Confirm(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("Confirm", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderConfirm(c, pd, user, http.StatusOK)
+}
+
+// ConfirmPost checks the password, or the second factor code of users with two-factor authentication, and allows
+// changes to the security of the account for the session.ReauthenticationWindow
+func (svc Service) ConfirmPost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("ConfirmPost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	confirmed := false
+	if password := c.PostForm("password"); password != "" {
+		confirmed, err = passhash.Verify(user.Password, password)
+		if err != nil {
+			slog.Error("ConfirmPost", "error", err)
+		}
+		if !confirmed {
+			pd.AddMessage(routes.Error, pd.Trans("The password you entered is not correct."))
+		}
+	} else if code := c.PostForm("code"); code != "" && user.HasTwoFactor() {
+		status, err := lockout.CheckSecondFactor(db, user.ID)
+		if err != nil {
+			slog.Error("ConfirmPost", "error", err)
+		}
+		if status.Locked {
+			pd.AddMessage(routes.Error, pd.Trans("Too many invalid codes were entered, please try again later."))
+		} else if confirmed = twofactor.Verify(db, &user, code); confirmed {
+			err = lockout.SucceedSecondFactor(db, user.ID)
+		} else {
+			audit.Record(c, db, audit.LoginFailed, user.ID, "second factor confirmation")
+			_, err = lockout.FailSecondFactor(db, svc.env.GetConfig(), user.ID, lockout.SecondFactorMaxAttempts, c.ClientIP())
+			pd.AddMessage(routes.Error, pd.Trans("The code you entered is not valid, please try again."))
+		}
+		if err != nil {
+			slog.Error("ConfirmPost", "error", err)
+		}
+	} else {
+		pd.AddMessage(routes.Error, pd.Trans("The password you entered is not correct."))
+	}
+	if !confirmed {
+		svc.renderConfirm(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	identifier, _ := middleware.DefaultSessionWithOptions(c).Get(middleware.SessionIDKey).(string)
+	err = session.Reauthenticate(db, identifier)
+	if err != nil {
+		slog.Error("ConfirmPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderConfirm(c, pd, user, http.StatusInternalServerError)
+		return
+	}
+	c.Redirect(http.StatusFound, confirmNext(c))
+}
@@ -0,0 +1,33 @@
+package account
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConfirmNext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/account/passkeys", "/account/passkeys"},
+		{"/login/oidc/google?link=1", "/login/oidc/google?link=1"},
+		{"", "/account"},
+		{"account", "/account"},
+		{"https://evil.example.com", "/account"},
+		{"//evil.example.com", "/account"},
+		{"/\\evil.example.com", "/account"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/account/confirm?next="+url.QueryEscape(tt.next), nil)
+		if got := confirmNext(c); got != tt.want {
+			t.Errorf("confirmNext(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/apitoken"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+	"gorm.io/gorm"
+)
+
+// DataPageData holds the additional data needed to render the page where users download their data and delete their account
+type DataPageData struct {
+	routes.PageData
+	GraceDays int
+}
+
+func (svc Service) renderData(c *gin.Context, pd routes.PageData, status int) {
+	pd.Title = pd.Trans("Your Data")
+	c.HTML(status, "data.gohtml", DataPageData{
+		PageData:  pd,
+		GraceDays: svc.env.GetConfig().AccountDeletionGraceDays,
+	})
+}
+
+// Data renders the page where the current user downloads their data or deletes their account
+func (svc Service) Data(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderData(c, pd, http.StatusOK)
+}
+
+// DeletePost soft deletes the account of the current user after the password has been confirmed and logs the user out.
+// An email with a link which restores the account is sent, the data of the account is purged once the grace period has
+// passed.
+func (svc Service) DeletePost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("DeletePost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+
+	match, err := passhash.Verify(user.Password, c.PostForm("password"))
+	if err != nil {
+		slog.Error("DeletePost", "error", err)
+	}
+	if !match {
+		pd.AddMessage(routes.Error, pd.Trans("The password you entered is not correct."))
+		svc.renderData(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	conf := svc.env.GetConfig()
+	db := svc.env.GetDb()
+	var restoreToken models.Token
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&user)
+		if res.Error != nil {
+			return res.Error
+		}
+		err := rbac.EnsureAdmin(tx)
+		if err != nil {
+			return err
+		}
+		value, err := secureToken()
+		if err != nil {
+			return err
+		}
+		restoreToken = models.Token{
+			Value:     value,
+			Type:      models.TokenAccountRestore,
+			ModelID:   int(user.ID),
+			ModelType: "User",
+			ExpiresAt: time.Now().Add(time.Duration(conf.AccountDeletionGraceDays) * 24 * time.Hour),
+		}
+		return tx.Create(&restoreToken).Error
+	})
+	if errors.Is(err, rbac.ErrLastAdmin) {
+		pd.AddMessage(routes.Error, pd.Trans("This would leave no user who can manage roles."))
+		svc.renderData(c, pd, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("DeletePost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderData(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.UserDeleted, user.ID, fmt.Sprintf("self grace_days=%d", conf.AccountDeletionGraceDays))
+
+	count, err := session.RevokeAll(db, user.ID, "")
+	if err != nil {
+		slog.Error("DeletePost", "error", err)
+	}
+	err = apitoken.RevokeAll(db, user.ID)
+	if err != nil {
+		slog.Error("DeletePost", "error", err)
+	}
+	slog.Info("DeletePost:Deleted", "user", user.ID, "sessions", count)
+
+	middleware.ClearRememberCookie(c, conf)
+	cookie := middleware.DefaultSessionWithOptions(c)
+	cookie.Delete(middleware.SessionIDKey)
+	err = cookie.Save()
+	if err != nil {
+		slog.Error("DeletePost", "error", err)
+	}
+
+	if conf.AccountDeletionGraceDays > 0 {
+		go svc.sendRestoreEmail(user.Email, restoreToken.Value, conf.AccountDeletionGraceDays, pd.Trans)
+	}
+
+	pd.IsAuthenticated = false
+	pd.Permissions = nil
+	pd.Title = pd.Trans("Delete my account")
+	pd.AddMessage(routes.Success, fmt.Sprintf(pd.Trans("Your account has been deleted. We have sent you an email with a link which restores your account within %d days."), conf.AccountDeletionGraceDays))
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{PageData: pd})
+}
+
+func (svc Service) sendRestoreEmail(email string, token string, days int, trans func(string) string) {
+	conf := svc.env.GetConfig()
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		slog.Error("sendRestoreEmail", "error", err)
+		return
+	}
+	u.Path = path.Join(u.Path, "/account/restore/", token)
+
+	emailService := email2.New(conf)
+
+	emailService.Send(email, trans("Your account has been deleted"), fmt.Sprintf(trans("Your account has been deleted and its data will be removed in %d days. If you change your mind, use the following link to restore your account before then.\n%s"), days, u.String()))
+}
+
+// Restore renders a button which restores a deleted account
+func (svc Service) Restore(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Restore account")
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{
+		PageData: pd,
+		Action:   path.Join("/account/restore/", c.Param("token")),
+		Text:     pd.Trans("Click the button below to restore your account."),
+		Button:   pd.Trans("Restore"),
+	})
+}
+
+// RestorePost uses the restore token and restores the account if it is still within the grace period
+func (svc Service) RestorePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Restore account")
+	restoreError := pd.Trans("This link is invalid or has expired.")
+
+	db := svc.env.GetDb()
+	token := models.Token{Value: c.Param("token"), Type: models.TokenAccountRestore, ModelType: "User"}
+	res := db.Where(&token).First(&token)
+	if token.Value == "" || res.Error != nil || token.HasExpired() {
+		pd.AddMessage(routes.Error, restoreError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	user := models.User{}
+	res = db.Unscoped().Where("id = ?", token.ModelID).First(&user)
+	if res.Error != nil || !user.DeletedAt.Valid || user.PurgedAt != nil {
+		pd.AddMessage(routes.Error, restoreError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+	// Someone may have registered with the email after the account was deleted
+	if !svc.emailAvailable(db, user.Email) {
+		pd.AddMessage(routes.Error, pd.Trans("Your email is now used by another account and the account can not be restored, please contact support."))
+		c.HTML(http.StatusConflict, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Unscoped().Delete(&token)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return errors.New("restore token has already been used")
+		}
+		return tx.Unscoped().Model(&user).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		slog.Error("RestorePost", "error", err)
+		pd.AddMessage(routes.Error, restoreError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	audit.RecordUser(c, db, audit.UserRestored, user.ID, "self")
+
+	pd.AddMessage(routes.Success, pd.Trans("Your account has been restored, you may now login."))
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{PageData: pd})
+}
@@ -0,0 +1,369 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/uberswe/golang-base-project/apitoken"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+	"gorm.io/gorm"
+)
+
+const (
+	// emailChangeLifetime is how long the new address has to confirm a change
+	emailChangeLifetime = 24 * time.Hour
+	// emailRevertLifetime is how long the old address can revert a change
+	emailRevertLifetime = 7 * 24 * time.Hour
+)
+
+var errEmailChangeUsed = errors.New("email change has already been used")
+
+// EmailPageData holds the additional data needed to render the change email page
+type EmailPageData struct {
+	routes.PageData
+	Email   string
+	Pending *models.EmailChange
+}
+
+// LinkPageData holds the additional data needed to render the button which uses a link sent by email, such as
+// confirming or reverting an email change
+type LinkPageData struct {
+	routes.PageData
+	Action string
+	Text   string
+	Button string
+}
+
+func (svc Service) renderEmail(c *gin.Context, pd routes.PageData, user models.User, status int) {
+	pd.Title = pd.Trans("Change Email")
+	epd := EmailPageData{
+		PageData: pd,
+		Email:    user.Email,
+	}
+	pending := models.EmailChange{}
+	res := svc.env.GetDb().
+		Where("user_id = ? AND confirmed_at IS NULL AND reverted_at IS NULL AND created_at > ?", user.ID, time.Now().Add(-emailChangeLifetime)).
+		Order("id desc").Limit(1).Find(&pending)
+	if res.Error != nil {
+		slog.Error("renderEmail", "error", res.Error)
+	} else if res.RowsAffected > 0 {
+		epd.Pending = &pending
+	}
+	c.HTML(status, "email.gohtml", epd)
+}
+
+// Email renders the form where the current user changes their email
+func (svc Service) Email(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("Email", "error", err)
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderEmail(c, pd, user, http.StatusOK)
+}
+
+// EmailPost starts an email change. A confirmation link is sent to the new address and a link which reverts the change
+// is sent to the old address, the email is only changed once the new address is confirmed.
+func (svc Service) EmailPost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("EmailPost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	changeError := pd.Trans("Could not change your email, please make sure the email is correct and that it is not used by another account.")
+
+	newEmail := strings.TrimSpace(c.PostForm("email"))
+	err = validator.New().Var(newEmail, "required,email")
+	if err != nil || strings.EqualFold(newEmail, user.Email) {
+		pd.AddMessage(routes.Error, changeError)
+		svc.renderEmail(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	match, err := passhash.Verify(user.Password, c.PostForm("password"))
+	if err != nil {
+		slog.Error("EmailPost", "error", err)
+	}
+	if !match {
+		pd.AddMessage(routes.Error, pd.Trans("The password you entered is not correct."))
+		svc.renderEmail(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	db := svc.env.GetDb()
+	// The registration rules also apply to new emails, the generic error is shown so that the rules can not be probed
+	domainErr := svc.domains.Check(c.Request.Context(), svc.env.GetConfig(), newEmail)
+	if domainErr != nil {
+		slog.Info("EmailPost:Domain", "error", domainErr)
+	}
+	if domainErr != nil || !svc.emailAvailable(db, newEmail) {
+		pd.AddMessage(routes.Error, changeError)
+		svc.renderEmail(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	change := models.EmailChange{
+		UserID:   user.ID,
+		OldEmail: user.Email,
+		NewEmail: newEmail,
+	}
+	var confirmToken, revertToken models.Token
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// A new request replaces any change which has not been confirmed yet
+		err := tx.Unscoped().Where("type = ? AND model_type = ? AND model_id IN (?)", models.TokenEmailChange, "EmailChange",
+			tx.Model(&models.EmailChange{}).Select("id").Where("user_id = ?", user.ID)).Delete(&models.Token{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&change).Error
+		if err != nil {
+			return err
+		}
+		confirmToken, err = emailChangeToken(tx, change, models.TokenEmailChange, emailChangeLifetime)
+		if err != nil {
+			return err
+		}
+		revertToken, err = emailChangeToken(tx, change, models.TokenEmailRevert, emailRevertLifetime)
+		return err
+	})
+	if err != nil {
+		slog.Error("EmailPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderEmail(c, pd, user, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.EmailChangeRequested, user.ID, fmt.Sprintf("from=%q to=%q", change.OldEmail, change.NewEmail))
+
+	go svc.sendEmailChangeEmails(change, confirmToken.Value, revertToken.Value, pd.Trans)
+
+	pd.AddMessage(routes.Success, pd.Trans("We have sent a confirmation link to your new email. Your email will be changed once you have used the link."))
+	svc.renderEmail(c, pd, user, http.StatusOK)
+}
+
+// emailAvailable returns true if no account uses the email, it is checked the same way as when a user registers
+func (svc Service) emailAvailable(db *gorm.DB, email string) bool {
+	user := models.User{Email: email}
+	res := db.Where(&user).First(&user)
+	if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		slog.Error("emailAvailable", "error", res.Error)
+		return false
+	}
+	return res.RowsAffected == 0
+}
+
+func emailChangeToken(tx *gorm.DB, change models.EmailChange, tokenType string, lifetime time.Duration) (models.Token, error) {
+	value, err := secureToken()
+	if err != nil {
+		return models.Token{}, err
+	}
+	token := models.Token{
+		Value:     value,
+		Type:      tokenType,
+		ModelID:   int(change.ID),
+		ModelType: "EmailChange",
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	return token, tx.Create(&token).Error
+}
+
+// secureToken returns a token value for links which change the account, these are generated from a cryptographically
+// secure source unlike the ulid values used for other tokens
+func secureToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (svc Service) sendEmailChangeEmails(change models.EmailChange, confirmToken string, revertToken string, trans func(string) string) {
+	conf := svc.env.GetConfig()
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		slog.Error("sendEmailChangeEmails", "error", err)
+		return
+	}
+	confirmURL := *u
+	confirmURL.Path = path.Join(u.Path, "/account/email/confirm/", confirmToken)
+	revertURL := *u
+	revertURL.Path = path.Join(u.Path, "/account/email/revert/", revertToken)
+
+	emailService := email2.New(conf)
+
+	emailService.Send(change.NewEmail, trans("Confirm your new email"), fmt.Sprintf(trans("Use the following link to confirm this address as the new email of your account. If this was not requested by you, please ignore this email.\n%s"), confirmURL.String()))
+	emailService.Send(change.OldEmail, trans("Your email is being changed"), fmt.Sprintf(trans("A request was made to change the email of your account to %s. If this was not you, use the following link within 7 days to keep your current email and log out all sessions.\n%s"), change.NewEmail, revertURL.String()))
+}
+
+// EmailConfirm renders a button which confirms the new email. Opening the link does not use the token so that email
+// security scanners which follow links can not use it.
+func (svc Service) EmailConfirm(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Confirm new email")
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{
+		PageData: pd,
+		Action:   path.Join("/account/email/confirm/", c.Param("token")),
+		Text:     pd.Trans("Click the button below to confirm the new email of your account."),
+		Button:   pd.Trans("Confirm"),
+	})
+}
+
+// EmailConfirmPost uses the confirmation token and changes the email of the user to the new address
+func (svc Service) EmailConfirmPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Confirm new email")
+	confirmError := pd.Trans("This confirmation link is invalid or has expired.")
+
+	db := svc.env.GetDb()
+	token, change, user, ok := svc.emailChangeFromToken(db, c.Param("token"), models.TokenEmailChange)
+	if !ok || !change.IsPending() || user.Email != change.OldEmail {
+		pd.AddMessage(routes.Error, confirmError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+	if !svc.emailAvailable(db, change.NewEmail) {
+		pd.AddMessage(routes.Error, pd.Trans("Could not change your email, please make sure the email is correct and that it is not used by another account."))
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Unscoped().Delete(&token)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return errEmailChangeUsed
+		}
+		now := time.Now()
+		err := tx.Model(&change).Update("confirmed_at", &now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("email", change.NewEmail).Error
+	})
+	if err != nil {
+		slog.Error("EmailConfirmPost", "error", err)
+		pd.AddMessage(routes.Error, confirmError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	slog.Info("EmailConfirmPost:Changed", "user", user.ID)
+	audit.RecordUser(c, db, audit.EmailChanged, user.ID, fmt.Sprintf("from=%q to=%q", change.OldEmail, change.NewEmail))
+
+	pd.AddMessage(routes.Success, pd.Trans("Your email has been changed."))
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{PageData: pd})
+}
+
+// EmailRevert renders a button which reverts an email change
+func (svc Service) EmailRevert(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Revert email change")
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{
+		PageData: pd,
+		Action:   path.Join("/account/email/revert/", c.Param("token")),
+		Text:     pd.Trans("Click the button below to keep your previous email and log out all sessions of your account."),
+		Button:   pd.Trans("Revert"),
+	})
+}
+
+// EmailRevertPost uses the revert token sent to the old address. A change which has not been confirmed is cancelled,
+// a confirmed change is undone. All sessions are logged out as the change may have been made by someone else.
+func (svc Service) EmailRevertPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Revert email change")
+	revertError := pd.Trans("This link is invalid or has expired.")
+
+	db := svc.env.GetDb()
+	_, change, user, ok := svc.emailChangeFromToken(db, c.Param("token"), models.TokenEmailRevert)
+	// The email can only be reverted while it is still the one it was changed to
+	if !ok || change.RevertedAt != nil || (change.ConfirmedAt != nil && user.Email != change.NewEmail) {
+		pd.AddMessage(routes.Error, revertError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+	if change.ConfirmedAt != nil && !svc.emailAvailable(db, change.OldEmail) {
+		pd.AddMessage(routes.Error, pd.Trans("Your previous email is now used by another account and can not be restored, please contact support."))
+		c.HTML(http.StatusConflict, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Unscoped().Where("model_type = ? AND model_id = ?", "EmailChange", change.ID).Delete(&models.Token{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errEmailChangeUsed
+		}
+		now := time.Now()
+		err := tx.Model(&change).Update("reverted_at", &now).Error
+		if err != nil || change.ConfirmedAt == nil {
+			return err
+		}
+		return tx.Model(&user).Update("email", change.OldEmail).Error
+	})
+	if err != nil {
+		slog.Error("EmailRevertPost", "error", err)
+		pd.AddMessage(routes.Error, revertError)
+		c.HTML(http.StatusBadRequest, "link.gohtml", LinkPageData{PageData: pd})
+		return
+	}
+
+	count, err := session.RevokeAll(db, user.ID, "")
+	if err != nil {
+		slog.Error("EmailRevertPost", "error", err)
+	}
+	err = apitoken.RevokeAll(db, user.ID)
+	if err != nil {
+		slog.Error("EmailRevertPost", "error", err)
+	}
+	slog.Info("EmailRevertPost:Reverted", "user", user.ID, "sessions", count)
+	audit.RecordUser(c, db, audit.EmailChangeReverted, user.ID, fmt.Sprintf("email=%q confirmed=%t sessions_revoked=%d", change.OldEmail, change.ConfirmedAt != nil, count))
+
+	pd.AddMessage(routes.Success, pd.Trans("The email change has been reverted and all sessions have been logged out. If you did not request the change, please reset your password."))
+	c.HTML(http.StatusOK, "link.gohtml", LinkPageData{PageData: pd})
+}
+
+// emailChangeFromToken loads the token, the email change and the user of an email change link
+func (svc Service) emailChangeFromToken(db *gorm.DB, value string, tokenType string) (models.Token, models.EmailChange, models.User, bool) {
+	token := models.Token{Value: value, Type: tokenType, ModelType: "EmailChange"}
+	change := models.EmailChange{}
+	user := models.User{}
+	if value == "" {
+		return token, change, user, false
+	}
+	res := db.Where(&token).First(&token)
+	if res.Error != nil || token.HasExpired() {
+		return token, change, user, false
+	}
+	change.ID = uint(token.ModelID)
+	res = db.Where(&change).First(&change)
+	if res.Error != nil {
+		return token, change, user, false
+	}
+	user.ID = change.UserID
+	res = db.Where(&user).First(&user)
+	return token, change, user, res.Error == nil
+}
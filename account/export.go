@@ -0,0 +1,121 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// exportSection is a part of the data export. Only the listed columns are exported so that secrets such as password
+// hashes, token hashes and passkey credentials are never included.
+type exportSection struct {
+	Name    string
+	Model   interface{}
+	Columns []string
+	Where   string
+}
+
+// exportSections is the data a user owns, each section is a file in the ZIP archive or a key in the JSON document
+var exportSections = []exportSection{
+	{"user", &models.User{}, []string{"id", "created_at", "updated_at", "email", "display_name", "language", "time_zone", "theme", "activated_at", "totp_enabled_at", "disabled_at"}, "id = ?"},
+	{"sessions", &models.Session{}, []string{"created_at", "expires_at", "ip", "user_agent", "last_seen_at"}, "user_id = ?"},
+	{"passkeys", &models.Passkey{}, []string{"created_at", "name", "last_used_at"}, "user_id = ?"},
+	{"linked_accounts", &models.Identity{}, []string{"created_at", "provider", "email", "last_used_at"}, "user_id = ?"},
+	{"api_tokens", &models.APIToken{}, []string{"created_at", "name", "hint", "scopes", "expires_at", "last_used_at"}, "user_id = ?"},
+	{"oauth_consents", &models.OAuthConsent{}, []string{"created_at", "updated_at", "client_id", "scopes"}, "user_id = ?"},
+	{"email_changes", &models.EmailChange{}, []string{"created_at", "old_email", "new_email", "confirmed_at", "reverted_at"}, "user_id = ?"},
+	{"lockouts", &models.LockoutEvent{}, []string{"created_at", "ip", "locked_until", "unlocked_at"}, "user_id = ?"},
+	{"audit_log", &models.AuditEvent{}, []string{"created_at", "action", "actor_id", "subject_id", "details", "ip", "user_agent", "request_id"}, "actor_id = ? OR (subject_id = ? AND " + audit.SubjectIsUserCondition + ")"},
+}
+
+// exportData collects every section of the data export of the user
+func exportData(db *gorm.DB, userID uint) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	var roles []string
+	res := db.Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.deleted_at IS NULL", userID).
+		Order("roles.name").Pluck("roles.name", &roles)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	data["roles"] = roles
+	for _, s := range exportSections {
+		var rows []map[string]interface{}
+		args := make([]interface{}, strings.Count(s.Where, "?"))
+		for i := range args {
+			args[i] = userID
+		}
+		res = db.Model(s.Model).Select(s.Columns).Where(s.Where, args...).Order("created_at").Find(&rows)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		data[s.Name] = rows
+	}
+	return data, nil
+}
+
+// Export downloads the data the application holds about the current user, as a ZIP archive with a JSON file for each
+// section or as a single JSON document with ?format=json
+func (svc Service) Export(c *gin.Context) {
+	userID := c.GetUint(middleware.UserIDKey)
+	db := svc.env.GetDb()
+	data, err := exportData(db, userID)
+	if err != nil {
+		slog.Error("Export", "error", err)
+		c.String(http.StatusInternalServerError, "Something went wrong, please try again.")
+		return
+	}
+
+	format := "zip"
+	if c.Query("format") == "json" {
+		format = "json"
+	}
+	audit.Record(c, db, audit.UserDataExported, userID, "format="+format)
+
+	filename := fmt.Sprintf("account-data-%d-%s", userID, time.Now().Format("20060102"))
+	if format == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		c.IndentedJSON(http.StatusOK, data)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	archive := zip.NewWriter(c.Writer)
+	names := []string{"roles"}
+	for _, s := range exportSections {
+		names = append(names, s.Name)
+	}
+	for _, name := range names {
+		f, err := archive.Create(name + ".json")
+		if err == nil {
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(data[name])
+		}
+		if err != nil {
+			// The headers have been sent, the archive is left incomplete
+			slog.Error("Export", "error", err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		slog.Error("Export", "error", err)
+	}
+}
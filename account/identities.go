@@ -0,0 +1,90 @@
+package account
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// IdentitiesPageData holds the additional data needed to render the linked accounts page
+type IdentitiesPageData struct {
+	routes.PageData
+	Identities []models.Identity
+	Providers  []infra.OIDCProvider
+	Names      map[string]string
+}
+
+func (svc Service) renderIdentities(c *gin.Context, pd routes.PageData, status int) {
+	pd.Title = pd.Trans("Linked Accounts")
+	ipd := IdentitiesPageData{
+		PageData:  pd,
+		Providers: svc.env.GetConfig().OIDCProviders,
+		Names:     map[string]string{},
+	}
+	for _, p := range ipd.Providers {
+		ipd.Names[p.Key] = p.Name
+	}
+	userID := c.GetUint(middleware.UserIDKey)
+	res := svc.env.GetDb().Where(&models.Identity{UserID: userID}).Order("created_at").Find(&ipd.Identities)
+	if res.Error != nil {
+		slog.Error("renderIdentities", "error", res.Error)
+		ipd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "identities.gohtml", ipd)
+}
+
+// Identities renders the external login providers linked to the current user, the result of linking a provider is passed in the linked parameter
+func (svc Service) Identities(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	switch c.Query("linked") {
+	case "ok":
+		pd.AddMessage(routes.Success, pd.Trans("The account has been linked."))
+	case "taken":
+		pd.AddMessage(routes.Error, pd.Trans("This account is already linked to another user."))
+	case "error":
+		pd.AddMessage(routes.Error, pd.Trans("The account could not be linked."))
+	case "confirm":
+		pd.AddMessage(routes.Error, pd.Trans("Please confirm your password again before linking an account."))
+	}
+	svc.renderIdentities(c, pd, http.StatusOK)
+}
+
+// IdentityDeletePost unlinks an external login provider from the current user
+func (svc Service) IdentityDeletePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	identity := models.Identity{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err == nil {
+		identity.ID = uint(id)
+		identity.UserID = c.GetUint(middleware.UserIDKey)
+		err = svc.env.GetDb().Where(&identity).First(&identity).Error
+	}
+	if err != nil {
+		pd.AddMessage(routes.Error, pd.Trans("The account could not be unlinked."))
+		svc.renderIdentities(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	// Identities are removed permanently so that the same provider account can be linked again
+	db := svc.env.GetDb()
+	res := db.Unscoped().Delete(&identity)
+	if res.Error != nil {
+		slog.Error("IdentityDeletePost", "error", res.Error)
+		pd.AddMessage(routes.Error, pd.Trans("The account could not be unlinked."))
+		svc.renderIdentities(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.IdentityUnlinked, identity.UserID, fmt.Sprintf("provider=%s subject=%q email=%q", identity.Provider, identity.Subject, identity.Email))
+	pd.AddMessage(routes.Success, pd.Trans("The account has been unlinked."))
+	svc.renderIdentities(c, pd, http.StatusOK)
+}
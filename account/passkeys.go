@@ -0,0 +1,199 @@
+package account
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passkey"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// PasskeysPageData holds the additional data needed to render the passkey settings page
+type PasskeysPageData struct {
+	routes.PageData
+	Passkeys []models.Passkey
+	// Reauthenticated is false when the user has to confirm their password before adding a passkey
+	Reauthenticated bool
+}
+
+func (svc Service) renderPasskeys(c *gin.Context, pd routes.PageData, status int) {
+	pd.Title = pd.Trans("Passkeys")
+	ppd := PasskeysPageData{
+		PageData:        pd,
+		Reauthenticated: middleware.Reauthenticated(c),
+	}
+	userID := c.GetUint(middleware.UserIDKey)
+	res := svc.env.GetDb().Where(&models.Passkey{UserID: userID}).Order("created_at").Find(&ppd.Passkeys)
+	if res.Error != nil {
+		slog.Error("renderPasskeys", "error", res.Error)
+		ppd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "passkeys.gohtml", ppd)
+}
+
+// Passkeys renders the list of passkeys registered by the current user
+func (svc Service) Passkeys(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderPasskeys(c, pd, http.StatusOK)
+}
+
+// PasskeyRegisterBegin starts a registration ceremony and returns the credential creation options as JSON
+func (svc Service) PasskeyRegisterBegin(c *gin.Context) {
+	db := svc.env.GetDb()
+	user, err := passkey.LoadUser(db, c.GetUint(middleware.UserIDKey))
+	if err != nil {
+		slog.Error("PasskeyRegisterBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load user"})
+		return
+	}
+
+	wa, err := passkey.New(svc.env.GetConfig())
+	if err != nil {
+		slog.Error("PasskeyRegisterBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "passkeys are not configured"})
+		return
+	}
+
+	options, data, err := wa.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		slog.Error("PasskeyRegisterBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start registration"})
+		return
+	}
+
+	err = passkey.SaveSessionData(middleware.DefaultSessionWithOptions(c), passkey.RegistrationSessionKey, data)
+	if err != nil {
+		slog.Error("PasskeyRegisterBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// PasskeyRegisterFinish verifies the response of the authenticator and stores the new credential, the name is passed as a query parameter
+func (svc Service) PasskeyRegisterFinish(c *gin.Context) {
+	db := svc.env.GetDb()
+	user, err := passkey.LoadUser(db, c.GetUint(middleware.UserIDKey))
+	if err != nil {
+		slog.Error("PasskeyRegisterFinish", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load user"})
+		return
+	}
+
+	data, err := passkey.TakeSessionData(middleware.DefaultSessionWithOptions(c), passkey.RegistrationSessionKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no registration in progress"})
+		return
+	}
+
+	wa, err := passkey.New(svc.env.GetConfig())
+	if err != nil {
+		slog.Error("PasskeyRegisterFinish", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "passkeys are not configured"})
+		return
+	}
+
+	credential, err := wa.FinishRegistration(user, data, c.Request)
+	if err != nil {
+		slog.Info("PasskeyRegisterFinish", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the passkey could not be verified"})
+		return
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	p, err := passkey.Encode(user.ID, name, credential)
+	if err == nil {
+		err = db.Save(&p).Error
+	}
+	if err != nil {
+		slog.Error("PasskeyRegisterFinish", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save passkey"})
+		return
+	}
+
+	audit.Record(c, db, audit.PasskeyAdded, user.ID, fmt.Sprintf("passkey=%d name=%q", p.ID, p.Name))
+
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	go email2.New(svc.env.GetConfig()).Send(user.Email, pd.Trans("A passkey has been added to your account"), fmt.Sprintf(pd.Trans("The passkey %s has been added to your account and can be used to login. If this was not you, remove the passkey, change your password and review the sessions of your account."), p.Name))
+
+	c.JSON(http.StatusOK, gin.H{"redirect": "/account/passkeys"})
+}
+
+// userPasskey loads the passkey referenced by the id parameter if it belongs to the current user
+func (svc Service) userPasskey(c *gin.Context) (models.Passkey, bool) {
+	p := models.Passkey{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return p, false
+	}
+	p.ID = uint(id)
+	p.UserID = c.GetUint(middleware.UserIDKey)
+	res := svc.env.GetDb().Where(&p).First(&p)
+	return p, res.Error == nil
+}
+
+// PasskeyRenamePost changes the name of a passkey
+func (svc Service) PasskeyRenamePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	p, ok := svc.userPasskey(c)
+	name := strings.TrimSpace(c.PostForm("name"))
+	if !ok || name == "" {
+		pd.AddMessage(routes.Error, pd.Trans("The passkey could not be renamed."))
+		svc.renderPasskeys(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	res := svc.env.GetDb().Model(&p).Update("name", name)
+	if res.Error != nil {
+		slog.Error("PasskeyRenamePost", "error", res.Error)
+		pd.AddMessage(routes.Error, pd.Trans("The passkey could not be renamed."))
+		svc.renderPasskeys(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	pd.AddMessage(routes.Success, pd.Trans("The passkey has been renamed."))
+	svc.renderPasskeys(c, pd, http.StatusOK)
+}
+
+// PasskeyDeletePost revokes a passkey so that it can no longer be used to login
+func (svc Service) PasskeyDeletePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	p, ok := svc.userPasskey(c)
+	if !ok {
+		pd.AddMessage(routes.Error, pd.Trans("The passkey could not be removed."))
+		svc.renderPasskeys(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	// Passkeys are removed permanently so that the credential id can be registered again
+	db := svc.env.GetDb()
+	res := db.Unscoped().Delete(&p)
+	if res.Error != nil {
+		slog.Error("PasskeyDeletePost", "error", res.Error)
+		pd.AddMessage(routes.Error, pd.Trans("The passkey could not be removed."))
+		svc.renderPasskeys(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.PasskeyRemoved, p.UserID, fmt.Sprintf("passkey=%d name=%q", p.ID, p.Name))
+	pd.AddMessage(routes.Success, pd.Trans("The passkey has been removed."))
+	svc.renderPasskeys(c, pd, http.StatusOK)
+}
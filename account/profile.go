@@ -0,0 +1,181 @@
+package account
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/passwords"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+)
+
+// Theme is a color theme a user can choose
+type Theme struct {
+	Value string
+	Name  string
+}
+
+// themes are the themes a user can choose, auto follows the color scheme of the browser
+var themes = []Theme{
+	{Value: "light", Name: "Light"},
+	{Value: "dark", Name: "Dark"},
+	{Value: "auto", Name: "Same as the browser"},
+}
+
+// ProfilePageData holds the additional data needed to render the profile page
+type ProfilePageData struct {
+	routes.PageData
+	User      models.User
+	Languages []infra.Language
+	Themes    []Theme
+}
+
+func (svc Service) renderProfile(c *gin.Context, pd routes.PageData, user models.User, status int) {
+	pd.Title = pd.Trans("Profile")
+	c.HTML(status, "profile.gohtml", ProfilePageData{
+		PageData:  pd,
+		User:      user,
+		Languages: infra.Languages,
+		Themes:    themes,
+	})
+}
+
+// Profile renders the profile of the current user where preferences and the password are changed
+func (svc Service) Profile(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("Profile", "error", err)
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderProfile(c, pd, user, http.StatusOK)
+}
+
+// ProfilePost saves the display name, language, time zone and theme of the current user
+func (svc Service) ProfilePost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("ProfilePost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	prefs := models.Preferences{
+		DisplayName: strings.TrimSpace(c.PostForm("display_name")),
+		Language:    c.PostForm("language"),
+		TimeZone:    strings.TrimSpace(c.PostForm("time_zone")),
+		Theme:       c.PostForm("theme"),
+	}
+	valid := len(prefs.DisplayName) <= 100 &&
+		(prefs.Language == "" || slices.ContainsFunc(infra.Languages, func(l infra.Language) bool { return l.Tag == prefs.Language })) &&
+		(prefs.Theme == "" || slices.ContainsFunc(themes, func(t Theme) bool { return t.Value == prefs.Theme }))
+	if prefs.TimeZone != "" {
+		_, err = time.LoadLocation(prefs.TimeZone)
+		valid = valid && err == nil
+	}
+	if !valid {
+		pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+		pd.AddMessage(routes.Error, pd.Trans("Please check the values you have entered."))
+		user.Preferences = prefs
+		svc.renderProfile(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	res := svc.env.GetDb().Model(&user).Select("DisplayName", "Language", "TimeZone", "Theme").Updates(models.User{Preferences: prefs})
+	if res.Error != nil {
+		slog.Error("ProfilePost", "error", res.Error)
+		pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderProfile(c, pd, user, http.StatusInternalServerError)
+		return
+	}
+
+	// The page is rendered with the new preferences right away
+	user.Preferences = prefs
+	c.Set(middleware.PreferencesKey, prefs)
+	c.Set(infra.LanguageKey, prefs.Language)
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.AddMessage(routes.Success, pd.Trans("Your profile has been saved."))
+	svc.renderProfile(c, pd, user, http.StatusOK)
+}
+
+// PasswordPost changes the password of the current user after the current password has been confirmed. Every other
+// session of the user is logged out.
+func (svc Service) PasswordPost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("PasswordPost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+
+	match, err := passhash.Verify(user.Password, c.PostForm("current_password"))
+	if err != nil {
+		slog.Error("PasswordPost", "error", err)
+	}
+	if !match {
+		pd.AddMessage(routes.Error, pd.Trans("The password you entered is not correct."))
+		svc.renderProfile(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	password := c.PostForm("password")
+	if password != c.PostForm("password_confirm") {
+		pd.AddMessage(routes.Error, pd.Trans("The new passwords do not match."))
+		svc.renderProfile(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	db := svc.env.GetDb()
+	conf := svc.env.GetConfig()
+	violations := passwords.Check(db, conf, password, user)
+	if len(violations) > 0 {
+		for _, v := range violations {
+			pd.AddMessage(routes.Error, v.Message(pd.Trans))
+		}
+		svc.renderProfile(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	err = passwords.Remember(db, conf, user)
+	if err != nil {
+		slog.Error("PasswordPost", "error", err)
+	}
+
+	hashedPassword, err := passwords.Hash(conf, password)
+	if err == nil {
+		err = db.Model(&user).Update("password", hashedPassword).Error
+	}
+	if err != nil {
+		slog.Error("PasswordPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderProfile(c, pd, user, http.StatusInternalServerError)
+		return
+	}
+
+	// Anyone else who is logged in with the old password is logged out
+	identifier, _ := middleware.DefaultSessionWithOptions(c).Get(middleware.SessionIDKey).(string)
+	count, err := session.RevokeAll(db, user.ID, identifier)
+	if err != nil {
+		slog.Error("PasswordPost", "error", err)
+	}
+	audit.Record(c, db, audit.PasswordChanged, user.ID, fmt.Sprintf("sessions_revoked=%d", count))
+
+	go email2.New(conf).Send(user.Email, pd.Trans("Your password has been changed"), pd.Trans("The password of your account has been changed. If this was not you, reset your password right away and review the sessions of your account."))
+
+	pd.AddMessage(routes.Success, pd.Trans("Your password has been changed. Other sessions have been logged out."))
+	svc.renderProfile(c, pd, user, http.StatusOK)
+}
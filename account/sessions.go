@@ -0,0 +1,115 @@
+package account
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+	"github.com/uberswe/golang-base-project/text"
+)
+
+// SessionsPageData holds the additional data needed to render the sessions page
+type SessionsPageData struct {
+	routes.PageData
+	Sessions []ActiveSession
+}
+
+// ActiveSession is a session of the current user together with its parsed user agent
+type ActiveSession struct {
+	models.Session
+	Device  string // empty if the user agent is not recognized
+	Current bool
+}
+
+// currentSession returns the identifier of the session used for the current request
+func currentSession(c *gin.Context) string {
+	identifier, _ := middleware.DefaultSessionWithOptions(c).Get(middleware.SessionIDKey).(string)
+	return identifier
+}
+
+func (svc Service) renderSessions(c *gin.Context, pd routes.PageData, status int) {
+	pd.Title = pd.Trans("Sessions")
+	spd := SessionsPageData{
+		PageData: pd,
+	}
+	var sessions []models.Session
+	res := svc.env.GetDb().
+		Where("user_id = ? AND expires_at > ? AND impersonator_id = 0", c.GetUint(middleware.UserIDKey), time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions)
+	if res.Error != nil {
+		slog.Error("renderSessions", "error", res.Error)
+		spd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	current := currentSession(c)
+	for _, s := range sessions {
+		spd.Sessions = append(spd.Sessions, ActiveSession{
+			Session: s,
+			Device:  text.ParseUserAgent(s.UserAgent).String(),
+			Current: s.Identifier == current,
+		})
+	}
+	c.HTML(status, "sessions.gohtml", spd)
+}
+
+// Sessions renders the list of active sessions of the current user
+func (svc Service) Sessions(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderSessions(c, pd, http.StatusOK)
+}
+
+// SessionRevokePost logs out a single session of the current user
+func (svc Service) SessionRevokePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		pd.AddMessage(routes.Error, pd.Trans("The session could not be revoked."))
+		svc.renderSessions(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	ses := models.Session{UserID: c.GetUint(middleware.UserIDKey)}
+	ses.ID = uint(id)
+	res := svc.env.GetDb().Where(&ses).First(&ses)
+	if res.Error != nil {
+		pd.AddMessage(routes.Error, pd.Trans("The session could not be revoked."))
+		svc.renderSessions(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	err = session.Revoke(svc.env.GetDb(), ses.Identifier)
+	if err != nil {
+		slog.Error("SessionRevokePost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("The session could not be revoked."))
+		svc.renderSessions(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("SessionRevokePost:Revoked", "user", ses.UserID, "session", ses.ID)
+	pd.AddMessage(routes.Success, pd.Trans("The session has been revoked."))
+	svc.renderSessions(c, pd, http.StatusOK)
+}
+
+// SessionRevokeOthersPost logs out every session of the current user except the one used for this request
+func (svc Service) SessionRevokeOthersPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	userID := c.GetUint(middleware.UserIDKey)
+	count, err := session.RevokeAll(svc.env.GetDb(), userID, currentSession(c))
+	if err != nil {
+		slog.Error("SessionRevokeOthersPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderSessions(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("SessionRevokeOthersPost:Revoked", "user", userID, "count", count)
+	pd.AddMessage(routes.Success, pd.Trans("All other sessions have been revoked."))
+	svc.renderSessions(c, pd, http.StatusOK)
+}
@@ -0,0 +1,127 @@
+package account
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/apitoken"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// TokensPageData holds the additional data needed to render the personal access tokens page
+type TokensPageData struct {
+	routes.PageData
+	Tokens []models.APIToken
+	Scopes []string
+	// NewToken is only set right after a token has been created as it can not be shown again
+	NewToken string
+}
+
+// tokenExpiry are the number of days a new token can be valid for, 0 means the token never expires
+var tokenExpiry = []int{30, 90, 365, 0}
+
+func (svc Service) renderTokens(c *gin.Context, pd routes.PageData, status int, newToken string) {
+	pd.Title = pd.Trans("API Tokens")
+	tpd := TokensPageData{
+		PageData: pd,
+		Scopes:   apitoken.Scopes,
+		NewToken: newToken,
+	}
+	res := svc.env.GetDb().Where(&models.APIToken{UserID: c.GetUint(middleware.UserIDKey)}).Order("created_at desc").Find(&tpd.Tokens)
+	if res.Error != nil {
+		slog.Error("renderTokens", "error", res.Error)
+		tpd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "tokens.gohtml", tpd)
+}
+
+// Tokens renders the personal access tokens of the current user
+func (svc Service) Tokens(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderTokens(c, pd, http.StatusOK, "")
+}
+
+// TokenCreatePost creates a personal access token and shows it to the user once
+func (svc Service) TokenCreatePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	createError := pd.Trans("The token could not be created.")
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	days, err := strconv.Atoi(c.PostForm("expires"))
+	var scopes []string
+	for _, s := range c.PostFormArray("scopes") {
+		if slices.Contains(apitoken.Scopes, s) && !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if name == "" || len(name) > 100 || err != nil || !slices.Contains(tokenExpiry, days) || len(scopes) == 0 {
+		pd.AddMessage(routes.Error, pd.Trans("Please enter a name and choose at least one scope."))
+		svc.renderTokens(c, pd, http.StatusBadRequest, "")
+		return
+	}
+
+	value, hash, err := apitoken.New()
+	if err != nil {
+		slog.Error("TokenCreatePost", "error", err)
+		pd.AddMessage(routes.Error, createError)
+		svc.renderTokens(c, pd, http.StatusInternalServerError, "")
+		return
+	}
+	token := models.APIToken{
+		UserID:    c.GetUint(middleware.UserIDKey),
+		Name:      name,
+		Hint:      value[:len(apitoken.Prefix)+4],
+		TokenHash: hash,
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if days > 0 {
+		expires := time.Now().AddDate(0, 0, days)
+		token.ExpiresAt = &expires
+	}
+	res := svc.env.GetDb().Save(&token)
+	if res.Error != nil {
+		slog.Error("TokenCreatePost", "error", res.Error)
+		pd.AddMessage(routes.Error, createError)
+		svc.renderTokens(c, pd, http.StatusInternalServerError, "")
+		return
+	}
+
+	audit.Record(c, svc.env.GetDb(), audit.APITokenCreated, token.UserID, fmt.Sprintf("token=%d name=%q scopes=%q", token.ID, token.Name, token.Scopes))
+	pd.AddMessage(routes.Success, pd.Trans("Your token has been created. Copy it now, it will not be shown again."))
+	svc.renderTokens(c, pd, http.StatusOK, value)
+}
+
+// TokenRevokePost deletes a personal access token so that it can no longer be used
+func (svc Service) TokenRevokePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	id, err := strconv.Atoi(c.Param("id"))
+	token := models.APIToken{UserID: c.GetUint(middleware.UserIDKey)}
+	token.ID = uint(id)
+	if err != nil || svc.env.GetDb().Where(&token).First(&token).Error != nil {
+		pd.AddMessage(routes.Error, pd.Trans("The token could not be revoked."))
+		svc.renderTokens(c, pd, http.StatusBadRequest, "")
+		return
+	}
+
+	res := svc.env.GetDb().Unscoped().Delete(&token)
+	if res.Error != nil {
+		slog.Error("TokenRevokePost", "error", res.Error)
+		pd.AddMessage(routes.Error, pd.Trans("The token could not be revoked."))
+		svc.renderTokens(c, pd, http.StatusInternalServerError, "")
+		return
+	}
+
+	audit.Record(c, svc.env.GetDb(), audit.APITokenRevoked, token.UserID, fmt.Sprintf("token=%d name=%q", token.ID, token.Name))
+	pd.AddMessage(routes.Success, pd.Trans("The token has been revoked."))
+	svc.renderTokens(c, pd, http.StatusOK, "")
+}
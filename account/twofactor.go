@@ -0,0 +1,235 @@
+package account
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/twofactor"
+)
+
+// pendingTOTPKey holds the otpauth URI of a secret which has been shown to the user but not yet confirmed
+const pendingTOTPKey = "PendingTOTP"
+
+// TwoFactorPageData holds the additional data needed to render the two-factor authentication settings page
+type TwoFactorPageData struct {
+	routes.PageData
+	Enabled        bool
+	Secret         string
+	QRCode         template.URL
+	RecoveryCodes  []string
+	RemainingCodes int64
+}
+
+// issuer is the name shown for the account in authenticator apps
+func (svc Service) issuer() string {
+	u, err := url.Parse(svc.env.GetConfig().BaseURL)
+	if err != nil || u.Hostname() == "" {
+		return "golang-base-project"
+	}
+	return u.Hostname()
+}
+
+func (svc Service) twoFactorPageData(c *gin.Context, user models.User) TwoFactorPageData {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Two-Factor Authentication")
+	tfpd := TwoFactorPageData{
+		PageData: pd,
+		Enabled:  user.HasTwoFactor(),
+	}
+	if tfpd.Enabled {
+		svc.env.GetDb().Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&tfpd.RemainingCodes)
+	}
+	return tfpd
+}
+
+// enrollmentKey returns the secret currently being enrolled or generates a new one and stores it in the session
+func (svc Service) enrollmentKey(c *gin.Context, user models.User) (*otp.Key, error) {
+	session := middleware.DefaultSessionWithOptions(c)
+	if uri, ok := session.Get(pendingTOTPKey).(string); ok {
+		key, err := otp.NewKeyFromURL(uri)
+		if err == nil && key.AccountName() == user.Email {
+			return key, nil
+		}
+	}
+	key, err := twofactor.GenerateKey(svc.issuer(), user.Email)
+	if err != nil {
+		return nil, err
+	}
+	session.Set(pendingTOTPKey, key.URL())
+	return key, session.Save()
+}
+
+// renderEnrollment renders the page with a QR code and secret which the user scans with an authenticator app
+func (svc Service) renderEnrollment(c *gin.Context, pd TwoFactorPageData, user models.User, status int) {
+	key, err := svc.enrollmentKey(c, user)
+	if err != nil {
+		slog.Error("renderEnrollment", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		c.HTML(http.StatusInternalServerError, "twofactor.gohtml", pd)
+		return
+	}
+	pd.Secret = key.Secret()
+	pd.QRCode, err = twofactor.QRCode(key)
+	if err != nil {
+		slog.Error("renderEnrollment", "error", err)
+	}
+	c.HTML(status, "twofactor.gohtml", pd)
+}
+
+// TwoFactor renders the two-factor authentication settings of the current user
+func (svc Service) TwoFactor(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("TwoFactor", "error", err)
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	pd := svc.twoFactorPageData(c, user)
+	if pd.Enabled {
+		c.HTML(http.StatusOK, "twofactor.gohtml", pd)
+		return
+	}
+	svc.renderEnrollment(c, pd, user, http.StatusOK)
+}
+
+// TwoFactorEnablePost confirms the secret shown during enrollment with a code from the authenticator and enables two-factor authentication
+func (svc Service) TwoFactorEnablePost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("TwoFactorEnablePost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := svc.twoFactorPageData(c, user)
+	if pd.Enabled {
+		c.Redirect(http.StatusFound, "/account/2fa")
+		return
+	}
+
+	key, err := svc.enrollmentKey(c, user)
+	if err != nil {
+		slog.Error("TwoFactorEnablePost", "error", err)
+		c.Redirect(http.StatusFound, "/account/2fa")
+		return
+	}
+
+	step, ok := twofactor.ValidateCode(key.Secret(), c.PostForm("code"), 0)
+	if !ok {
+		pd.AddMessage(routes.Error, pd.Trans("The code you entered is not valid, please try again."))
+		svc.renderEnrollment(c, pd, user, http.StatusBadRequest)
+		return
+	}
+
+	db := svc.env.GetDb()
+	now := time.Now()
+	res := db.Model(&user).Updates(models.User{
+		TOTPSecret:    key.Secret(),
+		TOTPEnabledAt: &now,
+		TOTPLastStep:  step,
+	})
+	if res.Error != nil {
+		slog.Error("TwoFactorEnablePost", "error", res.Error)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderEnrollment(c, pd, user, http.StatusInternalServerError)
+		return
+	}
+
+	codes, err := twofactor.ReplaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		slog.Error("TwoFactorEnablePost", "error", err)
+	}
+
+	session := middleware.DefaultSessionWithOptions(c)
+	session.Delete(pendingTOTPKey)
+	err = session.Save()
+	if err != nil {
+		slog.Error("TwoFactorEnablePost", "error", err)
+	}
+
+	slog.Info("TwoFactorEnablePost:Enabled", "user", user.ID)
+	pd.Enabled = true
+	pd.RecoveryCodes = codes
+	pd.RemainingCodes = int64(len(codes))
+	pd.AddMessage(routes.Success, pd.Trans("Two-factor authentication has been enabled."))
+	c.HTML(http.StatusOK, "twofactor.gohtml", pd)
+}
+
+// TwoFactorDisablePost disables two-factor authentication after the user has confirmed it with a valid code
+func (svc Service) TwoFactorDisablePost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("TwoFactorDisablePost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := svc.twoFactorPageData(c, user)
+	if !pd.Enabled {
+		c.Redirect(http.StatusFound, "/account/2fa")
+		return
+	}
+
+	db := svc.env.GetDb()
+	if !twofactor.Verify(db, &user, c.PostForm("code")) {
+		pd.AddMessage(routes.Error, pd.Trans("The code you entered is not valid, please try again."))
+		c.HTML(http.StatusBadRequest, "twofactor.gohtml", pd)
+		return
+	}
+
+	err = twofactor.Reset(db, &user)
+	if err != nil {
+		slog.Error("TwoFactorDisablePost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		c.HTML(http.StatusInternalServerError, "twofactor.gohtml", pd)
+		return
+	}
+
+	slog.Info("TwoFactorDisablePost:Disabled", "user", user.ID)
+	user.TOTPEnabledAt = nil
+	user.TOTPSecret = ""
+	pd = svc.twoFactorPageData(c, user)
+	pd.AddMessage(routes.Success, pd.Trans("Two-factor authentication has been disabled."))
+	svc.renderEnrollment(c, pd, user, http.StatusOK)
+}
+
+// RecoveryCodesPost replaces all recovery codes of the user after confirming with a valid code
+func (svc Service) RecoveryCodesPost(c *gin.Context) {
+	user, err := svc.currentUser(c)
+	if err != nil {
+		slog.Error("RecoveryCodesPost", "error", err)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	pd := svc.twoFactorPageData(c, user)
+	if !pd.Enabled {
+		c.Redirect(http.StatusFound, "/account/2fa")
+		return
+	}
+
+	db := svc.env.GetDb()
+	if !twofactor.Verify(db, &user, c.PostForm("code")) {
+		pd.AddMessage(routes.Error, pd.Trans("The code you entered is not valid, please try again."))
+		c.HTML(http.StatusBadRequest, "twofactor.gohtml", pd)
+		return
+	}
+
+	codes, err := twofactor.ReplaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		slog.Error("RecoveryCodesPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		c.HTML(http.StatusInternalServerError, "twofactor.gohtml", pd)
+		return
+	}
+
+	pd.RecoveryCodes = codes
+	pd.RemainingCodes = int64(len(codes))
+	pd.AddMessage(routes.Success, pd.Trans("New recovery codes have been generated, your old codes can no longer be used."))
+	c.HTML(http.StatusOK, "twofactor.gohtml", pd)
+}
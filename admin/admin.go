@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/uberswe/golang-base-project/infra"
 	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
 	"github.com/uberswe/golang-base-project/routes"
 	"gorm.io/gorm"
 )
@@ -25,7 +26,10 @@ func NewService(env infra.ILair) *Service {
 
 type AdminData struct {
 	routes.PageData
-	Chart Chart
+	Chart    Chart
+	Lockouts []models.LockoutEvent
+	// LegacyHashes is the number of users whose password is not yet hashed with Argon2id
+	LegacyHashes int64
 }
 
 type Chart struct {
@@ -52,6 +56,11 @@ type MonthlySignUps struct {
 // Admin renders the admin dashboard
 func (svc Service) Admin(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderAdmin(c, pd, http.StatusOK)
+}
+
+// renderAdmin renders the admin dashboard with any messages already added to the page data
+func (svc Service) renderAdmin(c *gin.Context, pd routes.PageData, status int) {
 	pd.Title = pd.Trans("Admin")
 
 	ad := AdminData{
@@ -74,7 +83,7 @@ func (svc Service) Admin(c *gin.Context) {
 		Find(&msu)
 
 	if res.Error != nil && res.Error != gorm.ErrRecordNotFound {
-		pd.AddMessage(routes.Error, "Something went wrong while fetching user data")
+		ad.AddMessage(routes.Error, "Something went wrong while fetching user data")
 		slog.Error("Admin:DB", "error", res.Error)
 		c.HTML(http.StatusInternalServerError, "admin.gohtml", ad)
 		return
@@ -178,5 +187,15 @@ func (svc Service) Admin(c *gin.Context) {
 
 	// The chart is inverted so we need to subtract the base value to our calculated values
 
-	c.HTML(http.StatusOK, "admin.gohtml", ad)
+	res = db.Order("created_at desc").Limit(20).Find(&ad.Lockouts)
+	if res.Error != nil {
+		slog.Error("Admin:Lockouts", "error", res.Error)
+	}
+
+	res = db.Model(&models.User{}).Where("password NOT LIKE ?", passhash.Argon2idPattern).Count(&ad.LegacyHashes)
+	if res.Error != nil {
+		slog.Error("Admin:LegacyHashes", "error", res.Error)
+	}
+
+	c.HTML(status, "admin.gohtml", ad)
 }
@@ -0,0 +1,308 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"gorm.io/gorm"
+)
+
+// eventsPerPage is the number of events shown on each page of the audit log
+const eventsPerPage = 50
+
+// AuditFilter holds the filters of the audit log, they are used by both the viewer and the export
+type AuditFilter struct {
+	Action    string
+	Actor     string
+	Subject   string
+	RequestID string
+	From      string
+	To        string
+}
+
+// AuditPageData holds the additional data needed to render the audit log
+type AuditPageData struct {
+	routes.PageData
+	Filter  AuditFilter
+	Events  []models.AuditEvent
+	Actions []string
+	// Emails are the emails of the users who appear as actor or subject of the listed events
+	Emails  map[uint]string
+	Total   int64
+	Page    int
+	PrevURL string
+	NextURL string
+	CSVURL  string
+	JSONURL string
+	// Verified is true when the chain was checked, Checked and BrokenID hold the result
+	Verified bool
+	Checked  int
+	BrokenID uint
+}
+
+// SubjectEmail returns the email of the user the event was taken on, or an empty string if the subject is not a user
+func (apd AuditPageData) SubjectEmail(e models.AuditEvent) string {
+	if !audit.SubjectIsUser(e.Action) {
+		return ""
+	}
+	return apd.Emails[e.SubjectID]
+}
+
+// AuditEventJSON is how an event is represented in the JSON export
+type AuditEventJSON struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ActorID   uint      `json:"actor_id"`
+	Action    string    `json:"action"`
+	SubjectID uint      `json:"subject_id"`
+	Details   string    `json:"details"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	RequestID string    `json:"request_id"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// Audit renders the audit log, newest events first. The hash chain of the whole log is checked when verify is set.
+func (svc Service) Audit(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Audit log")
+	db := svc.env.GetDb()
+
+	apd := AuditPageData{
+		PageData: pd,
+		Filter:   auditFilter(c),
+		Actions:  audit.Actions,
+		Page:     1,
+	}
+	if i, err := strconv.Atoi(c.Query("page")); err == nil && i > 1 {
+		apd.Page = i
+	}
+	apd.CSVURL = apd.Filter.url("/admin/audit/export", url.Values{"format": {"csv"}})
+	apd.JSONURL = apd.Filter.url("/admin/audit/export", url.Values{"format": {"json"}})
+
+	status := http.StatusOK
+	query := apd.Filter.query(db)
+	res := query.Count(&apd.Total)
+	if res.Error == nil {
+		res = query.Order("id DESC").
+			Limit(eventsPerPage).
+			Offset(eventsPerPage * (apd.Page - 1)).
+			Find(&apd.Events)
+	}
+	if res.Error == nil {
+		apd.Emails, res.Error = emails(db, apd.Events)
+	}
+	if res.Error != nil {
+		slog.Error("Audit", "error", res.Error)
+		apd.AddMessage(routes.Error, apd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+
+	if c.Query("verify") != "" {
+		checked, broken, err := audit.Verify(db)
+		if err != nil {
+			slog.Error("Audit", "error", err)
+			apd.AddMessage(routes.Error, apd.Trans("Something went wrong, please try again."))
+		} else {
+			apd.Verified, apd.Checked, apd.BrokenID = true, checked, broken
+			if broken != 0 {
+				slog.Warn("Audit:ChainBroken", "event", broken)
+				apd.AddMessage(routes.Error, apd.Trans("The audit log has been tampered with, the chain is broken at this event:")+fmt.Sprintf(" #%d", broken))
+			} else {
+				apd.AddMessage(routes.Success, apd.Trans("The audit log is intact."))
+			}
+		}
+	}
+
+	if apd.Page > 1 {
+		apd.PrevURL = apd.Filter.url("/admin/audit", url.Values{"page": {strconv.Itoa(apd.Page - 1)}})
+	}
+	if int64(apd.Page*eventsPerPage) < apd.Total {
+		apd.NextURL = apd.Filter.url("/admin/audit", url.Values{"page": {strconv.Itoa(apd.Page + 1)}})
+	}
+
+	c.HTML(status, "audit.gohtml", apd)
+}
+
+// AuditExport downloads every event which matches the filters as CSV or JSON, oldest events first so that the chain can
+// be checked from the export
+func (svc Service) AuditExport(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	filter := auditFilter(c)
+	query := filter.query(svc.env.GetDb())
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+
+	var batch []models.AuditEvent
+	var err error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		err = w.Write([]string{"id", "created_at", "actor_id", "action", "subject_id", "details", "ip", "user_agent", "request_id", "prev_hash", "hash"})
+		if err == nil {
+			err = query.Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+				for _, e := range batch {
+					err := w.Write([]string{
+						strconv.FormatUint(uint64(e.ID), 10),
+						e.CreatedAt.UTC().Format(time.RFC3339),
+						strconv.FormatUint(uint64(e.ActorID), 10),
+						e.Action,
+						strconv.FormatUint(uint64(e.SubjectID), 10),
+						e.Details,
+						e.IP,
+						e.UserAgent,
+						e.RequestID,
+						e.PrevHash,
+						e.Hash,
+					})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+		}
+		w.Flush()
+		if err == nil {
+			err = w.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		enc := json.NewEncoder(c.Writer)
+		separator := "["
+		err = query.Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, e := range batch {
+				_, err := c.Writer.WriteString(separator)
+				if err != nil {
+					return err
+				}
+				separator = ","
+				err = enc.Encode(AuditEventJSON(e))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+		if err == nil {
+			if separator == "[" {
+				_, err = c.Writer.WriteString("[")
+			}
+			if err == nil {
+				_, err = c.Writer.WriteString("]\n")
+			}
+		}
+	}
+	if err != nil {
+		// The response has already started so the error can only be logged
+		slog.Error("AuditExport", "error", err)
+	}
+}
+
+func auditFilter(c *gin.Context) AuditFilter {
+	return AuditFilter{
+		Action:    c.Query("action"),
+		Actor:     strings.TrimSpace(c.Query("actor")),
+		Subject:   strings.TrimSpace(c.Query("subject")),
+		RequestID: strings.TrimSpace(c.Query("request_id")),
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+	}
+}
+
+// query returns the events which match the filters. The actor and subject can be given as a user id or an email.
+func (f AuditFilter) query(db *gorm.DB) *gorm.DB {
+	query := db.Model(&models.AuditEvent{})
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.Actor != "" {
+		if id, ok := userID(db, f.Actor); ok {
+			query = query.Where("actor_id = ?", id)
+		} else {
+			query = query.Where("1 = 0")
+		}
+	}
+	if f.Subject != "" {
+		if id, ok := userID(db, f.Subject); ok {
+			query = query.Where("subject_id = ? AND "+audit.SubjectIsUserCondition, id)
+		} else {
+			query = query.Where("1 = 0")
+		}
+	}
+	if f.RequestID != "" {
+		query = query.Where("request_id = ?", f.RequestID)
+	}
+	if from, err := time.ParseInLocation(dateLayout, f.From, time.Local); err == nil {
+		query = query.Where("created_at >= ?", from.UTC())
+	}
+	if to, err := time.ParseInLocation(dateLayout, f.To, time.Local); err == nil {
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1).UTC())
+	}
+	return query
+}
+
+// url returns the address of path with the same filters and the additional values
+func (f AuditFilter) url(path string, v url.Values) string {
+	for key, value := range map[string]string{"action": f.Action, "actor": f.Actor, "subject": f.Subject, "request_id": f.RequestID, "from": f.From, "to": f.To} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	return path + "?" + v.Encode()
+}
+
+// userID returns the id of the user with the given id or email, including deleted users. It returns false if no user
+// has the email.
+func userID(db *gorm.DB, idOrEmail string) (uint64, bool) {
+	if id, err := strconv.ParseUint(idOrEmail, 10, 64); err == nil {
+		return id, true
+	}
+	user := models.User{}
+	res := db.Unscoped().Where("LOWER(email) = ?", strings.ToLower(idOrEmail)).Limit(1).Find(&user)
+	if res.Error != nil {
+		slog.Error("userID", "error", res.Error)
+	}
+	return uint64(user.ID), user.ID != 0
+}
+
+// emails looks up the emails of the actors and the users who are subjects of the events, deleted users included
+func emails(db *gorm.DB, events []models.AuditEvent) (map[uint]string, error) {
+	var ids []uint
+	for _, e := range events {
+		if e.ActorID != 0 {
+			ids = append(ids, e.ActorID)
+		}
+		if e.SubjectID != 0 && audit.SubjectIsUser(e.Action) {
+			ids = append(ids, e.SubjectID)
+		}
+	}
+	found := map[uint]string{}
+	if len(ids) == 0 {
+		return found, nil
+	}
+	var users []models.User
+	res := db.Unscoped().Select("id", "email").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		found[u.ID] = u.Email
+	}
+	return found, res.Error
+}
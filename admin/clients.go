@@ -0,0 +1,232 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/idp"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// ClientsPageData holds the additional data needed to render the OpenID Connect client management page
+type ClientsPageData struct {
+	routes.PageData
+	Clients []models.OAuthClient
+	Scopes  []string
+	// NewClientID and NewSecret are set once after a client is created or its secret is rotated
+	NewClientID string
+	NewSecret   string
+}
+
+func (svc Service) renderClients(c *gin.Context, cpd ClientsPageData, status int) {
+	cpd.Title = cpd.Trans("Clients")
+	cpd.Scopes = idp.Scopes
+	res := svc.env.GetDb().Order("name").Find(&cpd.Clients)
+	if res.Error != nil {
+		slog.Error("renderClients", "error", res.Error)
+		cpd.AddMessage(routes.Error, cpd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "clients.gohtml", cpd)
+}
+
+// Clients renders the applications which can use this application as their OpenID Connect provider
+func (svc Service) Clients(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderClients(c, ClientsPageData{PageData: pd}, http.StatusOK)
+}
+
+// clientForm reads and validates the name, redirect uris and scopes of a client from the posted form
+func clientForm(c *gin.Context, client *models.OAuthClient) error {
+	client.Name = strings.TrimSpace(c.PostForm("name"))
+	if client.Name == "" {
+		return errors.New("name is required")
+	}
+
+	var uris []string
+	for _, uri := range strings.Fields(c.PostForm("redirect_uris")) {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" || !redirectSchemeAllowed(u) {
+			return errors.New("invalid redirect uri")
+		}
+		uris = append(uris, uri)
+	}
+	if len(uris) == 0 {
+		return errors.New("at least one redirect uri is required")
+	}
+	client.RedirectURIs = strings.Join(uris, "\n")
+
+	scopes := []string{idp.ScopeOpenID}
+	for _, s := range c.PostFormArray("scopes") {
+		if slices.Contains(idp.Scopes, s) && !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	client.Scopes = strings.Join(scopes, " ")
+	return nil
+}
+
+// redirectSchemeAllowed returns true for https redirect uris and for http redirect uris on the loopback interface, which
+// native apps listen on. Other schemes such as javascript: and data: are never allowed.
+func redirectSchemeAllowed(u *url.URL) bool {
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// ClientCreatePost registers a new client and shows the generated client id and secret once
+func (svc Service) ClientCreatePost(c *gin.Context) {
+	cpd := ClientsPageData{PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)}
+
+	client := models.OAuthClient{}
+	err := clientForm(c, &client)
+	if err != nil {
+		cpd.AddMessage(routes.Error, cpd.Trans("Please enter a name and at least one valid redirect URI."))
+		svc.renderClients(c, cpd, http.StatusBadRequest)
+		return
+	}
+
+	client.ClientID, err = idp.NewSecret()
+	if err == nil {
+		cpd.NewSecret, err = idp.NewSecret()
+	}
+	if err == nil {
+		client.SecretHash = idp.HashSecret(cpd.NewSecret)
+		err = svc.env.GetDb().Save(&client).Error
+	}
+	if err != nil {
+		slog.Error("ClientCreatePost", "error", err)
+		cpd.NewSecret = ""
+		cpd.AddMessage(routes.Error, cpd.Trans("Something went wrong, please try again."))
+		svc.renderClients(c, cpd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, svc.env.GetDb(), audit.ClientCreated, client.ID, clientDetails(client))
+	cpd.NewClientID = client.ClientID
+	cpd.AddMessage(routes.Success, cpd.Trans("The client has been created. Copy the secret now, it will not be shown again."))
+	svc.renderClients(c, cpd, http.StatusOK)
+}
+
+// clientDetails describes a client in the audit log
+func clientDetails(client models.OAuthClient) string {
+	return fmt.Sprintf("client_id=%s name=%q redirect_uris=%q scopes=%q", client.ClientID, client.Name, strings.ReplaceAll(client.RedirectURIs, "\n", " "), client.Scopes)
+}
+
+// client loads the client referenced by the id parameter
+func (svc Service) client(c *gin.Context) (models.OAuthClient, bool) {
+	client := models.OAuthClient{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return client, false
+	}
+	client.ID = uint(id)
+	res := svc.env.GetDb().Where(&client).First(&client)
+	return client, res.Error == nil
+}
+
+// ClientUpdatePost changes the name, redirect uris and scopes of a client
+func (svc Service) ClientUpdatePost(c *gin.Context) {
+	cpd := ClientsPageData{PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)}
+
+	client, ok := svc.client(c)
+	if !ok || clientForm(c, &client) != nil {
+		cpd.AddMessage(routes.Error, cpd.Trans("Please enter a name and at least one valid redirect URI."))
+		svc.renderClients(c, cpd, http.StatusBadRequest)
+		return
+	}
+
+	res := svc.env.GetDb().Save(&client)
+	if res.Error != nil {
+		slog.Error("ClientUpdatePost", "error", res.Error)
+		cpd.AddMessage(routes.Error, cpd.Trans("Something went wrong, please try again."))
+		svc.renderClients(c, cpd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, svc.env.GetDb(), audit.ClientUpdated, client.ID, clientDetails(client))
+	cpd.AddMessage(routes.Success, cpd.Trans("The client has been updated."))
+	svc.renderClients(c, cpd, http.StatusOK)
+}
+
+// ClientSecretPost replaces the secret of a client, the old secret stops working immediately
+func (svc Service) ClientSecretPost(c *gin.Context) {
+	cpd := ClientsPageData{PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)}
+
+	client, ok := svc.client(c)
+	if !ok {
+		cpd.AddMessage(routes.Error, cpd.Trans("The client could not be found."))
+		svc.renderClients(c, cpd, http.StatusBadRequest)
+		return
+	}
+
+	secret, err := idp.NewSecret()
+	if err == nil {
+		err = svc.env.GetDb().Model(&client).Update("secret_hash", idp.HashSecret(secret)).Error
+	}
+	if err != nil {
+		slog.Error("ClientSecretPost", "error", err)
+		cpd.AddMessage(routes.Error, cpd.Trans("Something went wrong, please try again."))
+		svc.renderClients(c, cpd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, svc.env.GetDb(), audit.ClientSecretRotated, client.ID, fmt.Sprintf("client_id=%s", client.ClientID))
+	cpd.NewClientID = client.ClientID
+	cpd.NewSecret = secret
+	cpd.AddMessage(routes.Success, cpd.Trans("A new secret has been generated. Copy the secret now, it will not be shown again."))
+	svc.renderClients(c, cpd, http.StatusOK)
+}
+
+// ClientDeletePost removes a client together with the consents and access tokens which were given to it
+func (svc Service) ClientDeletePost(c *gin.Context) {
+	cpd := ClientsPageData{PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)}
+	db := svc.env.GetDb()
+
+	client, ok := svc.client(c)
+	if !ok {
+		cpd.AddMessage(routes.Error, cpd.Trans("The client could not be found."))
+		svc.renderClients(c, cpd, http.StatusBadRequest)
+		return
+	}
+
+	err := db.Unscoped().Where(&models.OAuthConsent{ClientID: client.ID}).Delete(&models.OAuthConsent{}).Error
+	if err == nil {
+		err = db.Unscoped().Where(&models.OAuthAccessToken{ClientID: client.ID}).Delete(&models.OAuthAccessToken{}).Error
+	}
+	if err == nil {
+		err = db.Unscoped().Where(&models.OAuthAuthorization{ClientID: client.ID}).Delete(&models.OAuthAuthorization{}).Error
+	}
+	if err == nil {
+		err = db.Unscoped().Delete(&client).Error
+	}
+	if err != nil {
+		slog.Error("ClientDeletePost", "error", err)
+		cpd.AddMessage(routes.Error, cpd.Trans("Something went wrong, please try again."))
+		svc.renderClients(c, cpd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.ClientDeleted, client.ID, clientDetails(client))
+	cpd.AddMessage(routes.Success, cpd.Trans("The client has been deleted."))
+	svc.renderClients(c, cpd, http.StatusOK)
+}
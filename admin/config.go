@@ -1,26 +1,36 @@
 package admin
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
 	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/invite"
+	"github.com/uberswe/golang-base-project/ratelimit"
 	"github.com/uberswe/golang-base-project/routes"
 )
 
 type ConfigPageData struct {
 	routes.PageData
-	Config   *infra.Config
-	LogLevel string
+	Config     *infra.Config
+	LogLevel   string
+	RateLimits []ratelimit.Policy
 }
 
 func (svc Service) pageData(c *gin.Context) *ConfigPageData {
 	return &ConfigPageData{
-		PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter),
-		Config:   svc.env.GetConfig(),
-		LogLevel: svc.env.GetLoggingLevel().Level().String(),
+		PageData:   routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter),
+		Config:     svc.env.GetConfig(),
+		LogLevel:   svc.env.GetLoggingLevel().Level().String(),
+		RateLimits: svc.env.GetRateLimiter().Policies(),
 	}
 }
 func (svc Service) ConfigRouteHandler(c *gin.Context) {
@@ -42,6 +52,7 @@ func (svc Service) LoggingRouteHandlerPost(c *gin.Context) {
 
 	// update logging level
 	if err == nil {
+		svc.configChanged(c, "log_level", svc.env.GetLoggingLevel().Level().String(), level.String())
 		svc.env.GetLoggingLevel().Set(level)
 	}
 
@@ -68,68 +79,181 @@ func (svc Service) ConfigRouteHandlerPost(c *gin.Context) {
 	newValue := c.PostForm("base_url")
 	if newValue != prevCfg.BaseURL {
 		slog.Info("BaseUrl", "newValue", newValue)
+		svc.configChanged(c, "base_url", prevCfg.BaseURL, newValue)
 		prevCfg.BaseURL = newValue
 		pd.AddMessage(routes.Success, pd.Trans("Base URL changed"))
 	}
 	newValue = c.PostForm("smtp_host")
 	if newValue != prevCfg.SMTPHost {
 		slog.Info("SmtpHost", "newValue", newValue)
+		svc.configChanged(c, "smtp_host", prevCfg.SMTPHost, newValue)
 		prevCfg.SMTPHost = newValue
 		pd.AddMessage(routes.Success, pd.Trans("SMTP host changed"))
 	}
 	newValue = c.PostForm("smtp_port")
 	if newValue != prevCfg.SMTPPort {
 		slog.Info("SMTPPort", "newValue", newValue)
+		svc.configChanged(c, "smtp_port", prevCfg.SMTPPort, newValue)
 		prevCfg.SMTPPort = newValue
 		pd.AddMessage(routes.Success, pd.Trans("SMTP port changed"))
 	}
 	newValue = c.PostForm("smtp_sender")
 	if newValue != prevCfg.SMTPSender {
 		slog.Info("SMTPSender", "newValue", newValue)
+		svc.configChanged(c, "smtp_sender", prevCfg.SMTPSender, newValue)
 		prevCfg.SMTPSender = newValue
 		pd.AddMessage(routes.Success, pd.Trans("SMTP sender changed"))
 	}
 	newValue = c.PostForm("smtp_username")
 	if newValue != prevCfg.SMTPUsername {
 		slog.Info("SMTPUsername", "newValue", newValue)
+		svc.configChanged(c, "smtp_username", prevCfg.SMTPUsername, newValue)
 		prevCfg.SMTPUsername = newValue
 		pd.AddMessage(routes.Success, pd.Trans("SMTP username changed"))
 	}
 	newValue = c.PostForm("smtp_password")
 	if newValue != prevCfg.SMTPPassword {
 		slog.Info("SMTPPassword", "newValue", newValue)
+		svc.configChanged(c, "smtp_password", "***", "***")
 		prevCfg.SMTPPassword = newValue
 		pd.AddMessage(routes.Success, pd.Trans("SMTP password changed"))
 	}
-	newValue = c.PostForm("request_per_minute")
-	newValueInt, err := strconv.Atoi(newValue)
-
-	if err != nil {
-		pd.AddMessage(routes.Error, "Can't convert to integer: "+newValue)
-	} else if newValueInt != prevCfg.RequestsPerMinute {
-		slog.Info("RequestsPerMinute", "newValue", newValue)
-		prevCfg.RequestsPerMinute = newValueInt
-		pd.AddMessage(routes.Success, pd.Trans("RequestsPerMinute changed"))
-	}
 	newValue = c.PostForm("cache_parameter")
 	if newValue != prevCfg.CacheParameter {
 		slog.Info("CacheParameter", "newValue", newValue)
+		svc.configChanged(c, "cache_parameter", prevCfg.CacheParameter, newValue)
 		prevCfg.CacheParameter = newValue
 		pd.AddMessage(routes.Success, pd.Trans("Cache parameter changed"))
 	}
 	newValue = c.PostForm("cache_max_age")
-	// reuse from above
-	newValueInt, err = strconv.Atoi(newValue)
+	newValueInt, err := strconv.Atoi(newValue)
 	if err != nil {
 		pd.AddMessage(routes.Error, "Can't convert to integer: "+newValue)
 	} else if newValueInt != prevCfg.CacheMaxAge {
 		slog.Info("CacheMaxAge", "newValue", newValue)
+		svc.configChanged(c, "cache_max_age", strconv.Itoa(prevCfg.CacheMaxAge), newValue)
 		prevCfg.CacheMaxAge = newValueInt
 		pd.AddMessage(routes.Success, pd.Trans("Cache max age changed"))
 	}
 
+	for _, f := range []struct {
+		name    string
+		value   *int
+		min     int
+		message string
+	}{
+		// A threshold of 0 turns the delay or lockout off, a duration of 0 would lock out or log out everyone at once.
+		{"lockout_delay_after", &prevCfg.LockoutDelayAfter, 0, "Lockout delay threshold changed"},
+		{"lockout_max_attempts", &prevCfg.LockoutMaxAttempts, 0, "Lockout max attempts changed"},
+		{"lockout_ip_max_attempts", &prevCfg.LockoutIPMaxAttempts, 0, "Lockout IP max attempts changed"},
+		{"lockout_duration", &prevCfg.LockoutDuration, 1, "Lockout duration changed"},
+		{"session_idle_timeout", &prevCfg.SessionIdleTimeout, 1, "Session idle timeout changed"},
+		{"session_lifetime", &prevCfg.SessionLifetime, 1, "Session lifetime changed"},
+		{"remember_me_days", &prevCfg.RememberMeDays, 1, "Remember me duration changed"},
+	} {
+		newValue = c.PostForm(f.name)
+		newValueInt, err = strconv.Atoi(newValue)
+		if err != nil {
+			pd.AddMessage(routes.Error, "Can't convert to integer: "+newValue)
+		} else if newValueInt < f.min {
+			pd.AddMessage(routes.Error, fmt.Sprintf(pd.Trans("%s must be at least %d."), f.name, f.min))
+		} else if newValueInt != *f.value {
+			slog.Info(f.name, "newValue", newValue)
+			svc.configChanged(c, f.name, strconv.Itoa(*f.value), newValue)
+			*f.value = newValueInt
+			pd.AddMessage(routes.Success, pd.Trans(f.message))
+		}
+	}
+
+	newValue = c.PostForm("registration_mode")
+	if !slices.Contains(invite.Modes, newValue) {
+		pd.AddMessage(routes.Error, "Unknown registration mode: "+newValue)
+	} else if newValue != prevCfg.RegistrationMode {
+		slog.Info("RegistrationMode", "newValue", newValue)
+		svc.configChanged(c, "registration_mode", prevCfg.RegistrationMode, newValue)
+		prevCfg.RegistrationMode = newValue
+		pd.AddMessage(routes.Success, pd.Trans("Registration mode changed"))
+	}
+
+	for _, f := range []struct {
+		name    string
+		value   *string
+		message string
+	}{
+		{"registration_allowed_domains", &prevCfg.RegistrationAllowedDomains, "Allowed registration domains changed"},
+		{"registration_blocked_domains", &prevCfg.RegistrationBlockedDomains, "Blocked registration domains changed"},
+	} {
+		newValue = strings.TrimSpace(c.PostForm(f.name))
+		if newValue != *f.value {
+			slog.Info(f.name, "newValue", newValue)
+			svc.configChanged(c, f.name, *f.value, newValue)
+			*f.value = newValue
+			pd.AddMessage(routes.Success, pd.Trans(f.message))
+		}
+	}
+	// Unchecked checkboxes are not sent with the form
+	for _, f := range []struct {
+		name    string
+		value   *bool
+		message string
+	}{
+		{"registration_block_disposable", &prevCfg.RegistrationBlockDisposable, "Blocking of disposable email domains changed"},
+		{"registration_check_mx", &prevCfg.RegistrationCheckMX, "Mail server check changed"},
+	} {
+		newBool := c.PostForm(f.name) != ""
+		if newBool != *f.value {
+			slog.Info(f.name, "newValue", newBool)
+			svc.configChanged(c, f.name, strconv.FormatBool(*f.value), strconv.FormatBool(newBool))
+			*f.value = newBool
+			pd.AddMessage(routes.Success, pd.Trans(f.message))
+		}
+	}
+
+	// Rate limits take effect at once on this instance and within a minute on the others, counts which have already
+	// started keep their period
+	limiter := svc.env.GetRateLimiter()
+	for _, p := range limiter.Policies() {
+		field := "ratelimit_" + p.Name
+		newValue = c.PostForm(field)
+		newValueInt, err = strconv.Atoi(newValue)
+		if err != nil {
+			pd.AddMessage(routes.Error, "Can't convert to integer: "+newValue)
+			continue
+		}
+		period, err := time.ParseDuration(c.PostForm(field + "_period"))
+		if err != nil {
+			pd.AddMessage(routes.Error, "Can't convert to duration: "+c.PostForm(field+"_period"))
+			continue
+		}
+		if newValueInt == p.Limit && period == p.Period {
+			continue
+		}
+		err = limiter.Save(svc.env.GetDb(), p.Name, newValueInt, period)
+		if errors.Is(err, ratelimit.ErrInvalidLimit) {
+			pd.AddMessage(routes.Error, pd.Trans("Rate limits must be a positive number of requests and a positive period."))
+			continue
+		}
+		if err != nil {
+			slog.Error("ConfigRouteHandlerPost", "error", err)
+			pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+			continue
+		}
+		slog.Info(field, "limit", newValueInt, "period", period)
+		svc.configChanged(c, field, fmt.Sprintf("%d/%s", p.Limit, p.Period), fmt.Sprintf("%d/%s", newValueInt, period))
+		if p.Name == ratelimit.Forms {
+			prevCfg.RequestsPerMinute = newValueInt
+		}
+		pd.AddMessage(routes.Success, pd.Trans("Rate limit changed")+": "+p.Name)
+	}
+	pd.RateLimits = limiter.Policies()
+
 	// refresh with new state
 	// pd.Config = infra.LairInstance().GetConfig()
 
 	c.HTML(http.StatusOK, "config.gohtml", pd)
 }
+
+// configChanged records a changed configuration field in the audit log, secrets are recorded without their value
+func (svc Service) configChanged(c *gin.Context, field string, from string, to string) {
+	audit.Record(c, svc.env.GetDb(), audit.ConfigChanged, 0, fmt.Sprintf("field=%s from=%q to=%q", field, from, to))
+}
@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+)
+
+// ImpersonatePost starts a session as the user so that the admin sees the website exactly as the user does. The admin's
+// own session is kept and used again when the impersonation ends.
+func (svc Service) ImpersonatePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	user, err := svc.loadUser(c)
+	if err != nil {
+		svc.userNotFound(c, pd)
+		return
+	}
+
+	if _, impersonating := c.Get(middleware.ImpersonatorIDKey); impersonating {
+		pd.AddMessage(routes.Error, pd.Trans("You are already impersonating a user."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusBadRequest)
+		return
+	}
+	if user.ID == c.GetUint(middleware.UserIDKey) {
+		pd.AddMessage(routes.Error, pd.Trans("You can not do this to your own account."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusBadRequest)
+		return
+	}
+	if user.DeletedAt.Valid || user.IsDisabled() {
+		pd.AddMessage(routes.Error, pd.Trans("Deleted and disabled users can not be impersonated."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusBadRequest)
+		return
+	}
+
+	// Impersonating a user with permissions the admin does not have would grant the admin those permissions
+	permissions, err := rbac.ForRoles(db, user.Roles)
+	if err != nil {
+		slog.Error("ImpersonatePost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusInternalServerError)
+		return
+	}
+	for _, p := range permissions {
+		if !middleware.Can(c, p) {
+			pd.AddMessage(routes.Error, pd.Trans("Users with permissions you do not have can not be impersonated."))
+			svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusForbidden)
+			return
+		}
+	}
+
+	cookie := middleware.DefaultSessionWithOptions(c)
+	admin := models.Session{}
+	admin.Identifier, _ = cookie.Get(middleware.SessionIDKey).(string)
+	if admin.Identifier == "" {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	res := db.Where(&admin).First(&admin)
+	if res.Error != nil {
+		slog.Error("ImpersonatePost", "error", res.Error)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusInternalServerError)
+		return
+	}
+
+	ses, err := session.Impersonate(db, svc.env.GetConfig(), user, admin, c.ClientIP(), c.Request.UserAgent())
+	if err == nil {
+		cookie.Set(middleware.SessionIDKey, ses.Identifier)
+		err = cookie.Save()
+	}
+	if err != nil {
+		slog.Error("ImpersonatePost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.ImpersonationStarted, user.ID, fmt.Sprintf("session=%d", ses.ID))
+	c.Redirect(http.StatusFound, "/")
+}
+
+// StopImpersonatingPost ends the impersonation and switches back to the admin's own session
+func (svc Service) StopImpersonatingPost(c *gin.Context) {
+	db := svc.env.GetDb()
+	cookie := middleware.DefaultSessionWithOptions(c)
+
+	ses := models.Session{}
+	ses.Identifier, _ = cookie.Get(middleware.SessionIDKey).(string)
+	if ses.Identifier == "" {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	res := db.Where(&ses).First(&ses)
+	if res.Error != nil || !ses.IsImpersonation() {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+
+	err := session.Revoke(db, ses.Identifier)
+	if err != nil {
+		slog.Error("StopImpersonatingPost", "error", err)
+	}
+	audit.Record(c, db, audit.ImpersonationEnded, ses.UserID, fmt.Sprintf("session=%d", ses.ID))
+
+	// The admin's session may have expired or been revoked while impersonating, the admin has to login again then
+	admin := models.Session{Identifier: ses.ImpersonatorSession}
+	res = db.Where(&admin).First(&admin)
+	if ses.ImpersonatorSession == "" || res.Error != nil || admin.HasExpired() || admin.UserID != ses.ImpersonatorID {
+		cookie.Delete(middleware.SessionIDKey)
+		err = cookie.Save()
+		if err != nil {
+			slog.Error("StopImpersonatingPost", "error", err)
+		}
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	cookie.Set(middleware.SessionIDKey, admin.Identifier)
+	err = cookie.Save()
+	if err != nil {
+		slog.Error("StopImpersonatingPost", "error", err)
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("/admin/users/%d", ses.UserID))
+}
@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/invite"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"gorm.io/gorm"
+)
+
+// invitationsShown is the number of invitations shown on the invitations page, the newest are shown first
+const invitationsShown = 200
+
+// maxInvitesPerRequest is how many emails can be invited at once
+const maxInvitesPerRequest = 100
+
+// inviteExpiryDays are the number of days an invitation can be valid for
+var inviteExpiryDays = []int{1, 3, 7, 14, 30}
+
+// defaultInviteExpiryDays is how many days an invitation is valid for unless another choice is made
+const defaultInviteExpiryDays = 7
+
+// InvitesPageData holds the additional data needed to render the invitations page
+type InvitesPageData struct {
+	routes.PageData
+	Invitations []models.Invitation
+	Roles       []models.Role
+	Status      string
+	Statuses    []string
+	ExpiryDays  []int
+	DefaultDays int
+	// Inviters are the emails of the admins who sent the listed invitations
+	Inviters map[uint]string
+	// InviteOnly is true when registering needs an invitation
+	InviteOnly bool
+}
+
+func (svc Service) renderInvites(c *gin.Context, ipd InvitesPageData, status int) {
+	ipd.Title = ipd.Trans("Invitations")
+	ipd.Statuses = []string{models.InvitationPending, models.InvitationAccepted, models.InvitationRevoked, models.InvitationExpired}
+	ipd.ExpiryDays = inviteExpiryDays
+	ipd.DefaultDays = defaultInviteExpiryDays
+	ipd.InviteOnly = svc.env.GetConfig().RegistrationMode == invite.ModeInvite
+	db := svc.env.GetDb()
+
+	now := time.Now()
+	query := db.Preload("Role")
+	switch ipd.Status {
+	case models.InvitationPending:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case models.InvitationAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case models.InvitationRevoked:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NOT NULL")
+	case models.InvitationExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	}
+	res := query.Order("created_at DESC").Limit(invitationsShown).Find(&ipd.Invitations)
+	if res.Error == nil {
+		res = db.Order("name").Find(&ipd.Roles)
+	}
+	if res.Error == nil {
+		ipd.Inviters, res.Error = inviters(db, ipd.Invitations)
+	}
+	if res.Error != nil {
+		slog.Error("renderInvites", "error", res.Error)
+		ipd.AddMessage(routes.Error, ipd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "invites.gohtml", ipd)
+}
+
+// inviters looks up the emails of the admins who sent the invitations, deleted users included
+func inviters(db *gorm.DB, invitations []models.Invitation) (map[uint]string, error) {
+	var ids []uint
+	for _, i := range invitations {
+		ids = append(ids, i.InvitedByID)
+	}
+	found := map[uint]string{}
+	if len(ids) == 0 {
+		return found, nil
+	}
+	var users []models.User
+	res := db.Unscoped().Select("id", "email").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		found[u.ID] = u.Email
+	}
+	return found, res.Error
+}
+
+// Invites renders the sent invitations, which can be filtered by status, and the form which sends new invitations
+func (svc Service) Invites(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderInvites(c, InvitesPageData{PageData: pd, Status: c.Query("status")}, http.StatusOK)
+}
+
+// InvitesPost invites one or more emails, separated by spaces, commas or new lines, with the chosen role. Emails which
+// already have an account are skipped and a pending invitation of an email is replaced by the new one.
+func (svc Service) InvitesPost(c *gin.Context) {
+	ipd := InvitesPageData{PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)}
+	db := svc.env.GetDb()
+
+	var emails, invalid []string
+	validate := validator.New()
+	for _, e := range strings.FieldsFunc(c.PostForm("emails"), func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	}) {
+		if validate.Var(e, "required,email") != nil {
+			invalid = append(invalid, e)
+		} else if !slices.Contains(emails, e) {
+			emails = append(emails, e)
+		}
+	}
+	if len(invalid) > 0 || len(emails) == 0 || len(emails) > maxInvitesPerRequest {
+		if len(invalid) > 0 {
+			ipd.AddMessage(routes.Error, fmt.Sprintf(ipd.Trans("These email addresses are not valid: %s"), strings.Join(invalid, ", ")))
+		}
+		ipd.AddMessage(routes.Error, fmt.Sprintf(ipd.Trans("Please enter between 1 and %d email addresses."), maxInvitesPerRequest))
+		svc.renderInvites(c, ipd, http.StatusBadRequest)
+		return
+	}
+
+	days, err := strconv.Atoi(c.PostForm("expires_days"))
+	if err != nil || !slices.Contains(inviteExpiryDays, days) {
+		days = defaultInviteExpiryDays
+	}
+
+	role := models.Role{}
+	roleID, err := strconv.Atoi(c.PostForm("role_id"))
+	if err == nil {
+		role.ID = uint(roleID)
+		err = db.Where(&role).First(&role).Error
+	}
+	if err != nil {
+		ipd.AddMessage(routes.Error, ipd.Trans("The role could not be found."))
+		svc.renderInvites(c, ipd, http.StatusBadRequest)
+		return
+	}
+
+	// Inviting someone with permissions the admin does not have would let the admin grant themselves those permissions
+	err = checkGrantable(c, db, []models.Role{role})
+	if errors.Is(err, errNotGrantable) {
+		ipd.AddMessage(routes.Error, ipd.Trans("Users can not be invited to a role with permissions you do not have."))
+		svc.renderInvites(c, ipd, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		slog.Error("InvitesPost", "error", err)
+		ipd.AddMessage(routes.Error, ipd.Trans("Something went wrong, please try again."))
+		svc.renderInvites(c, ipd, http.StatusInternalServerError)
+		return
+	}
+
+	var registered []string
+	sent := 0
+	for _, e := range emails {
+		user := models.User{}
+		res := db.Where(&models.User{Email: e}).First(&user)
+		if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			slog.Error("InvitesPost", "error", res.Error)
+			ipd.AddMessage(routes.Error, ipd.Trans("Something went wrong, please try again."))
+			break
+		}
+		if res.RowsAffected > 0 {
+			registered = append(registered, e)
+			continue
+		}
+
+		token, hash, err := invite.New()
+		if err != nil {
+			slog.Error("InvitesPost", "error", err)
+			ipd.AddMessage(routes.Error, ipd.Trans("Something went wrong, please try again."))
+			break
+		}
+		invitation := models.Invitation{
+			Email:       e,
+			RoleID:      role.ID,
+			TokenHash:   hash,
+			InvitedByID: c.GetUint(middleware.UserIDKey),
+			ExpiresAt:   time.Now().Add(time.Duration(days) * 24 * time.Hour),
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&models.Invitation{}).
+				Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", e).
+				Update("revoked_at", time.Now()).Error
+			if err != nil {
+				return err
+			}
+			return tx.Create(&invitation).Error
+		})
+		if err != nil {
+			slog.Error("InvitesPost", "error", err)
+			ipd.AddMessage(routes.Error, ipd.Trans("Something went wrong, please try again."))
+			break
+		}
+
+		audit.Record(c, db, audit.InvitationCreated, invitation.ID, fmt.Sprintf("email=%q role=%q expires_days=%d", e, role.Name, days))
+		go svc.sendInvitationEmail(token, e, invitation.ExpiresAt, ipd.Trans)
+		sent++
+	}
+
+	if sent > 0 {
+		ipd.AddMessage(routes.Success, fmt.Sprintf(ipd.Trans("%d invitations have been sent."), sent))
+	}
+	if len(registered) > 0 {
+		ipd.AddMessage(routes.Error, fmt.Sprintf(ipd.Trans("These email addresses already have an account and were not invited: %s"), strings.Join(registered, ", ")))
+	}
+	svc.renderInvites(c, ipd, http.StatusOK)
+}
+
+func (svc Service) sendInvitationEmail(token string, email string, expiresAt time.Time, trans func(string) string) {
+	conf := svc.env.GetConfig()
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		slog.Error("sendInvitationEmail", "error", err)
+		return
+	}
+
+	u.Path = path.Join(u.Path, "/register")
+	u.RawQuery = url.Values{"invite": {token}}.Encode()
+
+	emailService := email2.New(conf)
+
+	emailService.Send(email, trans("You have been invited"), fmt.Sprintf(trans("You have been invited to create an account. Use the following link to register before %s.\n%s"), expiresAt.Format("2006-01-02 15:04 MST"), u.String()))
+}
+
+// InviteRevokePost revokes a pending invitation so that its link can no longer be used to register
+func (svc Service) InviteRevokePost(c *gin.Context) {
+	ipd := InvitesPageData{PageData: routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)}
+	db := svc.env.GetDb()
+
+	invitation := models.Invitation{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		err = invite.ErrInvalid
+	} else {
+		invitation.ID = uint(id)
+		err = db.Where(&invitation).First(&invitation).Error
+	}
+	if err == nil {
+		err = invite.Revoke(db, invitation)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, invite.ErrInvalid) {
+		ipd.AddMessage(routes.Error, ipd.Trans("The invitation is no longer pending."))
+		svc.renderInvites(c, ipd, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("InviteRevokePost", "error", err)
+		ipd.AddMessage(routes.Error, ipd.Trans("Something went wrong, please try again."))
+		svc.renderInvites(c, ipd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.InvitationRevoked, invitation.ID, fmt.Sprintf("email=%q", invitation.Email))
+	ipd.AddMessage(routes.Success, ipd.Trans("The invitation has been revoked."))
+	svc.renderInvites(c, ipd, http.StatusOK)
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/lockout"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// LockoutUnlockPost removes the lock of the email address or IP address of a lockout event
+func (svc Service) LockoutUnlockPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	event := models.LockoutEvent{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err == nil {
+		event.ID = uint(id)
+		err = db.Where(&event).First(&event).Error
+	}
+	if err != nil {
+		pd.AddMessage(routes.Error, pd.Trans("The lockout could not be found."))
+		svc.renderAdmin(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	admin := models.User{}
+	admin.ID = c.GetUint(middleware.UserIDKey)
+	db.Where(&admin).First(&admin)
+
+	err = lockout.Unlock(db, event.LockKey, admin.Email)
+	if err != nil {
+		slog.Error("LockoutUnlockPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderAdmin(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	// The subject is the locked user, or no one when an IP address was locked
+	audit.Record(c, db, audit.LockoutRemoved, event.UserID, fmt.Sprintf("key=%q", event.LockKey))
+	pd.AddMessage(routes.Success, pd.Trans("The lockout has been removed."))
+	svc.renderAdmin(c, pd, http.StatusOK)
+}
@@ -0,0 +1,381 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+	"gorm.io/gorm"
+)
+
+// RolesPageData holds the additional data needed to render the role list
+type RolesPageData struct {
+	routes.PageData
+	Roles       []RoleSummary
+	Permissions []models.Permission
+}
+
+// RoleSummary is a role and the number of users who have it
+type RoleSummary struct {
+	models.Role
+	Members int64
+	BuiltIn bool
+}
+
+// RolePageData holds the additional data needed to render the page of a single role
+type RolePageData struct {
+	routes.PageData
+	Role        models.Role
+	Permissions []models.Permission
+	Members     []models.User
+	BuiltIn     bool
+}
+
+// HasPermission returns true if the role is granted the permission
+func (rpd RolePageData) HasPermission(name string) bool {
+	for _, p := range rpd.Role.Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles renders the list of roles and a form to create a new role
+func (svc Service) Roles(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.renderRoles(c, RolesPageData{PageData: pd}, http.StatusOK)
+}
+
+func (svc Service) renderRoles(c *gin.Context, rpd RolesPageData, status int) {
+	rpd.Title = rpd.Trans("Roles")
+	db := svc.env.GetDb()
+
+	var roles []models.Role
+	res := db.Order("name").Find(&roles)
+	if res.Error == nil {
+		res = db.Order("name").Find(&rpd.Permissions)
+	}
+	counts := map[uint]int64{}
+	if res.Error == nil {
+		var rows []struct {
+			RoleID uint
+			Count  int64
+		}
+		res = db.Table("user_roles").
+			Select("user_roles.role_id, COUNT(*) AS count").
+			Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+			Group("user_roles.role_id").
+			Scan(&rows)
+		for _, row := range rows {
+			counts[row.RoleID] = row.Count
+		}
+	}
+	if res.Error != nil {
+		slog.Error("renderRoles", "error", res.Error)
+		rpd.AddMessage(routes.Error, rpd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+
+	rpd.Roles = nil
+	for _, r := range roles {
+		rpd.Roles = append(rpd.Roles, RoleSummary{Role: r, Members: counts[r.ID], BuiltIn: rbac.IsBuiltIn(r.Name)})
+	}
+	c.HTML(status, "roles.gohtml", rpd)
+}
+
+// RoleCreatePost creates a role with the name, description and permissions of the form
+func (svc Service) RoleCreatePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	role := models.Role{}
+	err := checkPermissions(c, role, c.PostFormArray("permissions"))
+	if err == nil {
+		_, err = rbac.SaveRole(db, &role, c.PostForm("name"), c.PostForm("description"), c.PostFormArray("permissions"))
+	}
+	if err != nil {
+		pd.AddMessage(routes.Error, roleError(pd, err))
+		svc.renderRoles(c, RolesPageData{PageData: pd}, roleErrorStatus(err))
+		return
+	}
+
+	audit.Record(c, db, audit.RoleCreated, role.ID, fmt.Sprintf("name=%q", role.Name))
+	c.Redirect(http.StatusFound, fmt.Sprintf("/admin/roles/%d", role.ID))
+}
+
+// Role renders a role, its permissions and its members
+func (svc Service) Role(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	role, err := svc.loadRole(c)
+	if err != nil {
+		svc.roleNotFound(c, pd)
+		return
+	}
+	svc.renderRole(c, RolePageData{PageData: pd, Role: role}, http.StatusOK)
+}
+
+func (svc Service) renderRole(c *gin.Context, rpd RolePageData, status int) {
+	rpd.Title = rpd.Role.Name
+	rpd.BuiltIn = rbac.IsBuiltIn(rpd.Role.Name)
+	db := svc.env.GetDb()
+	res := db.Order("name").Find(&rpd.Permissions)
+	if res.Error == nil {
+		res = db.Where("id IN (?)", db.Table("user_roles").Select("user_id").Where("role_id = ?", rpd.Role.ID)).
+			Order("email").
+			Find(&rpd.Members)
+	}
+	if res.Error != nil {
+		slog.Error("renderRole", "error", res.Error)
+		rpd.AddMessage(routes.Error, rpd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "role.gohtml", rpd)
+}
+
+func (svc Service) roleNotFound(c *gin.Context, pd routes.PageData) {
+	pd.AddMessage(routes.Error, pd.Trans("The role could not be found."))
+	svc.renderRoles(c, RolesPageData{PageData: pd}, http.StatusNotFound)
+}
+
+// loadRole loads the role of the id in the path with its permissions
+func (svc Service) loadRole(c *gin.Context) (models.Role, error) {
+	role := models.Role{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return role, err
+	}
+	role.ID = uint(id)
+	err = svc.env.GetDb().Preload("Permissions").Where(&role).First(&role).Error
+	return role, err
+}
+
+// RoleUpdatePost changes the name, description and permissions of a role
+func (svc Service) RoleUpdatePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	role, err := svc.loadRole(c)
+	if err != nil {
+		svc.roleNotFound(c, pd)
+		return
+	}
+
+	before := role.Name
+	err = checkPermissions(c, role, c.PostFormArray("permissions"))
+	if err != nil {
+		pd.AddMessage(routes.Error, roleError(pd, err))
+		svc.renderRole(c, RolePageData{PageData: pd, Role: role}, roleErrorStatus(err))
+		return
+	}
+	members, err := rbac.SaveRole(db, &role, c.PostForm("name"), c.PostForm("description"), c.PostFormArray("permissions"))
+	if err != nil {
+		// The role is loaded again as SaveRole may have changed it before the error
+		if reloaded, loadErr := svc.loadRole(c); loadErr == nil {
+			role = reloaded
+		}
+		pd.AddMessage(routes.Error, roleError(pd, err))
+		svc.renderRole(c, RolePageData{PageData: pd, Role: role}, roleErrorStatus(err))
+		return
+	}
+	refreshRoles(db, members)
+
+	audit.Record(c, db, audit.RoleUpdated, role.ID, fmt.Sprintf("from=%q to=%q permissions=%q", before, role.Name, strings.Join(c.PostFormArray("permissions"), " ")))
+	role, _ = svc.loadRole(c)
+	pd.AddMessage(routes.Success, pd.Trans("The role has been updated."))
+	svc.renderRole(c, RolePageData{PageData: pd, Role: role}, http.StatusOK)
+}
+
+// RoleDeletePost deletes a role which is not built in and removes it from its members
+func (svc Service) RoleDeletePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	role, err := svc.loadRole(c)
+	if err != nil {
+		svc.roleNotFound(c, pd)
+		return
+	}
+
+	members, err := rbac.DeleteRole(db, role)
+	if err != nil {
+		pd.AddMessage(routes.Error, roleError(pd, err))
+		svc.renderRole(c, RolePageData{PageData: pd, Role: role}, roleErrorStatus(err))
+		return
+	}
+	refreshRoles(db, members)
+
+	audit.Record(c, db, audit.RoleDeleted, role.ID, fmt.Sprintf("name=%q members=%d", role.Name, len(members)))
+	pd.AddMessage(routes.Success, pd.Trans("The role has been deleted."))
+	svc.renderRoles(c, RolesPageData{PageData: pd}, http.StatusOK)
+}
+
+// RoleMembersAddPost gives the role to the users with the email addresses entered in the form
+func (svc Service) RoleMembersAddPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	role, err := svc.loadRole(c)
+	if err != nil {
+		svc.roleNotFound(c, pd)
+		return
+	}
+	err = checkGrantable(c, db, []models.Role{role})
+	if err != nil {
+		pd.AddMessage(routes.Error, roleError(pd, err))
+		svc.renderRole(c, RolePageData{PageData: pd, Role: role}, roleErrorStatus(err))
+		return
+	}
+
+	emails := strings.FieldsFunc(strings.ToLower(c.PostForm("emails")), func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	var ids []uint
+	if len(emails) > 0 {
+		res := db.Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("id", &ids)
+		if res.Error != nil {
+			err = res.Error
+		}
+	}
+	var added []uint
+	if err == nil {
+		added, err = rbac.AddMembers(db, role, ids)
+	}
+	if err != nil {
+		slog.Error("RoleMembersAddPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderRole(c, RolePageData{PageData: pd, Role: role}, http.StatusInternalServerError)
+		return
+	}
+	refreshRoles(db, added)
+
+	if len(added) > 0 {
+		audit.Record(c, db, audit.RoleMembersAdded, role.ID, fmt.Sprintf("name=%q users=%v", role.Name, added))
+	}
+	pd.AddMessage(routes.Success, fmt.Sprintf(pd.Trans("%d users were given the role."), len(added)))
+	if missing := len(emails) - len(ids); missing > 0 {
+		pd.AddMessage(routes.Error, fmt.Sprintf(pd.Trans("%d email addresses did not match a user."), missing))
+	}
+	svc.renderRole(c, RolePageData{PageData: pd, Role: role}, http.StatusOK)
+}
+
+// RoleMembersRemovePost takes the role from the users selected in the form
+func (svc Service) RoleMembersRemovePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	role, err := svc.loadRole(c)
+	if err != nil {
+		svc.roleNotFound(c, pd)
+		return
+	}
+
+	removed, err := rbac.RemoveMembers(db, role, formIDs(c, "user_ids"))
+	if err != nil {
+		pd.AddMessage(routes.Error, roleError(pd, err))
+		svc.renderRole(c, RolePageData{PageData: pd, Role: role}, roleErrorStatus(err))
+		return
+	}
+	refreshRoles(db, removed)
+
+	if len(removed) > 0 {
+		audit.Record(c, db, audit.RoleMembersRemoved, role.ID, fmt.Sprintf("name=%q users=%v", role.Name, removed))
+	}
+	pd.AddMessage(routes.Success, fmt.Sprintf(pd.Trans("%d users were removed from the role."), len(removed)))
+	svc.renderRole(c, RolePageData{PageData: pd, Role: role}, http.StatusOK)
+}
+
+// formIDs returns the posted values of the field which are valid ids
+func formIDs(c *gin.Context, field string) []uint {
+	var ids []uint
+	for _, v := range c.PostFormArray(field) {
+		if id, err := strconv.Atoi(v); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// refreshRoles updates the sessions of users whose roles or permissions have changed
+func refreshRoles(db *gorm.DB, userIDs []uint) {
+	for _, id := range userIDs {
+		err := session.RefreshRoles(db, id)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("refreshRoles", "error", err, "user", id)
+		}
+	}
+}
+
+// errNotGrantable is returned when an admin would grant permissions which they do not have themselves
+var errNotGrantable = errors.New("permissions not held by the admin")
+
+// checkGrantable returns errNotGrantable if the roles grant a permission which the current user does not have. Giving
+// such a role would let an admin grant themselves, or someone they control, more than they are allowed to do.
+func checkGrantable(c *gin.Context, db *gorm.DB, roles []models.Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	permissions, err := rbac.ForRoles(db, roles)
+	if err != nil {
+		return err
+	}
+	for _, p := range permissions {
+		if !middleware.Can(c, p) {
+			return errNotGrantable
+		}
+	}
+	return nil
+}
+
+// checkPermissions returns errNotGrantable if the role would gain a permission which the current user does not have.
+// Permissions the role already has are not checked so that an admin can still edit a role which has permissions they
+// lack, as long as they do not add any.
+func checkPermissions(c *gin.Context, role models.Role, permissions []string) error {
+	for _, p := range rbac.Permissions {
+		if !slices.Contains(permissions, p.Name) || middleware.Can(c, p.Name) {
+			continue
+		}
+		if !slices.ContainsFunc(role.Permissions, func(granted models.Permission) bool { return granted.Name == p.Name }) {
+			return errNotGrantable
+		}
+	}
+	return nil
+}
+
+// roleError returns the message shown for an error returned by the rbac package
+func roleError(pd routes.PageData, err error) string {
+	switch {
+	case errors.Is(err, rbac.ErrRoleName):
+		return pd.Trans("The role needs a name which is not used by another role.")
+	case errors.Is(err, rbac.ErrBuiltInRole):
+		return pd.Trans("The admin and user roles can not be renamed or deleted.")
+	case errors.Is(err, rbac.ErrLastAdmin):
+		return pd.Trans("This would leave no user who can manage roles.")
+	case errors.Is(err, errNotGrantable):
+		return pd.Trans("You can not grant permissions you do not have.")
+	}
+	slog.Error("roleError", "error", err)
+	return pd.Trans("Something went wrong, please try again.")
+}
+
+// roleErrorStatus returns the HTTP status for an error returned by the rbac package
+func roleErrorStatus(err error) int {
+	if errors.Is(err, rbac.ErrRoleName) || errors.Is(err, rbac.ErrBuiltInRole) || errors.Is(err, rbac.ErrLastAdmin) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, errNotGrantable) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,230 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
+)
+
+// RoleJSON is how a role is represented by the role API
+type RoleJSON struct {
+	ID          uint         `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	BuiltIn     bool         `json:"built_in"`
+	Permissions []string     `json:"permissions"`
+	Members     []MemberJSON `json:"members,omitempty"`
+}
+
+// MemberJSON is a user who has a role
+type MemberJSON struct {
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+}
+
+// roleRequest is the body of requests which create or change a role
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// membersRequest is the body of requests which add or remove members
+type membersRequest struct {
+	UserIDs []uint `json:"user_ids"`
+}
+
+func roleJSON(role models.Role) RoleJSON {
+	r := RoleJSON{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		BuiltIn:     rbac.IsBuiltIn(role.Name),
+		Permissions: []string{},
+	}
+	for _, p := range role.Permissions {
+		r.Permissions = append(r.Permissions, p.Name)
+	}
+	return r
+}
+
+// roleAPIError writes the JSON error response for an error returned by the rbac package
+func roleAPIError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, rbac.ErrRoleName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_name"})
+	case errors.Is(err, rbac.ErrBuiltInRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "built_in_role"})
+	case errors.Is(err, rbac.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": "last_admin"})
+	case errors.Is(err, errNotGrantable):
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_permission"})
+	default:
+		slog.Error("roleAPIError", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+	}
+}
+
+// APIRoles returns all roles with their permissions
+func (svc Service) APIRoles(c *gin.Context) {
+	var roles []models.Role
+	res := svc.env.GetDb().Preload("Permissions").Order("name").Find(&roles)
+	if res.Error != nil {
+		roleAPIError(c, res.Error)
+		return
+	}
+	list := []RoleJSON{}
+	for _, r := range roles {
+		list = append(list, roleJSON(r))
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// APIRole returns a role with its permissions and members
+func (svc Service) APIRole(c *gin.Context) {
+	role, err := svc.loadRole(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found"})
+		return
+	}
+	var members []models.User
+	db := svc.env.GetDb()
+	res := db.Where("id IN (?)", db.Table("user_roles").Select("user_id").Where("role_id = ?", role.ID)).
+		Order("email").
+		Find(&members)
+	if res.Error != nil {
+		roleAPIError(c, res.Error)
+		return
+	}
+	r := roleJSON(role)
+	for _, u := range members {
+		r.Members = append(r.Members, MemberJSON{ID: u.ID, Email: u.Email})
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// APIRoleCreate creates a role
+func (svc Service) APIRoleCreate(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	db := svc.env.GetDb()
+	role := models.Role{}
+	err := checkPermissions(c, role, req.Permissions)
+	if err == nil {
+		_, err = rbac.SaveRole(db, &role, req.Name, req.Description, req.Permissions)
+	}
+	if err != nil {
+		roleAPIError(c, err)
+		return
+	}
+	audit.Record(c, db, audit.RoleCreated, role.ID, fmt.Sprintf("name=%q", role.Name))
+	db.Preload("Permissions").First(&role, role.ID)
+	c.JSON(http.StatusCreated, roleJSON(role))
+}
+
+// APIRoleUpdate changes the name, description and permissions of a role
+func (svc Service) APIRoleUpdate(c *gin.Context) {
+	role, err := svc.loadRole(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found"})
+		return
+	}
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	db := svc.env.GetDb()
+	before := role.Name
+	err = checkPermissions(c, role, req.Permissions)
+	if err != nil {
+		roleAPIError(c, err)
+		return
+	}
+	members, err := rbac.SaveRole(db, &role, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		roleAPIError(c, err)
+		return
+	}
+	refreshRoles(db, members)
+	audit.Record(c, db, audit.RoleUpdated, role.ID, fmt.Sprintf("from=%q to=%q permissions=%q", before, role.Name, strings.Join(req.Permissions, " ")))
+	role, _ = svc.loadRole(c)
+	c.JSON(http.StatusOK, roleJSON(role))
+}
+
+// APIRoleDelete deletes a role which is not built in
+func (svc Service) APIRoleDelete(c *gin.Context) {
+	role, err := svc.loadRole(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found"})
+		return
+	}
+	db := svc.env.GetDb()
+	members, err := rbac.DeleteRole(db, role)
+	if err != nil {
+		roleAPIError(c, err)
+		return
+	}
+	refreshRoles(db, members)
+	audit.Record(c, db, audit.RoleDeleted, role.ID, fmt.Sprintf("name=%q members=%d", role.Name, len(members)))
+	c.Status(http.StatusNoContent)
+}
+
+// APIRoleMembersAdd gives the role to the users in the request
+func (svc Service) APIRoleMembersAdd(c *gin.Context) {
+	svc.apiRoleMembers(c, true)
+}
+
+// APIRoleMembersRemove takes the role from the users in the request
+func (svc Service) APIRoleMembersRemove(c *gin.Context) {
+	svc.apiRoleMembers(c, false)
+}
+
+func (svc Service) apiRoleMembers(c *gin.Context, add bool) {
+	role, err := svc.loadRole(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found"})
+		return
+	}
+	var req membersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	db := svc.env.GetDb()
+	action := audit.RoleMembersAdded
+	var changed []uint
+	if add {
+		err = checkGrantable(c, db, []models.Role{role})
+		if err != nil {
+			roleAPIError(c, err)
+			return
+		}
+		changed, err = rbac.AddMembers(db, role, req.UserIDs)
+	} else {
+		action = audit.RoleMembersRemoved
+		changed, err = rbac.RemoveMembers(db, role, req.UserIDs)
+	}
+	if err != nil {
+		roleAPIError(c, err)
+		return
+	}
+	refreshRoles(db, changed)
+	if len(changed) > 0 {
+		audit.Record(c, db, action, role.ID, fmt.Sprintf("name=%q users=%v", role.Name, changed))
+	}
+	if changed == nil {
+		changed = []uint{}
+	}
+	c.JSON(http.StatusOK, gin.H{"user_ids": changed})
+}
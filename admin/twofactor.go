@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/twofactor"
+)
+
+// TwoFactorResetPost disables two-factor authentication for a user, used when a user has lost both their authenticator and recovery codes
+func (svc Service) TwoFactorResetPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+
+	db := svc.env.GetDb()
+
+	email := c.PostForm("email")
+	user := models.User{Email: email}
+	res := db.Where(&user).First(&user)
+	if res.Error != nil || email == "" {
+		pd.AddMessage(routes.Error, pd.Trans("No user with that email address could be found."))
+		svc.renderAdmin(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	err := twofactor.Reset(db, &user)
+	if err != nil {
+		slog.Error("TwoFactorResetPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderAdmin(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, audit.UserTwoFactorReset, user.ID, "")
+	pd.AddMessage(routes.Success, pd.Trans("Two-factor authentication has been reset for the user."))
+	svc.renderAdmin(c, pd, http.StatusOK)
+}
@@ -0,0 +1,478 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/apitoken"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passwords"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/session"
+	"github.com/uberswe/golang-base-project/ulid"
+	"gorm.io/gorm"
+)
+
+// usersPerPage is the number of users shown on each page of the user list
+const usersPerPage = 25
+
+// forcedResetLifetime is how long the link emailed after an admin forces a password reset can be used
+const forcedResetLifetime = 24 * time.Hour
+
+// dateLayout is the format of the date filters of the user list
+const dateLayout = "2006-01-02"
+
+// The states which the user list can be filtered by
+const (
+	StateActive   = "active"
+	StateInactive = "inactive"
+	StateDisabled = "disabled"
+	StateDeleted  = "deleted"
+)
+
+// UserFilter holds the search and filters of the user list
+type UserFilter struct {
+	Query string
+	Role  string
+	State string
+	From  string
+	To    string
+}
+
+// UsersPageData holds the additional data needed to render the user list
+type UsersPageData struct {
+	routes.PageData
+	Filter  UserFilter
+	Users   []models.User
+	Roles   []models.Role
+	States  []string
+	Total   int64
+	Page    int
+	PrevURL string
+	NextURL string
+}
+
+// UserPageData holds the additional data needed to render the page of a single user
+type UserPageData struct {
+	routes.PageData
+	User  models.User
+	Roles []models.Role
+	// Self is true when the admin is viewing their own account, some actions are not available then
+	Self bool
+}
+
+// HasRole returns true if the user has the role with the given id
+func (upd UserPageData) HasRole(id uint) bool {
+	for _, r := range upd.User.Roles {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Users renders the list of users which can be searched by email and filtered by role, state and creation date
+func (svc Service) Users(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Users")
+	db := svc.env.GetDb()
+
+	upd := UsersPageData{
+		PageData: pd,
+		Filter: UserFilter{
+			Query: strings.TrimSpace(c.Query("q")),
+			Role:  c.Query("role"),
+			State: c.Query("state"),
+			From:  c.Query("from"),
+			To:    c.Query("to"),
+		},
+		States: []string{StateActive, StateInactive, StateDisabled, StateDeleted},
+		Page:   1,
+	}
+	if i, err := strconv.Atoi(c.Query("page")); err == nil && i > 1 {
+		upd.Page = i
+	}
+
+	query := db.Model(&models.User{})
+	if upd.Filter.Query != "" {
+		query = query.Where("LOWER(email) LIKE ?", "%"+strings.ToLower(upd.Filter.Query)+"%")
+	}
+	if upd.Filter.Role != "" {
+		query = query.Where("id IN (?)", db.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ?", upd.Filter.Role))
+	}
+	switch upd.Filter.State {
+	case StateActive:
+		query = query.Where("activated_at IS NOT NULL AND disabled_at IS NULL")
+	case StateInactive:
+		query = query.Where("activated_at IS NULL")
+	case StateDisabled:
+		query = query.Where("disabled_at IS NOT NULL")
+	case StateDeleted:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if from, err := time.ParseInLocation(dateLayout, upd.Filter.From, time.Local); err == nil {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, err := time.ParseInLocation(dateLayout, upd.Filter.To, time.Local); err == nil {
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	status := http.StatusOK
+	res := query.Count(&upd.Total)
+	if res.Error == nil {
+		res = query.Preload("Roles").
+			Order("created_at DESC").
+			Limit(usersPerPage).
+			Offset(usersPerPage * (upd.Page - 1)).
+			Find(&upd.Users)
+	}
+	if res.Error == nil {
+		res = db.Order("name").Find(&upd.Roles)
+	}
+	if res.Error != nil {
+		slog.Error("Users", "error", res.Error)
+		upd.AddMessage(routes.Error, upd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+
+	if upd.Page > 1 {
+		upd.PrevURL = upd.Filter.url(upd.Page - 1)
+	}
+	if int64(upd.Page*usersPerPage) < upd.Total {
+		upd.NextURL = upd.Filter.url(upd.Page + 1)
+	}
+
+	c.HTML(status, "users.gohtml", upd)
+}
+
+// url returns the address of the given page of the user list with the same filters
+func (f UserFilter) url(page int) string {
+	v := url.Values{}
+	for key, value := range map[string]string{"q": f.Query, "role": f.Role, "state": f.State, "from": f.From, "to": f.To} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	v.Set("page", strconv.Itoa(page))
+	return "/admin/users?" + v.Encode()
+}
+
+// User renders the details of a single user and the actions which can be taken
+func (svc Service) User(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	user, err := svc.loadUser(c)
+	if err != nil {
+		svc.userNotFound(c, pd)
+		return
+	}
+	svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusOK)
+}
+
+func (svc Service) renderUser(c *gin.Context, upd UserPageData, status int) {
+	upd.Title = upd.User.Email
+	upd.Self = upd.User.ID == c.GetUint(middleware.UserIDKey)
+	res := svc.env.GetDb().Order("name").Find(&upd.Roles)
+	if res.Error != nil {
+		slog.Error("renderUser", "error", res.Error)
+		upd.AddMessage(routes.Error, upd.Trans("Something went wrong, please try again."))
+		status = http.StatusInternalServerError
+	}
+	c.HTML(status, "user.gohtml", upd)
+}
+
+func (svc Service) userNotFound(c *gin.Context, pd routes.PageData) {
+	pd.Title = pd.Trans("Users")
+	pd.AddMessage(routes.Error, pd.Trans("The user could not be found."))
+	c.HTML(http.StatusNotFound, "users.gohtml", UsersPageData{PageData: pd})
+}
+
+// loadUser loads the user of the id in the path including soft deleted users
+func (svc Service) loadUser(c *gin.Context) (models.User, error) {
+	user := models.User{}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return user, err
+	}
+	user.ID = uint(id)
+	err = svc.env.GetDb().Unscoped().Preload("Roles").Where(&user).First(&user).Error
+	return user, err
+}
+
+// userAction loads the user of the request, runs the action and renders the user page with the resulting message.
+// The action returns the message shown on success and the details which are recorded in the audit log.
+func (svc Service) userAction(c *gin.Context, action string, run func(db *gorm.DB, user *models.User) (string, string, error)) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	user, err := svc.loadUser(c)
+	if err != nil {
+		svc.userNotFound(c, pd)
+		return
+	}
+
+	// Users with permissions the admin does not have can not be changed, as when impersonating, so that an admin can
+	// not remove the roles of, disable or take over an account which can do more than they can
+	var message, details string
+	err = checkGrantable(c, db, user.Roles)
+	if err == nil {
+		message, details, err = run(db, &user)
+	}
+	if err != nil {
+		if errors.Is(err, errSelf) {
+			pd.AddMessage(routes.Error, pd.Trans("You can not do this to your own account."))
+			svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, rbac.ErrLastAdmin) {
+			pd.AddMessage(routes.Error, pd.Trans("This would leave no user who can manage roles."))
+			svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errEmailTaken) {
+			pd.AddMessage(routes.Error, pd.Trans("The email of the user is now used by another account and the user can not be restored."))
+			svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errNotGrantable) {
+			pd.AddMessage(routes.Error, pd.Trans("You can not change users or give roles with permissions you do not have."))
+			svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusForbidden)
+			return
+		}
+		slog.Error("userAction", "error", err, "action", action, "user", user.ID)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusInternalServerError)
+		return
+	}
+
+	audit.Record(c, db, action, user.ID, details)
+
+	// The user is loaded again so that the page shows the result of the action
+	if reloaded, err := svc.loadUser(c); err == nil {
+		user = reloaded
+	}
+	pd.AddMessage(routes.Success, pd.Trans(message))
+	svc.renderUser(c, UserPageData{PageData: pd, User: user}, http.StatusOK)
+}
+
+// errSelf is returned by actions which an admin can not take on their own account
+var errSelf = errors.New("action not allowed on own account")
+
+// errEmailTaken is returned when a deleted user can not be restored as another account has registered with the email
+var errEmailTaken = errors.New("email used by another account")
+
+// UserRolesPost replaces the roles of the user with the roles selected in the form
+func (svc Service) UserRolesPost(c *gin.Context) {
+	svc.userAction(c, audit.UserRolesChanged, func(db *gorm.DB, user *models.User) (string, string, error) {
+		var roles []models.Role
+		ids := formIDs(c, "role_ids")
+		if len(ids) > 0 {
+			res := db.Where("id IN ?", ids).Find(&roles)
+			if res.Error != nil {
+				return "", "", res.Error
+			}
+		}
+
+		var added []models.Role
+		for _, r := range roles {
+			if !hasRole(user.Roles, r.ID) {
+				added = append(added, r)
+			}
+		}
+		err := checkGrantable(c, db, added)
+		if err != nil {
+			return "", "", err
+		}
+
+		before := roleNames(user.Roles)
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(user).Association("Roles").Replace(roles)
+			if err != nil {
+				return err
+			}
+			return rbac.EnsureAdmin(tx)
+		})
+		if err != nil {
+			return "", "", err
+		}
+		err = session.RefreshRoles(db, user.ID)
+		if err != nil {
+			return "", "", err
+		}
+		return "The roles of the user have been updated.", fmt.Sprintf("from=%q to=%q", before, roleNames(roles)), nil
+	})
+}
+
+func hasRole(roles []models.Role, id uint) bool {
+	for _, r := range roles {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func roleNames(roles []models.Role) string {
+	var names []string
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// UserActivatePost activates the account of a user who has not used their activation email
+func (svc Service) UserActivatePost(c *gin.Context) {
+	svc.userAction(c, audit.UserActivated, func(db *gorm.DB, user *models.User) (string, string, error) {
+		if user.ActivatedAt != nil {
+			return "The user is already activated.", "already activated", nil
+		}
+		now := time.Now()
+		res := db.Model(user).Update("activated_at", &now)
+		if res.Error != nil {
+			return "", "", res.Error
+		}
+		res = db.Where("type = ? AND model_type = ? AND model_id = ?", models.TokenUserActivation, "User", user.ID).Delete(&models.Token{})
+		return "The user has been activated.", "", res.Error
+	})
+}
+
+// UserResetPasswordPost replaces the password of the user with a random one, logs the user out everywhere and emails
+// a link which the user can use to choose a new password
+func (svc Service) UserResetPasswordPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	svc.userAction(c, audit.UserPasswordResetForced, func(db *gorm.DB, user *models.User) (string, string, error) {
+		hash, err := passwords.Hash(svc.env.GetConfig(), ulid.Generate()+ulid.Generate())
+		if err != nil {
+			return "", "", err
+		}
+		res := db.Model(user).Update("password", hash)
+		if res.Error != nil {
+			return "", "", res.Error
+		}
+		_, err = session.RevokeAll(db, user.ID, "")
+		if err == nil {
+			err = apitoken.RevokeAll(db, user.ID)
+		}
+		if err != nil {
+			return "", "", err
+		}
+
+		token := models.Token{
+			Value:     ulid.Generate(),
+			Type:      models.TokenPasswordReset,
+			ModelID:   int(user.ID),
+			ModelType: "User",
+			ExpiresAt: time.Now().Add(forcedResetLifetime),
+		}
+		res = db.Save(&token)
+		if res.Error != nil {
+			return "", "", res.Error
+		}
+		go svc.sendForcedResetEmail(token.Value, user.Email, pd.Trans)
+		return "The password has been reset and the user has been emailed a link to choose a new one.", "", nil
+	})
+}
+
+func (svc Service) sendForcedResetEmail(token string, email string, trans func(string) string) {
+	conf := svc.env.GetConfig()
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		slog.Error("sendForcedResetEmail", "error", err)
+		return
+	}
+
+	u.Path = path.Join(u.Path, "/user/password/reset/", token)
+
+	emailService := email2.New(conf)
+
+	emailService.Send(email, trans("Password Reset"), fmt.Sprintf(trans("An administrator has reset the password of your account. Use the following link to choose a new password, the link expires in 24 hours.\n%s"), u.String()))
+}
+
+// UserDisablePost disables the account so that the user can not log in and ends all sessions of the user
+func (svc Service) UserDisablePost(c *gin.Context) {
+	svc.userAction(c, audit.UserDisabled, func(db *gorm.DB, user *models.User) (string, string, error) {
+		if user.ID == c.GetUint(middleware.UserIDKey) {
+			return "", "", errSelf
+		}
+		now := time.Now()
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Unscoped().Model(user).Update("disabled_at", &now)
+			if res.Error != nil {
+				return res.Error
+			}
+			return rbac.EnsureAdmin(tx)
+		})
+		if err != nil {
+			return "", "", err
+		}
+		_, err = session.RevokeAll(db, user.ID, "")
+		if err == nil {
+			err = apitoken.RevokeAll(db, user.ID)
+		}
+		return "The user has been disabled.", "", err
+	})
+}
+
+// UserEnablePost allows a disabled user to log in again
+func (svc Service) UserEnablePost(c *gin.Context) {
+	svc.userAction(c, audit.UserEnabled, func(db *gorm.DB, user *models.User) (string, string, error) {
+		res := db.Unscoped().Model(user).Update("disabled_at", nil)
+		return "The user has been enabled.", "", res.Error
+	})
+}
+
+// UserDeletePost soft deletes the user, the user can be restored from the user list
+func (svc Service) UserDeletePost(c *gin.Context) {
+	svc.userAction(c, audit.UserDeleted, func(db *gorm.DB, user *models.User) (string, string, error) {
+		if user.ID == c.GetUint(middleware.UserIDKey) {
+			return "", "", errSelf
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Delete(user)
+			if res.Error != nil {
+				return res.Error
+			}
+			return rbac.EnsureAdmin(tx)
+		})
+		if err != nil {
+			return "", "", err
+		}
+		_, err = session.RevokeAll(db, user.ID, "")
+		if err == nil {
+			err = apitoken.RevokeAll(db, user.ID)
+		}
+		return "The user has been deleted.", "", err
+	})
+}
+
+// UserRestorePost restores a soft deleted user
+func (svc Service) UserRestorePost(c *gin.Context) {
+	svc.userAction(c, audit.UserRestored, func(db *gorm.DB, user *models.User) (string, string, error) {
+		// Someone may have registered with the email after the user was deleted, emails have to stay unique
+		var count int64
+		res := db.Model(&models.User{}).Where(&models.User{Email: user.Email}).Where("id <> ?", user.ID).Count(&count)
+		if res.Error != nil {
+			return "", "", res.Error
+		}
+		if count > 0 {
+			return "", "", errEmailTaken
+		}
+		// A user restored after the grace period has no sessions or tokens left and is purged again if deleted again
+		res = db.Unscoped().Model(user).Updates(map[string]interface{}{"deleted_at": nil, "purged_at": nil})
+		return "The user has been restored.", "", res.Error
+	})
+}
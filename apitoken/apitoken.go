@@ -0,0 +1,84 @@
+// Package apitoken creates and checks personal access tokens which let users call the application from scripts with an
+// Authorization: Bearer header instead of logging in
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// Prefix starts every token so that tokens can be told apart from other bearer tokens and found by secret scanners
+const Prefix = "gbp_"
+
+const (
+	// ScopeRead allows requests which do not change anything, such as GET requests
+	ScopeRead = "read"
+	// ScopeWrite allows requests which change data, such as POST requests
+	ScopeWrite = "write"
+	// ScopeAdmin keeps the admin role and the permissions of the user, without it requests made with the token have no
+	// permissions
+	ScopeAdmin = "admin"
+)
+
+// Scopes are all the scopes a token can be given
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// New returns a new token, which is only shown to the user once, and the hash which is stored
+func New() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	token := Prefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, Hash(token), nil
+}
+
+// Hash returns the hash of a token which is used to look it up
+func Hash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Allows returns true if a token with the scopes can make a request with the method
+func Allows(scopes []string, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return slices.Contains(scopes, ScopeRead) || slices.Contains(scopes, ScopeWrite)
+	}
+	return slices.Contains(scopes, ScopeWrite)
+}
+
+// Role returns the roles a request made with a token has, which are the roles of the user without admin unless the
+// token has the admin scope
+func Role(roles string, scopes []string) string {
+	if slices.Contains(scopes, ScopeAdmin) {
+		return roles
+	}
+	var kept []string
+	for _, r := range strings.Split(roles, ",") {
+		if r != "" && r != "admin" {
+			kept = append(kept, r)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+// RevokeAll deletes the personal access tokens of the user and the access tokens issued to other applications for the
+// user. It is called together with session.RevokeAll when an account may have been taken over, such as after a
+// password reset, so that a token created by an attacker does not outlive their sessions.
+func RevokeAll(db *gorm.DB, userID uint) error {
+	err := db.Unscoped().Where("user_id = ?", userID).Delete(&models.APIToken{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Where("user_id = ?", userID).Delete(&models.OAuthAccessToken{}).Error
+}
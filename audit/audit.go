@@ -0,0 +1,221 @@
+// Package audit records security and admin events in the append-only, hash-chained audit log
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// The actions which are recorded
+const (
+	Login                   = "auth.login"
+	LoginFailed             = "auth.login_failed"
+	Logout                  = "auth.logout"
+	UserRegistered          = "user.registered"
+	UserRolesChanged        = "user.roles_changed"
+	UserActivated           = "user.activated"
+	UserPasswordResetForced = "user.password_reset_forced"
+	UserDisabled            = "user.disabled"
+	UserEnabled             = "user.enabled"
+	UserDeleted             = "user.deleted"
+	UserRestored            = "user.restored"
+	UserPurged              = "user.purged"
+	UserDataExported        = "user.data_exported"
+	UserTwoFactorReset      = "user.2fa_reset"
+	LockoutRemoved          = "lockout.removed"
+	PasswordResetRequested  = "password.reset_requested"
+	PasswordReset           = "password.reset"
+	PasswordChanged         = "password.changed"
+	EmailChangeRequested    = "email.change_requested"
+	EmailChanged            = "email.changed"
+	EmailChangeReverted     = "email.change_reverted"
+	APITokenCreated         = "api_token.created"
+	APITokenRevoked         = "api_token.revoked"
+	PasskeyAdded            = "passkey.added"
+	PasskeyRemoved          = "passkey.removed"
+	IdentityLinked          = "identity.linked"
+	IdentityUnlinked        = "identity.unlinked"
+	ClientCreated           = "client.created"
+	ClientUpdated           = "client.updated"
+	ClientSecretRotated     = "client.secret_rotated"
+	ClientDeleted           = "client.deleted"
+	RoleCreated             = "role.created"
+	RoleUpdated             = "role.updated"
+	RoleDeleted             = "role.deleted"
+	RoleMembersAdded        = "role.members_added"
+	RoleMembersRemoved      = "role.members_removed"
+	InvitationCreated       = "invitation.created"
+	InvitationRevoked       = "invitation.revoked"
+	InvitationAccepted      = "invitation.accepted"
+	ImpersonationStarted    = "impersonation.started"
+	ImpersonationEnded      = "impersonation.ended"
+	ConfigChanged           = "config.changed"
+)
+
+// Actions are all the actions which are recorded, used to filter the audit log
+var Actions = []string{
+	Login, LoginFailed, Logout,
+	UserRegistered, UserRolesChanged, UserActivated, UserPasswordResetForced, UserDisabled, UserEnabled, UserDeleted, UserRestored, UserPurged, UserDataExported, UserTwoFactorReset,
+	LockoutRemoved,
+	PasswordResetRequested, PasswordReset, PasswordChanged,
+	EmailChangeRequested, EmailChanged, EmailChangeReverted,
+	APITokenCreated, APITokenRevoked,
+	PasskeyAdded, PasskeyRemoved,
+	IdentityLinked, IdentityUnlinked,
+	ClientCreated, ClientUpdated, ClientSecretRotated, ClientDeleted,
+	RoleCreated, RoleUpdated, RoleDeleted, RoleMembersAdded, RoleMembersRemoved,
+	InvitationCreated, InvitationRevoked, InvitationAccepted,
+	ImpersonationStarted, ImpersonationEnded,
+	ConfigChanged,
+}
+
+// otherSubjectPrefixes and otherSubjects are the actions which are taken on something other than a user, their
+// subject is the id of a role, an OAuth client, an invitation or nothing
+var (
+	otherSubjectPrefixes = []string{"role.", "client.", "config."}
+	otherSubjects        = []string{InvitationCreated, InvitationRevoked}
+)
+
+// SubjectIsUser returns false for actions which are taken on something other than a user
+func SubjectIsUser(action string) bool {
+	for _, prefix := range otherSubjectPrefixes {
+		if strings.HasPrefix(action, prefix) {
+			return false
+		}
+	}
+	return !slices.Contains(otherSubjects, action)
+}
+
+// SubjectIsUserCondition is the SQL condition which matches the same events as SubjectIsUser, it is used together with
+// a condition on subject_id so that the ids of roles, clients and invitations are not mistaken for the ids of users
+var SubjectIsUserCondition = subjectIsUserCondition()
+
+func subjectIsUserCondition() string {
+	var conditions []string
+	for _, prefix := range otherSubjectPrefixes {
+		conditions = append(conditions, fmt.Sprintf("action NOT LIKE '%s%%'", prefix))
+	}
+	for _, action := range otherSubjects {
+		conditions = append(conditions, fmt.Sprintf("action <> '%s'", action))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// chain serializes appending events so that each event is linked to the one before it. Running several instances of
+// the application against the same database can still fork the chain, which Verify reports.
+var chain sync.Mutex
+
+// Record adds an event to the audit log. The actor, IP address, user agent and request id are taken from the request,
+// while an admin is impersonating a user the admin is the actor. Errors are logged rather than returned as failing to
+// audit should not undo an action which has already been taken.
+func Record(c *gin.Context, db *gorm.DB, action string, subjectID uint, details string) {
+	actorID := c.GetUint(middleware.UserIDKey)
+	if impersonatorID := c.GetUint(middleware.ImpersonatorIDKey); impersonatorID != 0 {
+		details = strings.TrimSpace(fmt.Sprintf("%s impersonating=%d", details, actorID))
+		actorID = impersonatorID
+	}
+	record(c, db, actorID, action, subjectID, details)
+}
+
+// RecordUser adds an event which a user took on their own account before being logged in, such as logging in or
+// resetting a password. The user is the actor unless someone is logged in.
+func RecordUser(c *gin.Context, db *gorm.DB, action string, userID uint, details string) {
+	if _, exists := c.Get(middleware.UserIDKey); exists {
+		Record(c, db, action, userID, details)
+		return
+	}
+	record(c, db, userID, action, userID, details)
+}
+
+func record(c *gin.Context, db *gorm.DB, actorID uint, action string, subjectID uint, details string) {
+	event := models.AuditEvent{
+		ActorID:   actorID,
+		Action:    action,
+		SubjectID: subjectID,
+		Details:   details,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString(middleware.RequestIDKey),
+	}
+	err := Append(db, &event)
+	if err != nil {
+		slog.Error("audit.Record", "error", err, "action", action)
+		return
+	}
+	slog.Info("audit:"+action, "actor", actorID, "subject", subjectID, "details", details, "request", event.RequestID)
+}
+
+// Append links the event to the last event in the log and saves it
+func Append(db *gorm.DB, event *models.AuditEvent) error {
+	chain.Lock()
+	defer chain.Unlock()
+	return db.Transaction(func(tx *gorm.DB) error {
+		last := models.AuditEvent{}
+		res := tx.Order("id DESC").Limit(1).Find(&last)
+		if res.Error != nil {
+			return res.Error
+		}
+		// The time is stored in seconds so that the hash is the same after the row is read back from any database
+		event.CreatedAt = time.Now().UTC().Truncate(time.Second)
+		event.PrevHash = last.Hash
+		event.Hash = Hash(*event)
+		return tx.Create(event).Error
+	})
+}
+
+// Hash returns the hash of the event, which covers every field except the id and the hash itself
+func Hash(event models.AuditEvent) string {
+	b, _ := json.Marshal([]interface{}{
+		event.PrevHash,
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.ActorID,
+		event.Action,
+		event.SubjectID,
+		event.Details,
+		event.IP,
+		event.UserAgent,
+		event.RequestID,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify walks the whole log in the order of the ids and returns the number of events checked and the id of the first
+// event which does not match its hash or the hash of the event before it. The id is 0 when the chain is intact.
+func Verify(db *gorm.DB) (int, uint, error) {
+	checked := 0
+	var broken uint
+	prev := ""
+	var batch []models.AuditEvent
+	res := db.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, event := range batch {
+			if event.PrevHash != prev || Hash(event) != event.Hash {
+				broken = event.ID
+				return errStop
+			}
+			prev = event.Hash
+			checked++
+		}
+		return nil
+	})
+	if res.Error != nil && !errors.Is(res.Error, errStop) {
+		return checked, 0, res.Error
+	}
+	return checked, broken, nil
+}
+
+// errStop ends Verify at the first broken event
+var errStop = errors.New("stop")
@@ -0,0 +1,128 @@
+// Package challenge asks browsers to solve a challenge before public forms which send emails are accepted, so that
+// bots can not use the forms to send large amounts of email. Challenges are only asked for once requests pass a rate.
+package challenge
+
+import (
+	"errors"
+	"html/template"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/infra"
+)
+
+// The kinds of challenge which can be configured
+const (
+	KindHashcash = "hashcash"
+	KindOff      = "off"
+)
+
+var (
+	// ErrMissing is returned when a form was sent without an answer
+	ErrMissing = errors.New("challenge: no answer")
+	// ErrInvalid is returned when the answer or the challenge is wrong
+	ErrInvalid = errors.New("challenge: invalid answer")
+	// ErrExpired is returned when the challenge is too old
+	ErrExpired = errors.New("challenge: expired")
+	// ErrReused is returned when an answer is sent a second time
+	ErrReused = errors.New("challenge: already used")
+)
+
+// Challenger is implemented by each kind of challenge
+type Challenger interface {
+	// Render returns the form fields and script of a new challenge, trans translates the text shown to the user
+	Render(trans func(string) string) (template.HTML, error)
+	// Verify checks the answer sent with the form
+	Verify(c *gin.Context) error
+}
+
+// Adaptive asks for a challenge once the requests from an IP address or from everyone pass a threshold per minute. The
+// challenge stays on until a whole minute has passed below the threshold.
+type Adaptive struct {
+	Challenger Challenger
+	// IPThreshold and GlobalThreshold are requests per minute, 0 asks for a challenge on every request
+	IPThreshold     int
+	GlobalThreshold int
+
+	mu       sync.Mutex
+	minute   int64
+	current  map[string]int
+	previous map[string]int
+}
+
+// globalKey holds the count of all requests, it can not be mistaken for an IP address
+const globalKey = "*"
+
+// New returns the challenge which is configured, a nil Challenger turns challenges off
+func New(conf *infra.Config) *Adaptive {
+	a := &Adaptive{
+		IPThreshold:     conf.ChallengeIPThreshold,
+		GlobalThreshold: conf.ChallengeGlobalThreshold,
+	}
+	if conf.Challenge == KindHashcash {
+		a.Challenger = NewHashcash(conf.CookieSecret, conf.ChallengeDifficulty)
+	}
+	return a
+}
+
+// Required returns true if forms shown to the IP address should include a challenge
+func (a *Adaptive) Required(ip string) bool {
+	if a.Challenger == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rotate()
+	return a.over(ip)
+}
+
+// Allow counts a request from the IP address and verifies the answer if a challenge is required
+func (a *Adaptive) Allow(c *gin.Context) error {
+	if a.Challenger == nil {
+		return nil
+	}
+	ip := c.ClientIP()
+	a.mu.Lock()
+	a.rotate()
+	a.current[ip]++
+	a.current[globalKey]++
+	required := a.over(ip)
+	a.mu.Unlock()
+	if !required {
+		return nil
+	}
+	return a.Challenger.Verify(c)
+}
+
+// Render returns a new challenge
+func (a *Adaptive) Render(trans func(string) string) (template.HTML, error) {
+	return a.Challenger.Render(trans)
+}
+
+// rotate starts a new minute, the counts of the last minute are kept so that a challenge does not switch off at once
+func (a *Adaptive) rotate() {
+	minute := time.Now().Unix() / 60
+	if minute == a.minute && a.current != nil {
+		return
+	}
+	if minute == a.minute+1 {
+		a.previous = a.current
+	} else {
+		a.previous = nil
+	}
+	a.current = map[string]int{}
+	a.minute = minute
+}
+
+func (a *Adaptive) over(ip string) bool {
+	if a.IPThreshold == 0 || a.GlobalThreshold == 0 {
+		return true
+	}
+	for _, counts := range []map[string]int{a.current, a.previous} {
+		if counts[ip] > a.IPThreshold || counts[globalKey] > a.GlobalThreshold {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package challenge
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hashcashLifetime is how long the browser has to solve a challenge and send the form
+const hashcashLifetime = 10 * time.Minute
+
+// The form fields which carry the challenge and the answer
+const (
+	HashcashField       = "challenge"
+	HashcashAnswerField = "challenge_answer"
+)
+
+//go:embed hashcash.gohtml
+var hashcashHTML string
+
+var hashcashTemplate = template.Must(template.New("hashcash").Parse(hashcashHTML))
+
+// Hashcash is a proof-of-work challenge which the browser solves by finding a number which, added to the challenge,
+// gives a SHA-256 hash that starts with a number of zero bits. Solving it takes a moment for one form but makes sending
+// many forms expensive. Challenges are signed so that nothing is stored until an answer is used.
+type Hashcash struct {
+	key []byte
+	// Difficulty is the number of zero bits, each bit doubles the average work
+	Difficulty int
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewHashcash returns a hashcash challenge which signs challenges with a key derived from secret
+func NewHashcash(secret string, difficulty int) *Hashcash {
+	key := sha256.Sum256([]byte("challenge:hashcash:" + secret))
+	return &Hashcash{
+		key:        key[:],
+		Difficulty: min(max(difficulty, 1), 32),
+		used:       map[string]time.Time{},
+	}
+}
+
+// Render returns the hidden fields and the script which solves the challenge
+func (h *Hashcash) Render(trans func(string) string) (template.HTML, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	value := fmt.Sprintf("%d.%d.%s", time.Now().Unix(), h.Difficulty, base64.RawURLEncoding.EncodeToString(b))
+	value += "." + h.sign(value)
+
+	buf := bytes.Buffer{}
+	err = hashcashTemplate.Execute(&buf, map[string]interface{}{
+		"Field":       HashcashField,
+		"AnswerField": HashcashAnswerField,
+		"Challenge":   value,
+		"Difficulty":  h.Difficulty,
+		"Working":     trans("Checking your browser, the form can be sent in a moment."),
+		"Done":        trans("Your browser has been checked."),
+	})
+	return template.HTML(buf.String()), err
+}
+
+// Verify checks the signature, the age and the proof of work of the answer. Each challenge can only be used once.
+func (h *Hashcash) Verify(c *gin.Context) error {
+	value := c.PostForm(HashcashField)
+	answer := c.PostForm(HashcashAnswerField)
+	if value == "" || answer == "" {
+		return ErrMissing
+	}
+
+	parts := strings.Split(value, ".")
+	if len(parts) != 4 || !hmac.Equal([]byte(parts[3]), []byte(h.sign(strings.Join(parts[:3], ".")))) {
+		return ErrInvalid
+	}
+	issued, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	if time.Since(time.Unix(issued, 0)) > hashcashLifetime {
+		return ErrExpired
+	}
+	// The difficulty is part of the signed value so that a change of difficulty does not invalidate open forms
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ErrInvalid
+	}
+	if _, err = strconv.ParseUint(answer, 10, 64); err != nil {
+		return ErrInvalid
+	}
+	sum := sha256.Sum256([]byte(value + ":" + answer))
+	if leadingZeros(sum[:]) < difficulty {
+		return ErrInvalid
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for k, expires := range h.used {
+		if now.After(expires) {
+			delete(h.used, k)
+		}
+	}
+	if _, used := h.used[value]; used {
+		return ErrReused
+	}
+	h.used[value] = time.Unix(issued, 0).Add(hashcashLifetime)
+	return nil
+}
+
+func (h *Hashcash) sign(value string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// leadingZeros returns the number of zero bits at the start of b
+func leadingZeros(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package challenge
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testDifficulty keeps solving the challenges fast
+const testDifficulty = 8
+
+// challenge returns a challenge signed by h which was issued at the time
+func challenge(h *Hashcash, issued time.Time, difficulty int) string {
+	value := fmt.Sprintf("%d.%d.%s", issued.Unix(), difficulty, "nonce"+strconv.FormatInt(issued.UnixNano(), 36))
+	return value + "." + h.sign(value)
+}
+
+// solve returns the first answer with at least difficulty zero bits, or with fewer when enough is false
+func solve(value string, difficulty int, enough bool) string {
+	for i := uint64(0); ; i++ {
+		answer := strconv.FormatUint(i, 10)
+		sum := sha256.Sum256([]byte(value + ":" + answer))
+		if (leadingZeros(sum[:]) >= difficulty) == enough {
+			return answer
+		}
+	}
+}
+
+// formContext returns a context with the challenge and answer posted as a form
+func formContext(value string, answer string) *gin.Context {
+	form := url.Values{HashcashField: {value}, HashcashAnswerField: {answer}}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c
+}
+
+func TestHashcashVerify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHashcash("secret", testDifficulty)
+	other := NewHashcash("other secret", testDifficulty)
+	now := time.Now()
+
+	valid := challenge(h, now, testDifficulty)
+	easier := challenge(h, now.Add(-time.Second), 1)
+	expired := challenge(h, now.Add(-hashcashLifetime-time.Minute), testDifficulty)
+	forged := challenge(other, now, testDifficulty)
+	tampered := strings.Replace(valid, "."+strconv.Itoa(testDifficulty)+".", ".1.", 1)
+
+	tests := []struct {
+		name   string
+		value  string
+		answer string
+		want   error
+	}{
+		{"missing", "", "", ErrMissing},
+		{"missing answer", valid, "", ErrMissing},
+		{"not enough work", valid, solve(valid, testDifficulty, false), ErrInvalid},
+		{"answer not a number", valid, "abc", ErrInvalid},
+		{"signed by another key", forged, solve(forged, testDifficulty, true), ErrInvalid},
+		{"difficulty changed", tampered, solve(tampered, 1, true), ErrInvalid},
+		{"malformed", "1.2.3", "1", ErrInvalid},
+		{"expired", expired, solve(expired, testDifficulty, true), ErrExpired},
+		{"valid", valid, solve(valid, testDifficulty, true), nil},
+		{"reused", valid, solve(valid, testDifficulty, true), ErrReused},
+		{"difficulty it was issued with", easier, solve(easier, 1, true), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.Verify(formContext(tt.value, tt.answer))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeadingZeros(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x40}, 9},
+		{[]byte{0x00, 0x00}, 16},
+	}
+	for _, tt := range tests {
+		if got := leadingZeros(tt.b); got != tt.want {
+			t.Errorf("leadingZeros(%x) = %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
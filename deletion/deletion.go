@@ -0,0 +1,97 @@
+// Package deletion removes the sessions, tokens and credentials of deleted users once they can no longer be restored.
+// Users are soft deleted so that they can be restored during a grace period, a background job purges their data after it.
+package deletion
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/lockout"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// interval is how often the background job looks for users to purge
+const interval = time.Hour
+
+// Start runs Purge in the background every hour until the application stops, the same job removes expired failed
+// login counters
+func Start(db *gorm.DB, conf *infra.Config) {
+	go func() {
+		for {
+			count, err := Purge(db, time.Duration(conf.AccountDeletionGraceDays)*24*time.Hour)
+			if err != nil {
+				slog.Error("deletion:Purge", "error", err)
+			} else if count > 0 {
+				slog.Info("deletion:Purge", "users", count)
+			}
+			failures, err := lockout.Purge(db, conf)
+			if err != nil {
+				slog.Error("lockout:Purge", "error", err)
+			} else if failures > 0 {
+				slog.Info("lockout:Purge", "failures", failures)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Purge hard deletes the sessions, tokens and credentials of users who were deleted longer ago than the grace period.
+// The user row is kept soft deleted so that the audit log can still refer to it. The number of purged users is returned.
+func Purge(db *gorm.DB, grace time.Duration) (int, error) {
+	var users []models.User
+	res := db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ? AND purged_at IS NULL", time.Now().Add(-grace)).Find(&users)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	for i, user := range users {
+		err := purgeUser(db, user.ID)
+		if err != nil {
+			return i, err
+		}
+		err = audit.Append(db, &models.AuditEvent{Action: audit.UserPurged, SubjectID: user.ID})
+		if err != nil {
+			slog.Error("deletion:Purge", "error", err)
+		}
+	}
+	return len(users), nil
+}
+
+func purgeUser(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("model_type = ? AND model_id = ?", "User", userID).Delete(&models.Token{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Where("model_type = ? AND model_id IN (?)", "EmailChange",
+			tx.Model(&models.EmailChange{}).Select("id").Where("user_id = ?", userID)).Delete(&models.Token{}).Error
+		if err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.Session{},
+			&models.RememberToken{},
+			&models.APIToken{},
+			&models.RecoveryCode{},
+			&models.Passkey{},
+			&models.Identity{},
+			&models.PasswordHistory{},
+			&models.OAuthConsent{},
+			&models.OAuthAuthorization{},
+			&models.OAuthAccessToken{},
+		} {
+			err = tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		return tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"purged_at":       &now,
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+		}).Error
+	})
+}
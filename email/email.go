@@ -56,5 +56,5 @@ func (s Service) Send(to string, subject string, message string) {
 		slog.Error("smtp.SendEmail", "error", err, "smtpServer", s.Config.SMTPHost)
 		return
 	}
-	slog.Info("Email sent to %s", to)
+	slog.Info("Email sent", "to", to)
 }
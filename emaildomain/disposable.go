@@ -0,0 +1,71 @@
+package emaildomain
+
+import (
+	"bufio"
+	_ "embed"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bundled is the list of disposable email domains which is used unless another list is configured
+//
+//go:embed disposable.txt
+var bundled string
+
+// bundledDomains is the parsed bundled list
+var bundledDomains = sync.OnceValue(func() map[string]bool {
+	return parseList(bundled)
+})
+
+// disposableFile caches the parsed list of a configured file. The file is read again when its modification time
+// changes, so that the list can be updated without restarting the application.
+var disposableFile struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	domains map[string]bool
+}
+
+// disposableList returns the domains of the list at path, or of the bundled list if path is empty. The bundled list is
+// returned together with the error if the file can not be read.
+func disposableList(path string) (map[string]bool, error) {
+	if path == "" {
+		return bundledDomains(), nil
+	}
+
+	disposableFile.Lock()
+	defer disposableFile.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return bundledDomains(), err
+	}
+	if path == disposableFile.path && info.ModTime().Equal(disposableFile.modTime) {
+		return disposableFile.domains, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return bundledDomains(), err
+	}
+	disposableFile.path = path
+	disposableFile.modTime = info.ModTime()
+	disposableFile.domains = parseList(string(b))
+	return disposableFile.domains, nil
+}
+
+// parseList returns the domains of a list with one domain per line, lines starting with # are comments
+func parseList(list string) map[string]bool {
+	domains := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if d := normalize(line); d != "" {
+			domains[d] = true
+		}
+	}
+	return domains
+}
@@ -0,0 +1,171 @@
+// Package emaildomain decides which email domains can be used to register. Admins can allow only some domains, block
+// domains, block disposable email services and require that the domain can receive email.
+package emaildomain
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/uberswe/golang-base-project/infra"
+)
+
+// lookupTimeout is how long the DNS lookups of a domain can take
+const lookupTimeout = 5 * time.Second
+
+var (
+	// ErrNoDomain is returned for an email without a domain
+	ErrNoDomain = errors.New("emaildomain: email has no domain")
+	// ErrNotAllowed is returned when only some domains are allowed and the domain is not one of them
+	ErrNotAllowed = errors.New("emaildomain: domain is not allowed")
+	// ErrBlocked is returned when the domain is blocked
+	ErrBlocked = errors.New("emaildomain: domain is blocked")
+	// ErrDisposable is returned when the domain belongs to a disposable email service
+	ErrDisposable = errors.New("emaildomain: domain is disposable")
+	// ErrNoMail is returned when the domain has no mail server
+	ErrNoMail = errors.New("emaildomain: domain can not receive email")
+)
+
+// Resolver looks up the DNS records needed to tell if a domain can receive email. *net.Resolver implements it, tests
+// can use a stub which returns a *net.DNSError with IsNotFound set for missing records.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Checker checks emails against the registration rules of the configuration
+type Checker struct {
+	resolver Resolver
+}
+
+// New returns a Checker which uses the resolver for MX validation
+func New(resolver Resolver) *Checker {
+	return &Checker{resolver: resolver}
+}
+
+// Check returns an error if the configuration does not allow the email to register. The rules are read on every call
+// so that changes on the configuration page apply at once. Lookups which fail for other reasons than a missing record,
+// such as a timeout, are logged and the email is allowed so that a DNS outage does not stop registrations.
+func (ch *Checker) Check(ctx context.Context, conf *infra.Config, email string) error {
+	domain := Domain(email)
+	if domain == "" {
+		return ErrNoDomain
+	}
+
+	allowed := set(split(conf.RegistrationAllowedDomains))
+	if matches(set(split(conf.RegistrationBlockedDomains)), domain) {
+		return ErrBlocked
+	}
+	if len(allowed) > 0 && !matches(allowed, domain) {
+		return ErrNotAllowed
+	}
+	// Domains which are allowed by name are trusted even if they are in the disposable list
+	if conf.RegistrationBlockDisposable && !matches(allowed, domain) {
+		disposable, err := disposableList(conf.RegistrationDisposableList)
+		if err != nil {
+			slog.Error("emaildomain.Check", "error", err)
+		}
+		if matches(disposable, domain) {
+			return ErrDisposable
+		}
+	}
+
+	if conf.RegistrationCheckMX {
+		err := ch.receivesMail(ctx, domain)
+		if errors.Is(err, ErrNoMail) {
+			return err
+		}
+		if err != nil {
+			slog.Warn("emaildomain.Check", "error", err, "domain", domain)
+		}
+	}
+	return nil
+}
+
+// receivesMail returns ErrNoMail if the domain has no MX records and no address records, or a null MX record
+func (ch *Checker) receivesMail(ctx context.Context, domain string) error {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	records, err := ch.resolver.LookupMX(ctx, domain)
+	if err != nil && !notFound(err) {
+		return err
+	}
+	if err == nil && len(records) > 0 {
+		// A single record with the host "." is a null MX, the domain does not accept email (RFC 7505)
+		if len(records) == 1 && strings.TrimSuffix(records[0].Host, ".") == "" {
+			return ErrNoMail
+		}
+		return nil
+	}
+
+	// Without MX records email is delivered to the address records of the domain (RFC 5321)
+	hosts, err := ch.resolver.LookupHost(ctx, domain)
+	if err != nil && !notFound(err) {
+		return err
+	}
+	if err == nil && len(hosts) > 0 {
+		return nil
+	}
+	return ErrNoMail
+}
+
+// notFound returns true if the lookup failed because the record does not exist
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// Domain returns the lowercase domain of an email, or an empty string if it has none
+func Domain(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+	return normalize(email[i+1:])
+}
+
+// split returns the domains of a list separated by commas, spaces or new lines. A leading @ is removed so that both
+// example.com and @example.com can be entered.
+func split(domains string) []string {
+	var list []string
+	for _, d := range strings.FieldsFunc(domains, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	}) {
+		if d = normalize(strings.TrimPrefix(d, "@")); d != "" {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+func normalize(domain string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// set returns the domains as a set which can be passed to matches
+func set(domains []string) map[string]bool {
+	s := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		s[d] = true
+	}
+	return s
+}
+
+// matches returns true if the domain or one of its parent domains is in the set, so that listing example.com also
+// matches mail.example.com
+func matches(domains map[string]bool, domain string) bool {
+	for {
+		if domains[domain] {
+			return true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return false
+		}
+		domain = parent
+	}
+}
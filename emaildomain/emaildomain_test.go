@@ -0,0 +1,101 @@
+package emaildomain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/uberswe/golang-base-project/infra"
+)
+
+// stubResolver answers lookups from maps, missing domains are not found and domains in fail time out
+type stubResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+	fail  map[string]bool
+}
+
+func (r stubResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	if r.fail[name] {
+		return nil, &net.DNSError{Err: "timeout", Name: name, IsTimeout: true}
+	}
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if r.fail[host] {
+		return nil, &net.DNSError{Err: "timeout", Name: host, IsTimeout: true}
+	}
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestCheck(t *testing.T) {
+	resolver := stubResolver{
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mx.example.com.", Pref: 10}},
+			"nullmx.com":  {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{
+			"addressonly.com": {"192.0.2.1"},
+		},
+		fail: map[string]bool{
+			"timeout.com": true,
+		},
+	}
+	checker := New(resolver)
+
+	tests := []struct {
+		name  string
+		conf  infra.Config
+		email string
+		want  error
+	}{
+		{"no domain", infra.Config{}, "user", ErrNoDomain},
+		{"no rules", infra.Config{}, "user@anything.test", nil},
+		{"allowed", infra.Config{RegistrationAllowedDomains: "example.com"}, "user@example.com", nil},
+		{"allowed subdomain", infra.Config{RegistrationAllowedDomains: "@example.com"}, "user@mail.Example.com", nil},
+		{"not allowed", infra.Config{RegistrationAllowedDomains: "example.com, example.org"}, "user@example.net", ErrNotAllowed},
+		{"blocked", infra.Config{RegistrationBlockedDomains: "example.com"}, "user@example.com", ErrBlocked},
+		{"blocked wins over allowed", infra.Config{RegistrationAllowedDomains: "example.com", RegistrationBlockedDomains: "bad.example.com"}, "user@bad.example.com", ErrBlocked},
+		{"disposable", infra.Config{RegistrationBlockDisposable: true}, "user@10minutemail.com", ErrDisposable},
+		{"disposable not blocked", infra.Config{}, "user@10minutemail.com", nil},
+		{"disposable allowed by name", infra.Config{RegistrationBlockDisposable: true, RegistrationAllowedDomains: "10minutemail.com"}, "user@10minutemail.com", nil},
+		{"mx", infra.Config{RegistrationCheckMX: true}, "user@example.com", nil},
+		{"address records", infra.Config{RegistrationCheckMX: true}, "user@addressonly.com", nil},
+		{"null mx", infra.Config{RegistrationCheckMX: true}, "user@nullmx.com", ErrNoMail},
+		{"no records", infra.Config{RegistrationCheckMX: true}, "user@missing.test", ErrNoMail},
+		{"lookup fails", infra.Config{RegistrationCheckMX: true}, "user@timeout.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checker.Check(context.Background(), &tt.conf, tt.email)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.email, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomain(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"user@Example.COM", "example.com"},
+		{"user@example.com.", "example.com"},
+		{"a@b@example.com", "example.com"},
+		{"user", ""},
+	}
+	for _, tt := range tests {
+		if got := Domain(tt.email); got != tt.want {
+			t.Errorf("Domain(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
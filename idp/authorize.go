@@ -0,0 +1,263 @@
+package idp
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"gorm.io/gorm"
+)
+
+// pendingRequestKey holds an authorization request which is waiting for the user to give consent
+const pendingRequestKey = "OAuthRequest"
+
+// codeLifetime is how long a client has to exchange an authorization code
+const codeLifetime = 2 * time.Minute
+
+// authRequest holds a validated authorization request
+type authRequest struct {
+	ClientID      uint
+	UserID        uint
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	Nonce         string
+	CodeChallenge string
+}
+
+// ConsentPageData holds the additional data needed to render the consent screen
+type ConsentPageData struct {
+	routes.PageData
+	Client *models.OAuthClient
+	Scopes []string
+}
+
+// renderError renders the consent page with an error, used when the client or redirect uri can not be trusted
+func (svc Service) renderError(c *gin.Context, pd routes.PageData, status int) {
+	pd.Title = pd.Trans("Authorize")
+	pd.AddMessage(routes.Error, pd.Trans("The application sent an invalid login request."))
+	c.HTML(status, "consent.gohtml", ConsentPageData{PageData: pd})
+}
+
+// redirectError sends the user back to the client with an OAuth 2.0 error
+func redirectError(c *gin.Context, req authRequest, code string) {
+	redirect(c, req.RedirectURI, url.Values{"error": {code}, "state": {req.State}})
+}
+
+func redirect(c *gin.Context, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	q := u.Query()
+	for k, v := range params {
+		if len(v) > 0 && v[0] != "" {
+			q[k] = v
+		}
+	}
+	u.RawQuery = q.Encode()
+	c.Redirect(http.StatusFound, u.String())
+}
+
+// Authorize handles authorization requests from clients. Users are asked to login first and to give consent the first
+// time a client requests a set of scopes. If consent has already been given the user is sent back with a code.
+func (svc Service) Authorize(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	db := svc.env.GetDb()
+
+	client := models.OAuthClient{ClientID: c.Query("client_id")}
+	res := db.Where(&client).First(&client)
+	if client.ClientID == "" || res.Error != nil {
+		svc.renderError(c, pd, http.StatusBadRequest)
+		return
+	}
+	// The redirect uri must match a registered uri exactly, errors are only sent to the client once it has been verified
+	req := authRequest{ClientID: client.ID, RedirectURI: c.Query("redirect_uri"), State: c.Query("state"), Nonce: c.Query("nonce")}
+	if !slices.Contains(client.RedirectURIList(), req.RedirectURI) {
+		svc.renderError(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	if c.Query("response_type") != "code" {
+		redirectError(c, req, "unsupported_response_type")
+		return
+	}
+	// Scopes which are unknown or not allowed for the client are ignored, as OpenID Connect requires
+	for _, s := range strings.Fields(c.Query("scope")) {
+		if slices.Contains(client.ScopeList(), s) && !slices.Contains(req.Scopes, s) {
+			req.Scopes = append(req.Scopes, s)
+		}
+	}
+	if !slices.Contains(req.Scopes, ScopeOpenID) {
+		redirectError(c, req, "invalid_scope")
+		return
+	}
+	if challenge := c.Query("code_challenge"); challenge != "" {
+		if c.Query("code_challenge_method") != "S256" {
+			redirectError(c, req, "invalid_request")
+			return
+		}
+		req.CodeChallenge = challenge
+	}
+
+	prompt := c.Query("prompt")
+	userID, authenticated := c.Get(middleware.UserIDKey)
+	if !authenticated {
+		if prompt == "none" {
+			redirectError(c, req, "login_required")
+			return
+		}
+		session := middleware.DefaultSessionWithOptions(c)
+		session.Set(middleware.ReturnToKey, c.Request.URL.RequestURI())
+		err := session.Save()
+		if err != nil {
+			slog.Error("Authorize", "error", err)
+		}
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	req.UserID = userID.(uint)
+
+	consented, err := svc.hasConsent(req)
+	if err != nil {
+		slog.Error("Authorize", "error", err)
+		redirectError(c, req, "server_error")
+		return
+	}
+	if consented && prompt != "consent" {
+		svc.issueCode(c, req)
+		return
+	}
+	if prompt == "none" {
+		redirectError(c, req, "consent_required")
+		return
+	}
+
+	b, err := json.Marshal(req)
+	if err == nil {
+		session := middleware.DefaultSessionWithOptions(c)
+		session.Set(pendingRequestKey, string(b))
+		err = session.Save()
+	}
+	if err != nil {
+		slog.Error("Authorize", "error", err)
+		redirectError(c, req, "server_error")
+		return
+	}
+
+	pd.Title = pd.Trans("Authorize")
+	c.HTML(http.StatusOK, "consent.gohtml", ConsentPageData{
+		PageData: pd,
+		Client:   &client,
+		Scopes:   req.Scopes,
+	})
+}
+
+// AuthorizePost handles the answer of the consent screen
+func (svc Service) AuthorizePost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+
+	// The request is taken from the session so that it can not be modified and can only be answered once
+	session := middleware.DefaultSessionWithOptions(c)
+	s, ok := session.Get(pendingRequestKey).(string)
+	session.Delete(pendingRequestKey)
+	err := session.Save()
+	if err != nil {
+		slog.Error("AuthorizePost", "error", err)
+	}
+	req := authRequest{}
+	if !ok || json.Unmarshal([]byte(s), &req) != nil || req.UserID != c.GetUint(middleware.UserIDKey) {
+		svc.renderError(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	if c.PostForm("approve") != "true" {
+		slog.Info("AuthorizePost:Denied", "user", req.UserID, "client", req.ClientID)
+		redirectError(c, req, "access_denied")
+		return
+	}
+
+	consent := models.OAuthConsent{UserID: req.UserID, ClientID: req.ClientID}
+	res := svc.env.GetDb().Where(&consent).First(&consent)
+	if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		slog.Error("AuthorizePost", "error", res.Error)
+		redirectError(c, req, "server_error")
+		return
+	}
+	scopes := strings.Fields(consent.Scopes)
+	for _, s := range req.Scopes {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	consent.Scopes = strings.Join(scopes, " ")
+	res = svc.env.GetDb().Save(&consent)
+	if res.Error != nil {
+		slog.Error("AuthorizePost", "error", res.Error)
+		redirectError(c, req, "server_error")
+		return
+	}
+
+	slog.Info("AuthorizePost:Granted", "user", req.UserID, "client", req.ClientID, "scopes", consent.Scopes)
+	svc.issueCode(c, req)
+}
+
+// hasConsent checks if the user has already granted all the requested scopes to the client
+func (svc Service) hasConsent(req authRequest) (bool, error) {
+	consent := models.OAuthConsent{UserID: req.UserID, ClientID: req.ClientID}
+	res := svc.env.GetDb().Where(&consent).First(&consent)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if res.Error != nil {
+		return false, res.Error
+	}
+	granted := strings.Fields(consent.Scopes)
+	for _, s := range req.Scopes {
+		if !slices.Contains(granted, s) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// issueCode stores a new authorization code and sends the user back to the client with it. The roles of the current
+// session are stored with the code so that the tokens reflect the roles the user had when logging in.
+func (svc Service) issueCode(c *gin.Context, req authRequest) {
+	code, err := NewSecret()
+	if err != nil {
+		slog.Error("issueCode", "error", err)
+		redirectError(c, req, "server_error")
+		return
+	}
+
+	authorization := models.OAuthAuthorization{
+		CodeHash:      HashSecret(code),
+		ClientID:      req.ClientID,
+		UserID:        req.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        strings.Join(req.Scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		Role:          c.GetString(middleware.UserRoleKey),
+		ExpiresAt:     time.Now().Add(codeLifetime),
+	}
+	res := svc.env.GetDb().Save(&authorization)
+	if res.Error != nil {
+		slog.Error("issueCode", "error", res.Error)
+		redirectError(c, req, "server_error")
+		return
+	}
+
+	redirect(c, req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+}
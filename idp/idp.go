@@ -0,0 +1,120 @@
+// Package idp implements an OpenID Connect provider so that other applications can let the users of this application login
+package idp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+)
+
+const (
+	// ScopeOpenID is required in every authorization request
+	ScopeOpenID = "openid"
+	// ScopeEmail adds the email and email_verified claims
+	ScopeEmail = "email"
+	// ScopeRoles adds the roles claim which holds the roles of the user in this application
+	ScopeRoles = "roles"
+)
+
+// Scopes holds all the scopes which can be granted to a client
+var Scopes = []string{ScopeOpenID, ScopeEmail, ScopeRoles}
+
+type Service struct {
+	env infra.ILair
+}
+
+func NewService(env infra.ILair) *Service {
+	return &Service{env: env}
+}
+
+// issuer returns the issuer identifier which is the BaseURL without a trailing slash
+func (svc Service) issuer() string {
+	return strings.TrimRight(svc.env.GetConfig().BaseURL, "/")
+}
+
+// Discovery returns the OpenID Connect discovery document
+func (svc Service) Discovery(c *gin.Context) {
+	iss := svc.issuer()
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                iss,
+		"authorization_endpoint":                iss + "/oauth2/authorize",
+		"token_endpoint":                        iss + "/oauth2/token",
+		"userinfo_endpoint":                     iss + "/oauth2/userinfo",
+		"jwks_uri":                              iss + "/oauth2/jwks",
+		"scopes_supported":                      Scopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"iss", "sub", "aud", "exp", "iat", "nonce", "email", "email_verified", "roles"},
+	})
+}
+
+// JWKS returns the public keys which are used to verify ID tokens
+func (svc Service) JWKS(c *gin.Context) {
+	set, err := publicKeys(svc.env.GetDb())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	// Make sure there is always a key to publish, even before the first token has been signed
+	if len(set.Keys) == 0 {
+		k, err := currentKey(svc.env.GetDb())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+		set.Keys = append(set.Keys, k.Public())
+	}
+	c.JSON(http.StatusOK, set)
+}
+
+// NewSecret returns a random value which can be used as a client id, client secret, code or token
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashSecret returns the value stored in the database for a client secret, code or token. The values are random
+// with 256 bits of entropy so a fast hash is enough.
+func HashSecret(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// userClaims returns the claims about the user which are allowed by the granted scopes
+func userClaims(user models.User, scopes []string, role string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": strconv.FormatUint(uint64(user.ID), 10),
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.ActivatedAt != nil
+	}
+	if slices.Contains(scopes, ScopeRoles) {
+		// role holds the comma separated roles stored in models.Session when the client was authorized
+		roles := []string{}
+		for _, r := range strings.Split(role, ",") {
+			if r != "" {
+				roles = append(roles, r)
+			}
+		}
+		claims["roles"] = roles
+	}
+	return claims
+}
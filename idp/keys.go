@@ -0,0 +1,94 @@
+package idp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sync"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/text"
+	"gorm.io/gorm"
+)
+
+var (
+	keyMu sync.Mutex
+	// signingKey caches the newest signing key after it has been loaded from the database
+	signingKey *jose.JSONWebKey
+)
+
+// currentKey returns the key used to sign ID tokens. A key is generated and stored the first time it is needed so that
+// all instances of the application sign with the same key.
+func currentKey(db *gorm.DB) (jose.JSONWebKey, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if signingKey != nil {
+		return *signingKey, nil
+	}
+
+	sk := models.SigningKey{}
+	res := db.Order("id desc").First(&sk)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		sk, res.Error = generateKey(db)
+	}
+	if res.Error != nil {
+		return jose.JSONWebKey{}, res.Error
+	}
+
+	k, err := decodeKey(sk)
+	if err != nil {
+		return k, err
+	}
+	signingKey = &k
+	return k, nil
+}
+
+func generateKey(db *gorm.DB) (models.SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	sk := models.SigningKey{
+		KeyID:      text.RandomString(16),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+	res := db.Save(&sk)
+	return sk, res.Error
+}
+
+func decodeKey(sk models.SigningKey) (jose.JSONWebKey, error) {
+	block, _ := pem.Decode([]byte(sk.PrivateKey))
+	if block == nil {
+		return jose.JSONWebKey{}, errors.New("signing key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+	return jose.JSONWebKey{Key: key, KeyID: sk.KeyID, Algorithm: string(jose.RS256), Use: "sig"}, nil
+}
+
+// publicKeys returns the public part of every stored signing key
+func publicKeys(db *gorm.DB) (jose.JSONWebKeySet, error) {
+	set := jose.JSONWebKeySet{}
+	var keys []models.SigningKey
+	res := db.Order("id desc").Find(&keys)
+	if res.Error != nil {
+		return set, res.Error
+	}
+	for _, sk := range keys {
+		k, err := decodeKey(sk)
+		if err != nil {
+			return set, err
+		}
+		set.Keys = append(set.Keys, k.Public())
+	}
+	return set, nil
+}
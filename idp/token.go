@@ -0,0 +1,190 @@
+package idp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/uberswe/golang-base-project/models"
+)
+
+// tokenLifetime is how long ID tokens and access tokens are valid
+const tokenLifetime = time.Hour
+
+// tokenError writes an OAuth 2.0 error response from the token endpoint
+func tokenError(c *gin.Context, status int, code string) {
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+	}
+	c.JSON(status, gin.H{"error": code})
+}
+
+// authenticateClient checks the client credentials sent with client_secret_basic or client_secret_post
+func (svc Service) authenticateClient(c *gin.Context) (models.OAuthClient, bool) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if ok {
+		// The credentials are form encoded before they are put in the header
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client := models.OAuthClient{ClientID: clientID}
+	if clientID == "" || svc.env.GetDb().Where(&client).First(&client).Error != nil {
+		return client, false
+	}
+	return client, subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(client.SecretHash)) == 1
+}
+
+// Token exchanges an authorization code for an ID token and an access token
+func (svc Service) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	db := svc.env.GetDb()
+
+	client, ok := svc.authenticateClient(c)
+	if !ok {
+		tokenError(c, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if c.PostForm("grant_type") != "authorization_code" {
+		tokenError(c, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	authorization := models.OAuthAuthorization{CodeHash: HashSecret(c.PostForm("code"))}
+	res := db.Where(&authorization).First(&authorization)
+	if c.PostForm("code") == "" || res.Error != nil || authorization.ClientID != client.ID || authorization.HasExpired() ||
+		authorization.RedirectURI != c.PostForm("redirect_uri") {
+		tokenError(c, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	if authorization.CodeChallenge != "" {
+		h := sha256.Sum256([]byte(c.PostForm("code_verifier")))
+		if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(h[:])), []byte(authorization.CodeChallenge)) != 1 {
+			tokenError(c, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+	}
+
+	// A code can only be used once, if it is used again the tokens issued for it are revoked as the code has been leaked
+	now := time.Now()
+	res = db.Model(&models.OAuthAuthorization{}).Where("id = ? AND used_at IS NULL", authorization.ID).Update("used_at", &now)
+	if res.Error != nil || res.RowsAffected != 1 {
+		slog.Warn("Token:CodeReused", "client", client.ID, "user", authorization.UserID)
+		db.Where(&models.OAuthAccessToken{AuthorizationID: authorization.ID}).Delete(&models.OAuthAccessToken{})
+		tokenError(c, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	user := models.User{}
+	user.ID = authorization.UserID
+	res = db.Where(&user).First(&user)
+	if res.Error != nil || user.IsDisabled() {
+		tokenError(c, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	accessToken, err := NewSecret()
+	if err != nil {
+		slog.Error("Token", "error", err)
+		tokenError(c, http.StatusInternalServerError, "server_error")
+		return
+	}
+	scopes := strings.Fields(authorization.Scopes)
+	at := models.OAuthAccessToken{
+		TokenHash:       HashSecret(accessToken),
+		AuthorizationID: authorization.ID,
+		ClientID:        client.ID,
+		UserID:          user.ID,
+		Scopes:          authorization.Scopes,
+		Role:            authorization.Role,
+		ExpiresAt:       now.Add(tokenLifetime),
+	}
+	res = db.Save(&at)
+	if res.Error != nil {
+		slog.Error("Token", "error", res.Error)
+		tokenError(c, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	claims := userClaims(user, scopes, authorization.Role)
+	claims["iss"] = svc.issuer()
+	claims["aud"] = client.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(tokenLifetime).Unix()
+	if authorization.Nonce != "" {
+		claims["nonce"] = authorization.Nonce
+	}
+	idToken, err := svc.sign(claims)
+	if err != nil {
+		slog.Error("Token", "error", err)
+		tokenError(c, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	slog.Info("Token:Issued", "client", client.ID, "user", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenLifetime.Seconds()),
+		"id_token":     idToken,
+		"scope":        authorization.Scopes,
+	})
+}
+
+// sign returns the claims as a compact serialized JWT signed with the current signing key
+func (svc Service) sign(claims map[string]interface{}) (string, error) {
+	key, err := currentKey(svc.env.GetDb())
+	if err != nil {
+		return "", err
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return jws.CompactSerialize()
+}
+
+// UserInfo returns the claims about the user who authorized the access token sent in the Authorization header
+func (svc Service) UserInfo(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	db := svc.env.GetDb()
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	at := models.OAuthAccessToken{TokenHash: HashSecret(strings.TrimSpace(token))}
+	if !found || db.Where(&at).First(&at).Error != nil || at.HasExpired() {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	user := models.User{}
+	user.ID = at.UserID
+	res := db.Where(&user).First(&user)
+	if res.Error != nil || user.IsDisabled() {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	c.JSON(http.StatusOK, userClaims(user, strings.Fields(at.Scopes), at.Role))
+}
@@ -0,0 +1,180 @@
+package idp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testRedirectURI = "https://client.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// testService returns a service backed by an in-memory database with a user and a client, it uses one connection as
+// every connection to an in-memory database has its own data
+func testService(t *testing.T) (*Service, *gorm.DB, models.OAuthClient) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	err = db.AutoMigrate(&models.User{}, &models.Role{}, &models.OAuthClient{}, &models.OAuthAuthorization{},
+		&models.OAuthAccessToken{}, &models.SigningKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Email: "user@example.com"}
+	client := models.OAuthClient{ClientID: "client", SecretHash: HashSecret("secret"), RedirectURIs: testRedirectURI, Scopes: "openid email"}
+	for _, v := range []interface{}{&user, &client} {
+		err = db.Create(v).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := infra.InitLair(db, &infra.Config{BaseURL: "https://example.com/"}, nil, nil, nil)
+	return NewService(env), db, client
+}
+
+// authorize stores an authorization for the client as issueCode would and returns the code
+func authorize(t *testing.T, db *gorm.DB, client models.OAuthClient, challenge string, expires time.Time) string {
+	t.Helper()
+	code, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Create(&models.OAuthAuthorization{
+		CodeHash:      HashSecret(code),
+		ClientID:      client.ID,
+		UserID:        1,
+		RedirectURI:   testRedirectURI,
+		Scopes:        "openid email",
+		CodeChallenge: challenge,
+		ExpiresAt:     expires,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// exchange posts the code to the token endpoint with client_secret_basic and returns the response
+func exchange(svc *Service, code string, verifier string, redirectURI string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURI}}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.Request.SetBasicAuth("client", "secret")
+	svc.Token(c)
+	return w
+}
+
+func TestTokenPKCE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := sha256.Sum256([]byte(testVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+
+	tests := []struct {
+		name        string
+		challenge   string
+		verifier    string
+		redirectURI string
+		expires     time.Duration
+		want        int
+	}{
+		{"no challenge", "", "", testRedirectURI, time.Minute, http.StatusOK},
+		{"matching verifier", challenge, testVerifier, testRedirectURI, time.Minute, http.StatusOK},
+		{"wrong verifier", challenge, testVerifier + "x", testRedirectURI, time.Minute, http.StatusBadRequest},
+		{"missing verifier", challenge, "", testRedirectURI, time.Minute, http.StatusBadRequest},
+		{"challenge sent as verifier", challenge, challenge, testRedirectURI, time.Minute, http.StatusBadRequest},
+		{"other redirect uri", challenge, testVerifier, "https://client.example.com/other", time.Minute, http.StatusBadRequest},
+		{"expired code", challenge, testVerifier, testRedirectURI, -time.Minute, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, db, client := testService(t)
+			code := authorize(t, db, client, tt.challenge, time.Now().Add(tt.expires))
+			w := exchange(svc, code, tt.verifier, tt.redirectURI)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				var res struct{ Error string }
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				if err != nil || res.Error != "invalid_grant" {
+					t.Errorf("error = %q, want invalid_grant", res.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenCodeReuse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc, db, client := testService(t)
+	code := authorize(t, db, client, "", time.Now().Add(time.Minute))
+
+	w := exchange(svc, code, "", testRedirectURI)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first exchange status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var count int64
+	db.Model(&models.OAuthAccessToken{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("%d access tokens after the first exchange, want 1", count)
+	}
+
+	// The second exchange means the code has leaked so the token issued for it is revoked
+	w = exchange(svc, code, "", testRedirectURI)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("second exchange status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	db.Model(&models.OAuthAccessToken{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d access tokens after the code was reused, want 0", count)
+	}
+}
+
+func TestTokenInvalidClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc, db, client := testService(t)
+	code := authorize(t, db, client, "", time.Now().Add(time.Minute))
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {testRedirectURI}}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.Request.SetBasicAuth("client", "wrong")
+	svc.Token(c)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("WWW-Authenticate header is missing")
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"time"
 
 	"github.com/uberswe/golang-base-project/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/rbac"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -56,7 +57,7 @@ func attemptConnection(c *Config) (*gorm.DB, error) {
 }
 
 func MigrateDatabase(db *gorm.DB) error {
-	err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Token{}, &models.Session{}, &models.Website{})
+	err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Token{}, &models.Session{}, &models.Website{}, &models.RecoveryCode{}, &models.Passkey{}, &models.Identity{}, &models.OAuthClient{}, &models.OAuthConsent{}, &models.OAuthAuthorization{}, &models.OAuthAccessToken{}, &models.SigningKey{}, &models.FailedLogin{}, &models.LockoutEvent{}, &models.PasswordHistory{}, &models.RememberToken{}, &models.APIToken{}, &models.Permission{}, &models.AuditEvent{}, &models.RateLimitCounter{}, &models.RateLimitPolicy{}, &models.EmailChange{}, &models.Invitation{})
 	seed(db)
 	return err
 }
@@ -75,13 +76,20 @@ func seed(db *gorm.DB) {
 	}
 
 	for _, role := range roles {
-		res := db.Where(&role).First(&role)
+		// Roles are found by name only as admins can change the description
+		res := db.Where(models.Role{Name: role.Name}).First(&role)
 		// If no record exists we insert
 		if res.Error != nil && res.Error == gorm.ErrRecordNotFound {
 			db.Save(&role)
 		}
 	}
 
+	// The admin role is granted every permission
+	err := rbac.Seed(db)
+	if err != nil {
+		slog.Error("seed", "error", err)
+	}
+
 	// create admin account
 	adminRole := models.Role{}
 	res := db.Where("name='admin'").First(&adminRole)
@@ -91,11 +99,11 @@ func seed(db *gorm.DB) {
 	// make the account active as of now
 	tm := time.Now()
 
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	hashedPassword, _ := passhash.Hash("password", passhash.DefaultParams)
 	userName := "admin@admin.org"
 	adminUser := models.User{
 		Email:       userName,
-		Password:    string(hashedPassword),
+		Password:    hashedPassword,
 		ActivatedAt: &tm,
 	}
 	adminUser.Roles = append(adminUser.Roles, adminRole)
@@ -4,9 +4,13 @@ import (
 	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/ratelimit"
 	"github.com/uberswe/golang-base-project/text"
 	"gorm.io/gorm"
 )
@@ -27,6 +31,7 @@ type ILair interface {
 	GetConfig() *Config
 	GetBundle() *i18n.Bundle
 	GetLoggingLevel() *slog.LevelVar
+	GetRateLimiter() *ratelimit.Limiter
 }
 
 // implement the interface
@@ -34,14 +39,18 @@ func (l Lair) GetDb() *gorm.DB                 { return l.db }
 func (l Lair) GetConfig() *Config              { return l.config }
 func (l Lair) GetBundle() *i18n.Bundle         { return l.bundle }
 func (l Lair) GetLoggingLevel() *slog.LevelVar { return l.leveler }
+func (l Lair) GetRateLimiter() *ratelimit.Limiter {
+	return l.limiter
+}
 
-func InitLair(db *gorm.DB, config *Config, bundle *i18n.Bundle, levelVar *slog.LevelVar) ILair {
+func InitLair(db *gorm.DB, config *Config, bundle *i18n.Bundle, levelVar *slog.LevelVar, limiter *ratelimit.Limiter) ILair {
 	// set the global - should be called once at startup
 	glair = Lair{
 		db:      db,
 		config:  config,
 		bundle:  bundle,
 		leveler: levelVar,
+		limiter: limiter,
 	}
 	return glair
 
@@ -53,6 +62,7 @@ type Lair struct {
 	config  *Config
 	bundle  *i18n.Bundle
 	leveler *slog.LevelVar
+	limiter *ratelimit.Limiter
 }
 
 func LoadEnvVariables() *Config {
@@ -138,5 +148,147 @@ func LoadEnvVariables() *Config {
 		c.CacheMaxAge = i
 	}
 
+	// Failed login limits, see the README for a description of each variable
+	c.LockoutDelayAfter = envInt("LOCKOUT_DELAY_AFTER", 3)
+	c.LockoutMaxAttempts = envInt("LOCKOUT_MAX_ATTEMPTS", 10)
+	c.LockoutIPMaxAttempts = envInt("LOCKOUT_IP_MAX_ATTEMPTS", 50)
+	c.LockoutDuration = envPositiveInt("LOCKOUT_DURATION", 15)
+
+	// Password policy, see the README for a description of each variable
+	c.PasswordMinLength = envInt("PASSWORD_MIN_LENGTH", 8)
+	c.PasswordMaxLength = envInt("PASSWORD_MAX_LENGTH", 72)
+	c.PasswordRequireLower = os.Getenv("PASSWORD_REQUIRE_LOWER") == "true"
+	c.PasswordRequireUpper = os.Getenv("PASSWORD_REQUIRE_UPPER") == "true"
+	c.PasswordRequireDigit = os.Getenv("PASSWORD_REQUIRE_DIGIT") == "true"
+	c.PasswordRequireSymbol = os.Getenv("PASSWORD_REQUIRE_SYMBOL") == "true"
+	c.PasswordHistory = envInt("PASSWORD_HISTORY", 5)
+	c.PasswordBreachedList = os.Getenv("PASSWORD_BREACHED_LIST")
+	c.PasswordArgon2Memory = envInt("PASSWORD_ARGON2_MEMORY", int(passhash.DefaultParams.Memory))
+	c.PasswordArgon2Iterations = envInt("PASSWORD_ARGON2_ITERATIONS", int(passhash.DefaultParams.Iterations))
+	c.PasswordArgon2Parallelism = envInt("PASSWORD_ARGON2_PARALLELISM", int(passhash.DefaultParams.Parallelism))
+	argon2, ok := passhash.ParamsOf(c.PasswordArgon2Memory, c.PasswordArgon2Iterations, c.PasswordArgon2Parallelism)
+	if !ok {
+		slog.Warn("Env:PASSWORD_ARGON2", "error", "memory and iterations must be at least 1 and parallelism between 1 and 255, using the defaults",
+			"memory", c.PasswordArgon2Memory, "iterations", c.PasswordArgon2Iterations, "parallelism", c.PasswordArgon2Parallelism)
+		c.PasswordArgon2Memory = int(argon2.Memory)
+		c.PasswordArgon2Iterations = int(argon2.Iterations)
+		c.PasswordArgon2Parallelism = int(argon2.Parallelism)
+	}
+
+	// Session policy
+	c.SessionIdleTimeout = envPositiveInt("SESSION_IDLE_TIMEOUT", 60)
+	c.SessionLifetime = envPositiveInt("SESSION_LIFETIME", 24)
+	c.RememberMeDays = envPositiveInt("REMEMBER_ME_DAYS", 30)
+	c.AccountDeletionGraceDays = envInt("ACCOUNT_DELETION_GRACE_DAYS", 30)
+
+	// Registration rules, see the README for a description of each variable
+	c.RegistrationMode = os.Getenv("REGISTRATION_MODE")
+	if c.RegistrationMode == "" {
+		c.RegistrationMode = "open"
+	}
+	c.RegistrationAllowedDomains = os.Getenv("REGISTRATION_ALLOWED_DOMAINS")
+	c.RegistrationBlockedDomains = os.Getenv("REGISTRATION_BLOCKED_DOMAINS")
+	c.RegistrationBlockDisposable = os.Getenv("REGISTRATION_BLOCK_DISPOSABLE") != "false"
+	c.RegistrationDisposableList = os.Getenv("REGISTRATION_DISPOSABLE_LIST")
+	c.RegistrationCheckMX = os.Getenv("REGISTRATION_CHECK_MX") == "true"
+
+	// Challenges on public forms which send emails, see the README for a description of each variable
+	c.Challenge = os.Getenv("CHALLENGE")
+	if c.Challenge == "" {
+		c.Challenge = "hashcash"
+	}
+	c.ChallengeDifficulty = envInt("CHALLENGE_DIFFICULTY", 16)
+	c.ChallengeIPThreshold = envInt("CHALLENGE_IP_THRESHOLD", 5)
+	c.ChallengeGlobalThreshold = envInt("CHALLENGE_GLOBAL_THRESHOLD", 60)
+
+	// Rate limits, see the README for a description of each variable
+	c.RateLimitBackend = os.Getenv("RATE_LIMIT_BACKEND")
+	if c.RateLimitBackend == "" {
+		c.RateLimitBackend = ratelimit.BackendMemory
+	}
+	c.RateLimitRedisURL = os.Getenv("RATE_LIMIT_REDIS_URL")
+	if c.RateLimitRedisURL == "" {
+		c.RateLimitRedisURL = "redis://localhost:6379/0"
+	}
+	c.RateLimits = []ratelimit.Policy{
+		{Name: ratelimit.Forms, By: ratelimit.ByIP, Limit: c.RequestsPerMinute, Period: time.Minute},
+		{Name: ratelimit.Email, By: ratelimit.ByEmail, Limit: 10, Period: 15 * time.Minute},
+		{Name: ratelimit.API, By: ratelimit.ByUser, Limit: 300, Period: time.Minute},
+		{Name: ratelimit.Account, By: ratelimit.ByUser, Limit: 10, Period: 15 * time.Minute},
+	}
+	for i, p := range c.RateLimits {
+		c.RateLimits[i] = envRateLimit(p)
+	}
+
+	// OIDC_PROVIDERS is a comma separated list of provider keys, each provider is configured with OIDC_<KEY>_* variables
+	for _, key := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(key) + "_"
+		p := OIDCProvider{
+			Key:          strings.ToLower(key),
+			Name:         os.Getenv(prefix + "NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			TrustEmail:   os.Getenv(prefix+"TRUST_EMAIL") == "true",
+		}
+		if p.Name == "" {
+			p.Name = key
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			slog.Warn("Env:OIDC_PROVIDERS", "error", "issuer and client id are required", "provider", key)
+			continue
+		}
+		c.OIDCProviders = append(c.OIDCProviders, p)
+	}
+
 	return &c
 }
+
+// envInt returns the integer value of an environment variable or the default value if it is not set or invalid
+func envInt(name string, def int) int {
+	if os.Getenv(name) == "" {
+		return def
+	}
+	i, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		slog.Warn("Env:"+name, "error", err)
+		return def
+	}
+	return i
+}
+
+// envPositiveInt returns the value of envInt or the default value if it is less than 1
+func envPositiveInt(name string, def int) int {
+	i := envInt(name, def)
+	if i < 1 {
+		slog.Warn("Env:"+name, "error", "must be at least 1")
+		return def
+	}
+	return i
+}
+
+// envRateLimit returns the policy with the limit and period of its RATE_LIMIT_<NAME> variable, such as 10/15m
+func envRateLimit(p ratelimit.Policy) ratelimit.Policy {
+	name := "RATE_LIMIT_" + strings.ToUpper(p.Name)
+	value := os.Getenv(name)
+	if value == "" {
+		return p
+	}
+	limit, period, found := strings.Cut(value, "/")
+	i, err := strconv.Atoi(limit)
+	if err == nil && found {
+		var d time.Duration
+		d, err = time.ParseDuration(period)
+		if err == nil && i > 0 && d > 0 {
+			p.Limit = i
+			p.Period = d
+			return p
+		}
+	}
+	slog.Warn("Env:"+name, "error", "expected a limit and a period such as 10/15m", "value", value)
+	return p
+}
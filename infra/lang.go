@@ -11,6 +11,21 @@ import (
 	"golang.org/x/text/language"
 )
 
+// LanguageKey is the key used to set and get the language the user of the current request has chosen on their profile
+const LanguageKey = "Language"
+
+// Language is a language which has translations
+type Language struct {
+	Tag  string
+	Name string // the name of the language in the language itself
+}
+
+// Languages are the languages which have translations
+var Languages = []Language{
+	{Tag: "en", Name: "English"},
+	{Tag: "sv", Name: "Svenska"},
+}
+
 type LangService struct {
 	bundle    *i18n.Bundle
 	ctx       *gin.Context
@@ -18,7 +33,8 @@ type LangService struct {
 }
 
 func NewLangService(ctx *gin.Context, bundle *i18n.Bundle) LangService {
-	localizer := i18n.NewLocalizer(bundle, ctx.Request.Header.Get("Accept-Language"), "en")
+	// A language chosen on the profile is preferred over the languages of the browser
+	localizer := i18n.NewLocalizer(bundle, ctx.GetString(LanguageKey), ctx.Request.Header.Get("Accept-Language"), "en")
 	return LangService{
 		bundle:    bundle,
 		ctx:       ctx,
@@ -47,12 +63,8 @@ func (s *LangService) Trans(str string) string {
 func LoadLanguageBundles() *i18n.Bundle {
 	bundle := i18n.NewBundle(language.English)
 	bundle.RegisterUnmarshalFunc("toml", toml.Unmarshal)
-	languages := []string{
-		"en",
-		"sv",
-	}
-	for _, l := range languages {
-		_, err := bundle.LoadMessageFile(fmt.Sprintf("active.%s.toml", l))
+	for _, l := range Languages {
+		_, err := bundle.LoadMessageFile(fmt.Sprintf("active.%s.toml", l.Tag))
 		if err != nil {
 			slog.Error("Run", "error", err)
 			os.Exit(1)
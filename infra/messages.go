@@ -233,4 +233,1436 @@ var translationMessages = []i18n.Message{
 		ID:    "reset_password_message",
 		Other: "Please enter a new password.",
 	},
+	{
+		ID:    "two_factor_authentication",
+		Other: "Two-Factor Authentication",
+	},
+	{
+		ID:    "two_factor_login_message",
+		Other: "Enter the code from your authenticator app. If you have lost access to your authenticator you can enter one of your recovery codes instead.",
+	},
+	{
+		ID:    "authentication_code",
+		Other: "Authentication code",
+	},
+	{
+		ID:    "verify",
+		Other: "Verify",
+	},
+	{
+		ID:    "back_to_login",
+		Other: "Back to login",
+	},
+	{
+		ID:    "two_factor_code_error",
+		Other: "The code you entered is not valid, please try again.",
+	},
+	{
+		ID:    "two_factor_too_many_attempts",
+		Other: "Too many invalid codes were entered, please try again later.",
+	},
+	{
+		ID:    "generic_error",
+		Other: "Something went wrong, please try again.",
+	},
+	{
+		ID:    "two_factor_enabled",
+		Other: "Two-factor authentication has been enabled.",
+	},
+	{
+		ID:    "two_factor_disabled",
+		Other: "Two-factor authentication has been disabled.",
+	},
+	{
+		ID:    "recovery_codes_generated",
+		Other: "New recovery codes have been generated, your old codes can no longer be used.",
+	},
+	{
+		ID:    "recovery_codes_message",
+		Other: "These are your recovery codes. Each code can be used once to login if you lose access to your authenticator app. Store them somewhere safe, they will not be shown again.",
+	},
+	{
+		ID:    "two_factor_is_enabled",
+		Other: "Two-factor authentication is enabled for your account.",
+	},
+	{
+		ID:    "unused_recovery_codes",
+		Other: "Unused recovery codes",
+	},
+	{
+		ID:    "generate_recovery_codes",
+		Other: "Generate new recovery codes",
+	},
+	{
+		ID:    "generate",
+		Other: "Generate",
+	},
+	{
+		ID:    "disable_two_factor",
+		Other: "Disable two-factor authentication",
+	},
+	{
+		ID:    "disable",
+		Other: "Disable",
+	},
+	{
+		ID:    "two_factor_enroll_message",
+		Other: "Scan the QR code below with an authenticator app, or enter the secret manually, and then enter the code shown in the app to enable two-factor authentication.",
+	},
+	{
+		ID:    "secret",
+		Other: "Secret",
+	},
+	{
+		ID:    "enable",
+		Other: "Enable",
+	},
+	{
+		ID:    "account",
+		Other: "Account",
+	},
+	{
+		ID:    "reset_two_factor",
+		Other: "Reset two-factor authentication",
+	},
+	{
+		ID:    "reset_two_factor_message",
+		Other: "Disables two-factor authentication and removes all recovery codes for a user who has lost access to their authenticator.",
+	},
+	{
+		ID:    "reset",
+		Other: "Reset",
+	},
+	{
+		ID:    "user_not_found",
+		Other: "No user with that email address could be found.",
+	},
+	{
+		ID:    "two_factor_reset_success",
+		Other: "Two-factor authentication has been reset for the user.",
+	},
+	{
+		ID:    "passkeys",
+		Other: "Passkeys",
+	},
+	{
+		ID:    "passkeys_message",
+		Other: "Passkeys let you login with your fingerprint, face, screen lock or a security key instead of a password.",
+	},
+	{
+		ID:    "passkey_name_placeholder",
+		Other: "Passkey name, for example My laptop",
+	},
+	{
+		ID:    "add_passkey",
+		Other: "Add a passkey",
+	},
+	{
+		ID:    "name",
+		Other: "Name",
+	},
+	{
+		ID:    "created",
+		Other: "Created",
+	},
+	{
+		ID:    "last_used",
+		Other: "Last used",
+	},
+	{
+		ID:    "rename",
+		Other: "Rename",
+	},
+	{
+		ID:    "remove",
+		Other: "Remove",
+	},
+	{
+		ID:    "no_passkeys",
+		Other: "You have not added any passkeys yet.",
+	},
+	{
+		ID:    "passkey_rename_error",
+		Other: "The passkey could not be renamed.",
+	},
+	{
+		ID:    "passkey_renamed",
+		Other: "The passkey has been renamed.",
+	},
+	{
+		ID:    "passkey_remove_error",
+		Other: "The passkey could not be removed.",
+	},
+	{
+		ID:    "passkey_removed",
+		Other: "The passkey has been removed.",
+	},
+	{
+		ID:    "sign_in_with_passkey",
+		Other: "Sign in with a passkey",
+	},
+	{
+		ID:    "oidc_connect_error",
+		Other: "Could not connect to the login provider, please try again later.",
+	},
+	{
+		ID:    "oidc_login_error",
+		Other: "Could not login with the selected provider, please try again.",
+	},
+	{
+		ID:    "oidc_email_exists",
+		Other: "An account with this email address already exists. Please login with your password and link the provider from your account page.",
+	},
+	{
+		ID:    "linked_accounts",
+		Other: "Linked Accounts",
+	},
+	{
+		ID:    "linked_accounts_message",
+		Other: "Link an external account to login without entering your password.",
+	},
+	{
+		ID:    "link",
+		Other: "Link",
+	},
+	{
+		ID:    "provider",
+		Other: "Provider",
+	},
+	{
+		ID:    "unlink",
+		Other: "Unlink",
+	},
+	{
+		ID:    "no_linked_accounts",
+		Other: "You have not linked any accounts yet.",
+	},
+	{
+		ID:    "account_linked",
+		Other: "The account has been linked.",
+	},
+	{
+		ID:    "account_linked_taken",
+		Other: "This account is already linked to another user.",
+	},
+	{
+		ID:    "account_link_error",
+		Other: "The account could not be linked.",
+	},
+	{
+		ID:    "account_unlink_error",
+		Other: "The account could not be unlinked.",
+	},
+	{
+		ID:    "account_unlinked",
+		Other: "The account has been unlinked.",
+	},
+	{
+		ID:    "sign_in_with",
+		Other: "Sign in with",
+	},
+	{
+		ID:    "authorize",
+		Other: "Authorize",
+	},
+	{
+		ID:    "authorize_invalid_request",
+		Other: "The application sent an invalid login request.",
+	},
+	{
+		ID:    "consent_message",
+		Other: "would like to use your account to sign you in and is requesting access to:",
+	},
+	{
+		ID:    "consent_scope_openid",
+		Other: "Your user identifier",
+	},
+	{
+		ID:    "consent_scope_email",
+		Other: "Your email address",
+	},
+	{
+		ID:    "consent_scope_roles",
+		Other: "Your roles",
+	},
+	{
+		ID:    "allow",
+		Other: "Allow",
+	},
+	{
+		ID:    "deny",
+		Other: "Deny",
+	},
+	{
+		ID:    "clients",
+		Other: "Clients",
+	},
+	{
+		ID:    "clients_message",
+		Other: "Clients are applications which let users login with their account on this website using OpenID Connect.",
+	},
+	{
+		ID:    "client_id",
+		Other: "Client ID",
+	},
+	{
+		ID:    "client_secret",
+		Other: "Client secret",
+	},
+	{
+		ID:    "redirect_uris",
+		Other: "Redirect URIs, one per line",
+	},
+	{
+		ID:    "save",
+		Other: "Save",
+	},
+	{
+		ID:    "new_secret",
+		Other: "New secret",
+	},
+	{
+		ID:    "new_secret_confirm",
+		Other: "Generate a new secret? The old secret will stop working.",
+	},
+	{
+		ID:    "delete",
+		Other: "Delete",
+	},
+	{
+		ID:    "delete_client_confirm",
+		Other: "Delete this client?",
+	},
+	{
+		ID:    "add_client",
+		Other: "Add a client",
+	},
+	{
+		ID:    "client_form_error",
+		Other: "Please enter a name and at least one valid redirect URI.",
+	},
+	{
+		ID:    "client_created",
+		Other: "The client has been created. Copy the secret now, it will not be shown again.",
+	},
+	{
+		ID:    "client_not_found",
+		Other: "The client could not be found.",
+	},
+	{
+		ID:    "client_updated",
+		Other: "The client has been updated.",
+	},
+	{
+		ID:    "client_secret_rotated",
+		Other: "A new secret has been generated. Copy the secret now, it will not be shown again.",
+	},
+	{
+		ID:    "client_deleted",
+		Other: "The client has been deleted.",
+	},
+	{
+		ID:    "magic_link",
+		Other: "Email me a sign-in link",
+	},
+	{
+		ID:    "magic_link_sent",
+		Other: "If we have an account with your email you will receive a sign-in link shortly.",
+	},
+	{
+		ID:    "magic_link_subject",
+		Other: "Sign-in link",
+	},
+	{
+		ID:    "magic_link_email",
+		Other: "Use the following link to login. The link can only be used once and expires in 15 minutes. If this was not requested by you, please ignore this email.\n%s",
+	},
+	{
+		ID:    "magic_link_invalid",
+		Other: "This sign-in link is invalid or has expired, please request a new one.",
+	},
+	{
+		ID:    "magic_link_confirm",
+		Other: "Click the button below to finish signing in.",
+	},
+	{
+		ID:    "sign_in",
+		Other: "Sign in",
+	},
+	{
+		ID:    "magic_link_message",
+		Other: "Enter your email address and we will send you a link which logs you in without a password.",
+	},
+	{
+		ID:    "send_magic_link",
+		Other: "Send sign-in link",
+	},
+	{
+		ID:    "lockout_locked",
+		Other: "Too many failed login attempts. Logins have been temporarily locked, if you have an account we have sent you an email with a link to unlock it.",
+	},
+	{
+		ID:    "lockout_wait",
+		Other: "Too many failed login attempts, please wait %d seconds before trying again.",
+	},
+	{
+		ID:    "account_locked",
+		Other: "Account locked",
+	},
+	{
+		ID:    "account_locked_email",
+		Other: "There were too many failed attempts to login to your account so it has been temporarily locked. If this was you, use the following link to unlock your account. If it was not you, consider changing your password.\n%s",
+	},
+	{
+		ID:    "unlock_account",
+		Other: "Unlock account",
+	},
+	{
+		ID:    "unlock_invalid",
+		Other: "This unlock link is invalid or has expired.",
+	},
+	{
+		ID:    "unlock_success",
+		Other: "Your account has been unlocked, you may now login.",
+	},
+	{
+		ID:    "unlock_confirm",
+		Other: "Click the button below to unlock your account.",
+	},
+	{
+		ID:    "unlock",
+		Other: "Unlock",
+	},
+	{
+		ID:    "lockouts",
+		Other: "Lockouts",
+	},
+	{
+		ID:    "lockouts_message",
+		Other: "Email addresses and IP addresses which were locked after too many failed login attempts.",
+	},
+	{
+		ID:    "locked",
+		Other: "Locked",
+	},
+	{
+		ID:    "email_or_ip",
+		Other: "Email or IP address",
+	},
+	{
+		ID:    "last_ip",
+		Other: "Last IP address",
+	},
+	{
+		ID:    "locked_until",
+		Other: "Locked until",
+	},
+	{
+		ID:    "unlocked_by",
+		Other: "Unlocked by",
+	},
+	{
+		ID:    "no_lockouts",
+		Other: "There have not been any lockouts.",
+	},
+	{
+		ID:    "lockout_not_found",
+		Other: "The lockout could not be found.",
+	},
+	{
+		ID:    "lockout_removed",
+		Other: "The lockout has been removed.",
+	},
+	{
+		ID:    "password_min_length",
+		Other: "Your password must be at least %d characters long.",
+	},
+	{
+		ID:    "password_max_length",
+		Other: "Your password can not be longer than %d characters.",
+	},
+	{
+		ID:    "password_require_lower",
+		Other: "Your password must contain a lowercase letter.",
+	},
+	{
+		ID:    "password_require_upper",
+		Other: "Your password must contain an uppercase letter.",
+	},
+	{
+		ID:    "password_require_digit",
+		Other: "Your password must contain a number.",
+	},
+	{
+		ID:    "password_require_symbol",
+		Other: "Your password must contain a symbol.",
+	},
+	{
+		ID:    "password_similar_email",
+		Other: "Your password is too similar to your email address.",
+	},
+	{
+		ID:    "password_breached",
+		Other: "This password has appeared in a data breach, please choose a different password.",
+	},
+	{
+		ID:    "password_reused",
+		Other: "Your password can not be the same as any of your last %d passwords.",
+	},
+	{
+		ID:    "password_hashes",
+		Other: "Password hashes",
+	},
+	{
+		ID:    "legacy_password_hashes",
+		Other: "Users with a legacy bcrypt password hash",
+	},
+	{
+		ID:    "legacy_password_hashes_info",
+		Other: "Legacy hashes are replaced with Argon2id hashes when the user logs in the next time.",
+	},
+	{
+		ID:    "sessions",
+		Other: "Sessions",
+	},
+	{
+		ID:    "sessions_info",
+		Other: "These are the devices which are currently logged in to your account. Revoke any session you do not recognize.",
+	},
+	{
+		ID:    "device",
+		Other: "Device",
+	},
+	{
+		ID:    "ip_address",
+		Other: "IP address",
+	},
+	{
+		ID:    "last_seen",
+		Other: "Last seen",
+	},
+	{
+		ID:    "this_device",
+		Other: "This device",
+	},
+	{
+		ID:    "revoke",
+		Other: "Revoke",
+	},
+	{
+		ID:    "unknown_device",
+		Other: "Unknown device",
+	},
+	{
+		ID:    "revoke_others_confirm",
+		Other: "Log out all other sessions?",
+	},
+	{
+		ID:    "revoke_others",
+		Other: "Revoke all other sessions",
+	},
+	{
+		ID:    "session_revoke_error",
+		Other: "The session could not be revoked.",
+	},
+	{
+		ID:    "session_revoked",
+		Other: "The session has been revoked.",
+	},
+	{
+		ID:    "sessions_revoked",
+		Other: "All other sessions have been revoked.",
+	},
+	{
+		ID:    "remember_me",
+		Other: "Remember me",
+	},
+	{
+		ID:    "api_tokens",
+		Other: "API Tokens",
+	},
+	{
+		ID:    "api_tokens_info",
+		Other: "Personal access tokens let scripts call this website on your behalf. Send the token in an Authorization: Bearer header.",
+	},
+	{
+		ID:    "token_name_placeholder",
+		Other: "Token name, for example Backup script",
+	},
+	{
+		ID:    "expires",
+		Other: "Expires",
+	},
+	{
+		ID:    "in_30_days",
+		Other: "In 30 days",
+	},
+	{
+		ID:    "in_90_days",
+		Other: "In 90 days",
+	},
+	{
+		ID:    "in_a_year",
+		Other: "In a year",
+	},
+	{
+		ID:    "never",
+		Other: "Never",
+	},
+	{
+		ID:    "create_token",
+		Other: "Create a token",
+	},
+	{
+		ID:    "token",
+		Other: "Token",
+	},
+	{
+		ID:    "scopes",
+		Other: "Scopes",
+	},
+	{
+		ID:    "expired",
+		Other: "Expired",
+	},
+	{
+		ID:    "revoke_token_confirm",
+		Other: "Revoke this token? Scripts using it will stop working.",
+	},
+	{
+		ID:    "no_tokens",
+		Other: "You have not created any tokens yet.",
+	},
+	{
+		ID:    "token_create_error",
+		Other: "The token could not be created.",
+	},
+	{
+		ID:    "token_invalid",
+		Other: "Please enter a name and choose at least one scope.",
+	},
+	{
+		ID:    "token_created",
+		Other: "Your token has been created. Copy it now, it will not be shown again.",
+	},
+	{
+		ID:    "token_revoke_error",
+		Other: "The token could not be revoked.",
+	},
+	{
+		ID:    "token_revoked",
+		Other: "The token has been revoked.",
+	},
+	{
+		ID:    "users",
+		Other: "Users",
+	},
+	{
+		ID:    "all_roles",
+		Other: "All roles",
+	},
+	{
+		ID:    "all_states",
+		Other: "All states",
+	},
+	{
+		ID:    "active",
+		Other: "Active",
+	},
+	{
+		ID:    "not_activated",
+		Other: "Not activated",
+	},
+	{
+		ID:    "disabled",
+		Other: "Disabled",
+	},
+	{
+		ID:    "deleted",
+		Other: "Deleted",
+	},
+	{
+		ID:    "created_from",
+		Other: "Created from",
+	},
+	{
+		ID:    "created_to",
+		Other: "Created to",
+	},
+	{
+		ID:    "filter",
+		Other: "Filter",
+	},
+	{
+		ID:    "users_count",
+		Other: "users",
+	},
+	{
+		ID:    "email",
+		Other: "Email",
+	},
+	{
+		ID:    "roles",
+		Other: "Roles",
+	},
+	{
+		ID:    "state",
+		Other: "State",
+	},
+	{
+		ID:    "previous",
+		Other: "Previous",
+	},
+	{
+		ID:    "next",
+		Other: "Next",
+	},
+	{
+		ID:    "activated",
+		Other: "Activated",
+	},
+	{
+		ID:    "enabled",
+		Other: "Enabled",
+	},
+	{
+		ID:    "restore_this_user",
+		Other: "Restore this user?",
+	},
+	{
+		ID:    "restore",
+		Other: "Restore",
+	},
+	{
+		ID:    "change_roles_of_user",
+		Other: "Change the roles of this user?",
+	},
+	{
+		ID:    "actions",
+		Other: "Actions",
+	},
+	{
+		ID:    "activate_this_user",
+		Other: "Activate this user?",
+	},
+	{
+		ID:    "confirm_force_password_reset",
+		Other: "Reset the password of this user? The user will be logged out and emailed a link to choose a new password.",
+	},
+	{
+		ID:    "force_password_reset",
+		Other: "Force password reset",
+	},
+	{
+		ID:    "enable_this_user",
+		Other: "Enable this user?",
+	},
+	{
+		ID:    "confirm_disable_user",
+		Other: "Disable this user? The user will be logged out and can not log in until enabled again.",
+	},
+	{
+		ID:    "confirm_delete_user",
+		Other: "Delete this user? The user can be restored later.",
+	},
+	{
+		ID:    "admin_user_not_found",
+		Other: "The user could not be found.",
+	},
+	{
+		ID:    "not_allowed_on_own_account",
+		Other: "You can not do this to your own account.",
+	},
+	{
+		ID:    "user_roles_updated",
+		Other: "The roles of the user have been updated.",
+	},
+	{
+		ID:    "user_already_activated",
+		Other: "The user is already activated.",
+	},
+	{
+		ID:    "user_activated",
+		Other: "The user has been activated.",
+	},
+	{
+		ID:    "user_password_reset_forced",
+		Other: "The password has been reset and the user has been emailed a link to choose a new one.",
+	},
+	{
+		ID:    "forced_password_reset_email",
+		Other: "An administrator has reset the password of your account. Use the following link to choose a new password, the link expires in 24 hours.\n%s",
+	},
+	{
+		ID:    "user_disabled",
+		Other: "The user has been disabled.",
+	},
+	{
+		ID:    "user_enabled",
+		Other: "The user has been enabled.",
+	},
+	{
+		ID:    "user_deleted",
+		Other: "The user has been deleted.",
+	},
+	{
+		ID:    "user_restored",
+		Other: "The user has been restored.",
+	},
+	{
+		ID:    "account_disabled",
+		Other: "This account has been disabled.",
+	},
+	{
+		ID:    "roles_description",
+		Other: "Roles are granted permissions and users get the permissions of all their roles.",
+	},
+	{
+		ID:    "field_description",
+		Other: "Description",
+	},
+	{
+		ID:    "members",
+		Other: "Members",
+	},
+	{
+		ID:    "built_in",
+		Other: "Built in",
+	},
+	{
+		ID:    "add_a_role",
+		Other: "Add a role",
+	},
+	{
+		ID:    "confirm_create_role",
+		Other: "Create this role?",
+	},
+	{
+		ID:    "confirm_save_role",
+		Other: "Save the changes to this role?",
+	},
+	{
+		ID:    "confirm_remove_role_members",
+		Other: "Remove the selected users from this role?",
+	},
+	{
+		ID:    "remove_selected",
+		Other: "Remove selected",
+	},
+	{
+		ID:    "role_has_no_members",
+		Other: "No users have this role.",
+	},
+	{
+		ID:    "confirm_add_role_members",
+		Other: "Give this role to the users?",
+	},
+	{
+		ID:    "add_users_by_email",
+		Other: "Add users by email address, one per line",
+	},
+	{
+		ID:    "add_users",
+		Other: "Add users",
+	},
+	{
+		ID:    "confirm_delete_role",
+		Other: "Delete this role? It will be removed from all its members.",
+	},
+	{
+		ID:    "delete_role",
+		Other: "Delete role",
+	},
+	{
+		ID:    "role_not_found",
+		Other: "The role could not be found.",
+	},
+	{
+		ID:    "role_updated",
+		Other: "The role has been updated.",
+	},
+	{
+		ID:    "role_deleted",
+		Other: "The role has been deleted.",
+	},
+	{
+		ID:    "role_members_added",
+		Other: "%d users were given the role.",
+	},
+	{
+		ID:    "role_emails_not_found",
+		Other: "%d email addresses did not match a user.",
+	},
+	{
+		ID:    "role_members_removed",
+		Other: "%d users were removed from the role.",
+	},
+	{
+		ID:    "role_name_invalid",
+		Other: "The role needs a name which is not used by another role.",
+	},
+	{
+		ID:    "role_built_in",
+		Other: "The admin and user roles can not be renamed or deleted.",
+	},
+	{
+		ID:    "last_admin",
+		Other: "This would leave no user who can manage roles.",
+	},
+	{
+		ID:    "impersonation_banner",
+		Other: "You are viewing the website as another user. Account settings can not be changed while impersonating.",
+	},
+	{
+		ID:    "return_to_admin",
+		Other: "Return to admin",
+	},
+	{
+		ID:    "not_available_impersonating",
+		Other: "Not available while impersonating",
+	},
+	{
+		ID:    "confirm_impersonate",
+		Other: "Log in as this user? The impersonation is recorded in the audit log.",
+	},
+	{
+		ID:    "impersonate",
+		Other: "Log in as user",
+	},
+	{
+		ID:    "already_impersonating",
+		Other: "You are already impersonating a user.",
+	},
+	{
+		ID:    "impersonate_disabled",
+		Other: "Deleted and disabled users can not be impersonated.",
+	},
+	{
+		ID:    "impersonate_more_permissions",
+		Other: "Users with permissions you do not have can not be impersonated.",
+	},
+	{
+		ID:    "audit_log",
+		Other: "Audit log",
+	},
+	{
+		ID:    "all_actions",
+		Other: "All actions",
+	},
+	{
+		ID:    "actor_email_or_id",
+		Other: "Actor email or id",
+	},
+	{
+		ID:    "subject_email_or_id",
+		Other: "Subject email or id",
+	},
+	{
+		ID:    "request_id",
+		Other: "Request ID",
+	},
+	{
+		ID:    "audit_events",
+		Other: "events",
+	},
+	{
+		ID:    "export_csv",
+		Other: "Export CSV",
+	},
+	{
+		ID:    "export_json",
+		Other: "Export JSON",
+	},
+	{
+		ID:    "verify_chain",
+		Other: "Verify chain",
+	},
+	{
+		ID:    "events_checked",
+		Other: "events checked",
+	},
+	{
+		ID:    "audit_time",
+		Other: "Time",
+	},
+	{
+		ID:    "audit_action",
+		Other: "Action",
+	},
+	{
+		ID:    "audit_actor",
+		Other: "Actor",
+	},
+	{
+		ID:    "audit_subject",
+		Other: "Subject",
+	},
+	{
+		ID:    "audit_details",
+		Other: "Details",
+	},
+	{
+		ID:    "audit_chain_broken",
+		Other: "The audit log has been tampered with, the chain is broken at this event:",
+	},
+	{
+		ID:    "audit_chain_intact",
+		Other: "The audit log is intact.",
+	},
+	{
+		ID:    "form_expired",
+		Other: "Form expired",
+	},
+	{
+		ID:    "form_expired_text",
+		Other: "The form could not be sent because it has expired or did not come from this website. Go back, reload the page and try again.",
+	},
+	{
+		ID:    "challenge_working",
+		Other: "Checking your browser, the form can be sent in a moment.",
+	},
+	{
+		ID:    "challenge_done",
+		Other: "Your browser has been checked.",
+	},
+	{
+		ID:    "challenge_failed",
+		Other: "Please wait until your browser has been checked and send the form again.",
+	},
+	{
+		ID:    "rate_limits",
+		Other: "Rate limits",
+	},
+	{
+		ID:    "rate_limit_backend",
+		Other: "Counts are kept in the backend:",
+	},
+	{
+		ID:    "requests_per",
+		Other: "requests per",
+	},
+	{
+		ID:    "rate_limit_period",
+		Other: "Period",
+	},
+	{
+		ID:    "rate_limit_invalid",
+		Other: "Rate limits must be a positive number of requests and a positive period.",
+	},
+	{
+		ID:    "rate_limit_changed",
+		Other: "Rate limit changed",
+	},
+	{
+		ID:    "change_email",
+		Other: "Change Email",
+	},
+	{
+		ID:    "email_change_failed",
+		Other: "Could not change your email, please make sure the email is correct and that it is not used by another account.",
+	},
+	{
+		ID:    "password_not_correct",
+		Other: "The password you entered is not correct.",
+	},
+	{
+		ID:    "email_change_sent",
+		Other: "We have sent a confirmation link to your new email. Your email will be changed once you have used the link.",
+	},
+	{
+		ID:    "confirm_new_email_subject",
+		Other: "Confirm your new email",
+	},
+	{
+		ID:    "confirm_new_email_body",
+		Other: "Use the following link to confirm this address as the new email of your account. If this was not requested by you, please ignore this email.\n%s",
+	},
+	{
+		ID:    "email_changing_subject",
+		Other: "Your email is being changed",
+	},
+	{
+		ID:    "email_changing_body",
+		Other: "A request was made to change the email of your account to %s. If this was not you, use the following link within 7 days to keep your current email and log out all sessions.\n%s",
+	},
+	{
+		ID:    "confirm_new_email",
+		Other: "Confirm new email",
+	},
+	{
+		ID:    "confirm_new_email_text",
+		Other: "Click the button below to confirm the new email of your account.",
+	},
+	{
+		ID:    "confirm",
+		Other: "Confirm",
+	},
+	{
+		ID:    "email_confirm_invalid",
+		Other: "This confirmation link is invalid or has expired.",
+	},
+	{
+		ID:    "email_changed",
+		Other: "Your email has been changed.",
+	},
+	{
+		ID:    "revert_email_change",
+		Other: "Revert email change",
+	},
+	{
+		ID:    "revert_email_text",
+		Other: "Click the button below to keep your previous email and log out all sessions of your account.",
+	},
+	{
+		ID:    "revert",
+		Other: "Revert",
+	},
+	{
+		ID:    "link_invalid",
+		Other: "This link is invalid or has expired.",
+	},
+	{
+		ID:    "previous_email_taken",
+		Other: "Your previous email is now used by another account and can not be restored, please contact support.",
+	},
+	{
+		ID:    "email_change_reverted",
+		Other: "The email change has been reverted and all sessions have been logged out. If you did not request the change, please reset your password.",
+	},
+	{
+		ID:    "current_email_is",
+		Other: "Your current email is",
+	},
+	{
+		ID:    "confirmation_link_sent_to",
+		Other: "A confirmation link has been sent to",
+	},
+	{
+		ID:    "email_changed_once_used",
+		Other: "Your email will be changed once the link has been used.",
+	},
+	{
+		ID:    "new_email",
+		Other: "New email",
+	},
+	{
+		ID:    "current_password",
+		Other: "Current password",
+	},
+	{
+		ID:    "send_confirmation_link",
+		Other: "Send confirmation link",
+	},
+	{
+		ID:    "your_data",
+		Other: "Your Data",
+	},
+	{
+		ID:    "download_my_data",
+		Other: "Download my data",
+	},
+	{
+		ID:    "download_my_data_text",
+		Other: "Download a copy of the data we hold about you, such as your account, sessions, passkeys, linked accounts, API tokens and activity. Passwords and secrets are not included.",
+	},
+	{
+		ID:    "download_zip",
+		Other: "Download ZIP",
+	},
+	{
+		ID:    "download_json",
+		Other: "Download JSON",
+	},
+	{
+		ID:    "delete_my_account",
+		Other: "Delete my account",
+	},
+	{
+		ID:    "delete_account_text",
+		Other: "Deleting your account logs you out everywhere. We send you an email with a link which restores your account within %d days, after that your sessions, tokens and sign-in methods are removed for good.",
+	},
+	{
+		ID:    "delete_account_confirm",
+		Other: "Delete your account?",
+	},
+	{
+		ID:    "account_deleted_message",
+		Other: "Your account has been deleted. We have sent you an email with a link which restores your account within %d days.",
+	},
+	{
+		ID:    "account_deleted_subject",
+		Other: "Your account has been deleted",
+	},
+	{
+		ID:    "account_deleted_body",
+		Other: "Your account has been deleted and its data will be removed in %d days. If you change your mind, use the following link to restore your account before then.\n%s",
+	},
+	{
+		ID:    "restore_account",
+		Other: "Restore account",
+	},
+	{
+		ID:    "restore_account_text",
+		Other: "Click the button below to restore your account.",
+	},
+	{
+		ID:    "restore_email_taken",
+		Other: "Your email is now used by another account and the account can not be restored, please contact support.",
+	},
+	{
+		ID:    "account_restored",
+		Other: "Your account has been restored, you may now login.",
+	},
+	{
+		ID:    "profile",
+		Other: "Profile",
+	},
+	{
+		ID:    "display_name",
+		Other: "Display name",
+	},
+	{
+		ID:    "language",
+		Other: "Language",
+	},
+	{
+		ID:    "same_as_browser",
+		Other: "Same as the browser",
+	},
+	{
+		ID:    "time_zone",
+		Other: "Time zone",
+	},
+	{
+		ID:    "time_zone_placeholder",
+		Other: "Same as the server, for example Europe/Stockholm",
+	},
+	{
+		ID:    "theme",
+		Other: "Theme",
+	},
+	{
+		ID:    "change_password",
+		Other: "Change password",
+	},
+	{
+		ID:    "new_password",
+		Other: "New password",
+	},
+	{
+		ID:    "confirm_new_password",
+		Other: "Confirm new password",
+	},
+	{
+		ID:    "check_values",
+		Other: "Please check the values you have entered.",
+	},
+	{
+		ID:    "profile_saved",
+		Other: "Your profile has been saved.",
+	},
+	{
+		ID:    "passwords_do_not_match",
+		Other: "The new passwords do not match.",
+	},
+	{
+		ID:    "password_changed_subject",
+		Other: "Your password has been changed",
+	},
+	{
+		ID:    "password_changed_body",
+		Other: "The password of your account has been changed. If this was not you, reset your password right away and review the sessions of your account.",
+	},
+	{
+		ID:    "password_changed",
+		Other: "Your password has been changed. Other sessions have been logged out.",
+	},
+	{
+		ID:    "theme_light",
+		Other: "Light",
+	},
+	{
+		ID:    "theme_dark",
+		Other: "Dark",
+	},
+	{
+		ID:    "invitations",
+		Other: "Invitations",
+	},
+	{
+		ID:    "invite_users",
+		Other: "Invite users",
+	},
+	{
+		ID:    "registration_invite_only",
+		Other: "Registration is by invitation only.",
+	},
+	{
+		ID:    "registration_open_invitations",
+		Other: "Anyone can register, invitations give the invited users their role right away.",
+	},
+	{
+		ID:    "invite_emails_label",
+		Other: "Email addresses, one per line",
+	},
+	{
+		ID:    "role_label",
+		Other: "Role",
+	},
+	{
+		ID:    "invite_expires_after",
+		Other: "Expires after (days)",
+	},
+	{
+		ID:    "send_invitations",
+		Other: "Send invitations",
+	},
+	{
+		ID:    "all_invitations",
+		Other: "All invitations",
+	},
+	{
+		ID:    "invitation_pending",
+		Other: "Pending",
+	},
+	{
+		ID:    "invitation_accepted",
+		Other: "Accepted",
+	},
+	{
+		ID:    "invitation_revoked",
+		Other: "Revoked",
+	},
+	{
+		ID:    "invited_by",
+		Other: "Invited by",
+	},
+	{
+		ID:    "no_invitations",
+		Other: "No invitations have been sent.",
+	},
+	{
+		ID:    "invite_emails_invalid",
+		Other: "These email addresses are not valid: %s",
+	},
+	{
+		ID:    "invite_emails_count",
+		Other: "Please enter between 1 and %d email addresses.",
+	},
+	{
+		ID:    "invite_role_forbidden",
+		Other: "Users can not be invited to a role with permissions you do not have.",
+	},
+	{
+		ID:    "invitations_sent",
+		Other: "%d invitations have been sent.",
+	},
+	{
+		ID:    "invite_emails_registered",
+		Other: "These email addresses already have an account and were not invited: %s",
+	},
+	{
+		ID:    "invitation_email_subject",
+		Other: "You have been invited",
+	},
+	{
+		ID:    "invitation_email_body",
+		Other: "You have been invited to create an account. Use the following link to register before %s.\n%s",
+	},
+	{
+		ID:    "invitation_not_pending",
+		Other: "The invitation is no longer pending.",
+	},
+	{
+		ID:    "invitation_revoked_message",
+		Other: "The invitation has been revoked.",
+	},
+	{
+		ID:    "registration_open",
+		Other: "Anyone can register",
+	},
+	{
+		ID:    "registration_mode_changed",
+		Other: "Registration mode changed",
+	},
+	{
+		ID:    "invitation_invalid",
+		Other: "This invitation is invalid or has expired.",
+	},
+	{
+		ID:    "invitation_email_mismatch",
+		Other: "Please register with the email the invitation was sent to.",
+	},
+	{
+		ID:    "registered_with_invitation",
+		Other: "Thank you for registering, you may now login.",
+	},
+	{
+		ID:    "registration_invite",
+		Other: "Only with an invitation",
+	},
+	{
+		ID:    "registration_allowed_domains_changed",
+		Other: "Allowed registration domains changed",
+	},
+	{
+		ID:    "registration_blocked_domains_changed",
+		Other: "Blocked registration domains changed",
+	},
+	{
+		ID:    "registration_block_disposable_changed",
+		Other: "Blocking of disposable email domains changed",
+	},
+	{
+		ID:    "registration_check_mx_changed",
+		Other: "Mail server check changed",
+	},
+	{
+		ID:    "role_grant_forbidden",
+		Other: "You can not change users or give roles with permissions you do not have.",
+	},
+	{
+		ID:    "permission_grant_forbidden",
+		Other: "You can not grant permissions you do not have.",
+	},
+	{
+		ID:    "user_restore_email_taken",
+		Other: "The email of the user is now used by another account and the user can not be restored.",
+	},
+	{
+		ID:    "config_value_too_small",
+		Other: "%s must be at least %d.",
+	},
+	{
+		ID:    "confirm_your_identity",
+		Other: "Confirm your identity",
+	},
+	{
+		ID:    "confirm_identity_description",
+		Other: "Please enter your password again before changing how you login to your account.",
+	},
+	{
+		ID:    "confirm_identity_code",
+		Other: "Or enter a code from your authenticator app.",
+	},
+	{
+		ID:    "passkey_added_subject",
+		Other: "A passkey has been added to your account",
+	},
+	{
+		ID:    "passkey_added_body",
+		Other: "The passkey %s has been added to your account and can be used to login. If this was not you, remove the passkey, change your password and review the sessions of your account.",
+	},
+	{
+		ID:    "rate_limits_saved",
+		Other: "Changed limits are saved in the database and used by every instance within a minute.",
+	},
+	{
+		ID:    "redirect_uri_https",
+		Other: "Redirect URIs must use https, http is only allowed for localhost.",
+	},
+	{
+		ID:    "identity_link_confirm",
+		Other: "Please confirm your password again before linking an account.",
+	},
 }
@@ -1,6 +1,8 @@
 // Package config defines the env configuration variables
 package infra
 
+import "github.com/uberswe/golang-base-project/ratelimit"
+
 // Config defines all the configuration variables for the golang-base-project
 type Config struct {
 	LogLevel          string
@@ -21,4 +23,75 @@ type Config struct {
 	RequestsPerMinute int
 	CacheParameter    string
 	CacheMaxAge       int
+	OIDCProviders     []OIDCProvider
+	// LockoutDelayAfter is the number of failed logins after which each further attempt has to wait longer
+	LockoutDelayAfter int
+	// LockoutMaxAttempts is the number of failed logins after which an account is locked
+	LockoutMaxAttempts int
+	// LockoutIPMaxAttempts is the number of failed logins from one IP address after which the address is locked
+	LockoutIPMaxAttempts int
+	// LockoutDuration is how many minutes a lock lasts, failures older than this are also forgotten
+	LockoutDuration int
+	// PasswordMinLength and PasswordMaxLength are the allowed number of characters in a password, 0 disables the maximum
+	PasswordMinLength int
+	PasswordMaxLength int
+	// PasswordRequireLower, PasswordRequireUpper, PasswordRequireDigit and PasswordRequireSymbol require a character of each class
+	PasswordRequireLower  bool
+	PasswordRequireUpper  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	// PasswordHistory is how many previous passwords of a user can not be reused
+	PasswordHistory int
+	// PasswordBreachedList is the path to a sorted file of SHA-1 hashes of breached passwords, empty disables the check
+	PasswordBreachedList string
+	// PasswordArgon2Memory (KiB), PasswordArgon2Iterations and PasswordArgon2Parallelism are the Argon2id parameters for new password hashes
+	PasswordArgon2Memory      int
+	PasswordArgon2Iterations  int
+	PasswordArgon2Parallelism int
+	// SessionIdleTimeout is how many minutes a session stays valid without being used
+	SessionIdleTimeout int
+	// SessionLifetime is how many hours a session is valid at most
+	SessionLifetime int
+	// RememberMeDays is how many days a remember me token is valid without being used, 0 disables remember me
+	RememberMeDays int
+	// AccountDeletionGraceDays is how many days a deleted account can be restored before its sessions and tokens are purged
+	AccountDeletionGraceDays int
+	// RegistrationMode is "open" when anyone can register or "invite" when registering needs an invitation
+	RegistrationMode string
+	// RegistrationAllowedDomains and RegistrationBlockedDomains are comma separated email domains, when domains are
+	// allowed only emails of those domains and their subdomains can register
+	RegistrationAllowedDomains string
+	RegistrationBlockedDomains string
+	// RegistrationBlockDisposable blocks the domains of disposable email services from registering
+	RegistrationBlockDisposable bool
+	// RegistrationDisposableList is the path to a list of disposable email domains, empty uses the bundled list
+	RegistrationDisposableList string
+	// RegistrationCheckMX only lets emails register when their domain has a mail server
+	RegistrationCheckMX bool
+	// Challenge is the kind of challenge asked on public forms which send emails, "hashcash" or "off"
+	Challenge string
+	// ChallengeDifficulty is the number of zero bits the hashcash proof-of-work needs
+	ChallengeDifficulty int
+	// ChallengeIPThreshold and ChallengeGlobalThreshold are the requests per minute from one IP address and from everyone
+	// after which a challenge is asked, 0 always asks for one
+	ChallengeIPThreshold     int
+	ChallengeGlobalThreshold int
+	// RateLimitBackend is where rate limit counts are kept, "memory", "sql" or "redis"
+	RateLimitBackend string
+	// RateLimitRedisURL is the Redis server used by the redis backend
+	RateLimitRedisURL string
+	// RateLimits are the rate limit policies, the limit of the forms policy is RequestsPerMinute
+	RateLimits []ratelimit.Policy
+}
+
+// OIDCProvider holds the settings of an external OpenID Connect provider which users can login with
+type OIDCProvider struct {
+	Key          string // used in urls and to link identities, for example "google"
+	Name         string // shown on the login button
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// TrustEmail links an existing account to a new identity when the provider has verified the email of the account.
+	// Off by default, as anyone who controls the email at the provider would get the account.
+	TrustEmail bool
 }
@@ -0,0 +1,97 @@
+// Package invite creates and accepts the invitations which admins send. While registration is invite only, people can
+// only register with the link of an invitation sent to their email.
+package invite
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// The registration modes
+const (
+	// ModeOpen lets anyone register
+	ModeOpen = "open"
+	// ModeInvite only lets people with an invitation register, new users can not be created by logging in with an
+	// external provider either
+	ModeInvite = "invite"
+)
+
+// Modes are all the registration modes
+var Modes = []string{ModeOpen, ModeInvite}
+
+// ErrInvalid is returned when an invitation does not exist or has been accepted, revoked or has expired
+var ErrInvalid = errors.New("invite: invitation is invalid or has expired")
+
+// New returns a new token, which is only sent in the invitation email, and the hash which is stored
+func New() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, Hash(token), nil
+}
+
+// Hash returns the hash of a token which is used to look up the invitation
+func Hash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// Find returns the pending invitation of the token together with its role
+func Find(db *gorm.DB, token string) (models.Invitation, error) {
+	invitation := models.Invitation{}
+	if token == "" {
+		return invitation, ErrInvalid
+	}
+	res := db.Preload("Role").Where("token_hash = ?", Hash(token)).First(&invitation)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return invitation, ErrInvalid
+	}
+	if res.Error != nil {
+		return invitation, res.Error
+	}
+	if invitation.Status() != models.InvitationPending || invitation.Role.ID == 0 {
+		return invitation, ErrInvalid
+	}
+	return invitation, nil
+}
+
+// Accept marks the invitation as accepted by the user. It is meant to be called in the transaction which creates the
+// user, ErrInvalid is returned if the invitation was accepted or revoked after it was found so that it is only used once.
+func Accept(tx *gorm.DB, invitation models.Invitation, userID uint) error {
+	now := time.Now()
+	res := tx.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+		Updates(map[string]interface{}{"accepted_at": &now, "user_id": userID})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Revoke stops a pending invitation from being accepted, ErrInvalid is returned if it is no longer pending
+func Revoke(db *gorm.DB, invitation models.Invitation) error {
+	now := time.Now()
+	res := db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Update("revoked_at", &now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ErrInvalid
+	}
+	return nil
+}
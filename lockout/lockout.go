@@ -0,0 +1,207 @@
+// Package lockout counts failed logins per email address and per IP address. After a number of failures each attempt
+// has to wait longer than the previous one and eventually the email address or IP address is locked for a while.
+// Invalid second factor codes are counted per user in the same way.
+package lockout
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// maxDelay caps the progressive delay between attempts
+const maxDelay = 5 * time.Minute
+
+// SecondFactorMaxAttempts is how many invalid codes can be entered for a user before the second factor is locked
+const SecondFactorMaxAttempts = 5
+
+// Status describes whether a login attempt may be made
+type Status struct {
+	Locked     bool
+	RetryAfter time.Duration // the time to wait before the next attempt when not locked
+}
+
+// Allowed returns true if the password may be checked
+func (s Status) Allowed() bool {
+	return !s.Locked && s.RetryAfter <= 0
+}
+
+// EmailKey returns the key used to count failed logins for an email address
+func EmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey returns the key used to count failed logins from an IP address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// SecondFactorKey returns the key used to count invalid second factor codes entered for a user
+func SecondFactorKey(userID uint) string {
+	return "2fa:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// window returns how long failures are remembered and how long a lock lasts
+func window(conf *infra.Config) time.Duration {
+	return time.Duration(conf.LockoutDuration) * time.Minute
+}
+
+// Check returns the combined status of the email address and IP address. The email address is counted whether or not
+// an account exists so that the responses do not reveal which accounts exist.
+func Check(db *gorm.DB, email string, ip string) (Status, error) {
+	status := Status{}
+	var counters []models.FailedLogin
+	res := db.Where("lock_key IN ?", []string{EmailKey(email), IPKey(ip)}).Find(&counters)
+	if res.Error != nil {
+		return status, res.Error
+	}
+	now := time.Now()
+	for _, f := range counters {
+		if f.IsLocked() {
+			status.Locked = true
+		}
+		if wait := f.NextAttemptAt.Sub(now); wait > status.RetryAfter {
+			status.RetryAfter = wait
+		}
+	}
+	return status, nil
+}
+
+// Fail records a failed login for the email address and IP address. Events are returned for the keys which became
+// locked by this failure.
+func Fail(db *gorm.DB, conf *infra.Config, email string, ip string) ([]models.LockoutEvent, error) {
+	var events []models.LockoutEvent
+	// Delays are only applied per email address as many users can share an IP address
+	event, err := fail(db, conf, EmailKey(email), conf.LockoutMaxAttempts, true, ip)
+	if err == nil && event != nil {
+		events = append(events, *event)
+	}
+	if err != nil {
+		return events, err
+	}
+	event, err = fail(db, conf, IPKey(ip), conf.LockoutIPMaxAttempts, false, ip)
+	if event != nil {
+		events = append(events, *event)
+	}
+	return events, err
+}
+
+func fail(db *gorm.DB, conf *infra.Config, key string, max int, delayed bool, ip string) (*models.LockoutEvent, error) {
+	now := time.Now()
+	f := models.FailedLogin{LockKey: key}
+	res := db.Where(&f).FirstOrCreate(&f)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	// Counting starts over once a lock has expired or the last failure is older than the window
+	if (f.LockedUntil != nil && !f.IsLocked()) || f.LastFailedAt.Before(now.Add(-window(conf))) {
+		res = db.Model(&f).Updates(map[string]interface{}{"count": 0, "locked_until": nil})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+	}
+
+	// The counter is incremented in the database so that concurrent requests are all counted
+	res = db.Model(&f).Updates(map[string]interface{}{"count": gorm.Expr("count + 1"), "last_failed_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	res = db.First(&f, f.ID)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	updates := map[string]interface{}{}
+	if delayed {
+		updates["next_attempt_at"] = now.Add(delay(conf, f.Count))
+	}
+	var event *models.LockoutEvent
+	if max > 0 && f.Count >= max && !f.IsLocked() {
+		lockedUntil := now.Add(window(conf))
+		updates["locked_until"] = &lockedUntil
+		event = &models.LockoutEvent{LockKey: key, IP: ip, LockedUntil: lockedUntil}
+		if email, ok := strings.CutPrefix(key, "email:"); ok {
+			user := models.User{}
+			if db.Where("LOWER(email) = ?", email).First(&user).Error == nil {
+				event.UserID = user.ID
+			}
+		} else if id, ok := strings.CutPrefix(key, "2fa:"); ok {
+			userID, _ := strconv.ParseUint(id, 10, 64)
+			event.UserID = uint(userID)
+		}
+		res = db.Save(event)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+	}
+	if len(updates) > 0 {
+		res = db.Model(&f).Updates(updates)
+	}
+	return event, res.Error
+}
+
+// CheckSecondFactor returns the status of the second factor of a user. The codes are counted per user and not per
+// session so that starting a new login does not allow more guesses.
+func CheckSecondFactor(db *gorm.DB, userID uint) (Status, error) {
+	status := Status{}
+	var counters []models.FailedLogin
+	res := db.Where("lock_key = ?", SecondFactorKey(userID)).Find(&counters)
+	for _, f := range counters {
+		status.Locked = f.IsLocked()
+	}
+	return status, res.Error
+}
+
+// FailSecondFactor records an invalid second factor code for a user, who is locked after max invalid codes. The event
+// is returned if the user became locked by this failure.
+func FailSecondFactor(db *gorm.DB, conf *infra.Config, userID uint, max int, ip string) (*models.LockoutEvent, error) {
+	return fail(db, conf, SecondFactorKey(userID), max, false, ip)
+}
+
+// SucceedSecondFactor forgets the invalid second factor codes of a user after a successful login
+func SucceedSecondFactor(db *gorm.DB, userID uint) error {
+	return db.Unscoped().Where(&models.FailedLogin{LockKey: SecondFactorKey(userID)}).Delete(&models.FailedLogin{}).Error
+}
+
+// Purge removes the failures which are older than the window and no longer lock or delay anything, failures are
+// counted for any email address so the rows would otherwise pile up. The number of removed rows is returned.
+func Purge(db *gorm.DB, conf *infra.Config) (int64, error) {
+	now := time.Now()
+	res := db.Unscoped().
+		Where("last_failed_at < ? AND next_attempt_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-window(conf)), now, now).
+		Delete(&models.FailedLogin{})
+	return res.RowsAffected, res.Error
+}
+
+// delay returns how long to wait after the given number of failures, doubling with every failure after LockoutDelayAfter
+func delay(conf *infra.Config, count int) time.Duration {
+	n := count - conf.LockoutDelayAfter
+	if conf.LockoutDelayAfter <= 0 || n < 0 {
+		return 0
+	}
+	d := time.Second << min(n, 16)
+	return min(d, maxDelay)
+}
+
+// Succeed forgets the failures of an email address after a successful login. The IP address is not reset as an
+// attacker could otherwise reset it by logging in to their own account.
+func Succeed(db *gorm.DB, email string) error {
+	return db.Unscoped().Where(&models.FailedLogin{LockKey: EmailKey(email)}).Delete(&models.FailedLogin{}).Error
+}
+
+// Unlock removes the lock and the failures of a key and marks its open lockout events as unlocked by the given actor
+func Unlock(db *gorm.DB, key string, by string) error {
+	res := db.Unscoped().Where(&models.FailedLogin{LockKey: key}).Delete(&models.FailedLogin{})
+	if res.Error != nil {
+		return res.Error
+	}
+	now := time.Now()
+	return db.Model(&models.LockoutEvent{}).
+		Where("lock_key = ? AND unlocked_at IS NULL AND locked_until > ?", key, now).
+		Updates(models.LockoutEvent{UnlockedAt: &now, UnlockedBy: by}).Error
+}
@@ -0,0 +1,160 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns an in-memory database with the lockout tables, it uses one connection as every connection to an
+// in-memory database has its own data
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	err = db.AutoMigrate(&models.User{}, &models.FailedLogin{}, &models.LockoutEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDelay(t *testing.T) {
+	conf := &infra.Config{LockoutDelayAfter: 3}
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{11, 256 * time.Second},
+		{12, maxDelay},
+		{1000, maxDelay},
+	}
+	for _, tt := range tests {
+		if got := delay(conf, tt.count); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.count, got, tt.want)
+		}
+	}
+
+	// A threshold of 0 turns the delay off
+	if got := delay(&infra.Config{}, 10); got != 0 {
+		t.Errorf("delay without threshold = %s, want 0", got)
+	}
+}
+
+func TestFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		failures int
+		locked   bool
+		events   int
+	}{
+		{"below max", 3, 2, false, 0},
+		{"at max", 3, 3, true, 1},
+		{"above max is locked once", 3, 5, true, 1},
+		{"disabled", 0, 20, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			conf := &infra.Config{LockoutDuration: 15, LockoutDelayAfter: 2}
+			events := 0
+			for i := 0; i < tt.failures; i++ {
+				event, err := fail(db, conf, EmailKey("user@example.com"), tt.max, true, "192.0.2.1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if event != nil {
+					events++
+				}
+			}
+			if events != tt.events {
+				t.Errorf("%d lockout events, want %d", events, tt.events)
+			}
+			status, err := Check(db, "User@Example.com", "192.0.2.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Locked != tt.locked {
+				t.Errorf("Locked = %t, want %t", status.Locked, tt.locked)
+			}
+			if want := tt.failures >= conf.LockoutDelayAfter; (status.RetryAfter > 0) != want {
+				t.Errorf("RetryAfter = %s, want a delay %t", status.RetryAfter, want)
+			}
+		})
+	}
+}
+
+func TestFailStartsOverAfterWindow(t *testing.T) {
+	db := testDB(t)
+	conf := &infra.Config{LockoutDuration: 15}
+	key := IPKey("192.0.2.1")
+	old := time.Now().Add(-time.Hour)
+	err := db.Create(&models.FailedLogin{LockKey: key, Count: 9, LastFailedAt: old, LockedUntil: &old}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := fail(db, conf, key, 10, false, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event != nil {
+		t.Error("a failure after the window locked the key")
+	}
+	f := models.FailedLogin{}
+	db.Where(&models.FailedLogin{LockKey: key}).First(&f)
+	if f.Count != 1 {
+		t.Errorf("Count = %d, want 1", f.Count)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	db := testDB(t)
+	conf := &infra.Config{LockoutDuration: 15}
+	now := time.Now()
+	old := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+	for _, f := range []models.FailedLogin{
+		{LockKey: "email:old", LastFailedAt: old, NextAttemptAt: old},
+		{LockKey: "email:recent", LastFailedAt: now, NextAttemptAt: now},
+		{LockKey: "email:locked", LastFailedAt: old, NextAttemptAt: old, LockedUntil: &later},
+		{LockKey: "email:delayed", LastFailedAt: old, NextAttemptAt: later},
+	} {
+		err := db.Create(&f).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := Purge(db, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Purge removed %d rows, want 1", count)
+	}
+	var keys []string
+	db.Unscoped().Model(&models.FailedLogin{}).Order("lock_key").Pluck("lock_key", &keys)
+	if len(keys) != 3 || keys[0] != "email:delayed" || keys[1] != "email:locked" || keys[2] != "email:recent" {
+		t.Errorf("kept %v", keys)
+	}
+}
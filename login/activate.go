@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
 	"github.com/uberswe/golang-base-project/models"
 	"github.com/uberswe/golang-base-project/routes"
 )
@@ -65,6 +66,7 @@ func (svc Service) Activate(c *gin.Context) {
 	// We don't need to check for an error here, even if it's not deleted it will not really affect application logic
 	db.Delete(&activationToken)
 
+	audit.RecordUser(c, db, audit.UserActivated, user.ID, "")
 	pd.AddMessage(routes.Success, activationSuccess)
 	slog.Info("Activate:Success", "token", activationToken.Value)
 	c.HTML(http.StatusOK, "activate.gohtml", pd)
@@ -0,0 +1,60 @@
+package login
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/challenge"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// ChallengePageData holds the page data of public forms which send emails, Challenge is set when bots are suspected
+type ChallengePageData struct {
+	routes.PageData
+	Challenge template.HTML
+}
+
+// renderForm renders a public form which sends emails and adds a challenge when one is required
+func (svc Service) renderForm(c *gin.Context, name string, pd routes.PageData, status int) {
+	c.HTML(status, name, svc.challengeData(c, pd))
+}
+
+// challengeData returns the page data of a public form, with a challenge when one is required
+func (svc Service) challengeData(c *gin.Context, pd routes.PageData) ChallengePageData {
+	cpd := ChallengePageData{PageData: pd}
+	if svc.challenge.Required(c.ClientIP()) {
+		var err error
+		cpd.Challenge, err = svc.challenge.Render(pd.Trans)
+		if err != nil {
+			slog.Error("renderForm", "error", err)
+		}
+	}
+	return cpd
+}
+
+// challengePassed counts the request and checks the answer to the challenge if one is required. The form is rendered
+// again with a new challenge when the check fails.
+func (svc Service) challengePassed(c *gin.Context, name string, pd routes.PageData) bool {
+	if svc.challengeAllowed(c, &pd) {
+		return true
+	}
+	svc.renderForm(c, name, pd, http.StatusBadRequest)
+	return false
+}
+
+// challengeAllowed counts the request and checks the answer to the challenge if one is required, a message is added
+// to the page data when the check fails
+func (svc Service) challengeAllowed(c *gin.Context, pd *routes.PageData) bool {
+	err := svc.challenge.Allow(c)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, challenge.ErrMissing) {
+		slog.Warn("Challenge", "error", err, "ip", c.ClientIP(), "path", c.FullPath())
+	}
+	pd.AddMessage(routes.Error, pd.Trans("Please wait until your browser has been checked and send the form again."))
+	return false
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
 	email2 "github.com/uberswe/golang-base-project/email"
 	"github.com/uberswe/golang-base-project/models"
 	"github.com/uberswe/golang-base-project/routes"
@@ -20,13 +21,16 @@ import (
 func (svc Service) ForgotPassword(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
 	pd.Title = pd.Trans("Forgot Password")
-	c.HTML(http.StatusOK, "forgotpassword.gohtml", pd)
+	svc.renderForm(c, "forgotpassword.gohtml", pd, http.StatusOK)
 }
 
 // ForgotPasswordPost handles the POST request which requests a password reset and then renders the HTML page with the appropriate message
 func (svc Service) ForgotPasswordPost(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
 	pd.Title = pd.Trans("Forgot Password")
+	if !svc.challengePassed(c, "forgotpassword.gohtml", pd) {
+		return
+	}
 
 	db := svc.env.GetDb()
 
@@ -34,12 +38,13 @@ func (svc Service) ForgotPasswordPost(c *gin.Context) {
 	user := models.User{Email: email}
 	res := db.Where(&user).First(&user)
 	if res.Error == nil && user.ActivatedAt != nil {
+		audit.RecordUser(c, db, audit.PasswordResetRequested, user.ID, "")
 		go svc.forgotPasswordEmailHandler(user.ID, email, pd.Trans)
 	}
 
 	pd.AddMessage(routes.Success, pd.Trans("An email with instructions to reset password has been sent"))
 	// We always return a positive response here to prevent user enumeration
-	c.HTML(http.StatusOK, "forgotpassword.gohtml", pd)
+	svc.renderForm(c, "forgotpassword.gohtml", pd, http.StatusOK)
 }
 
 func (svc Service) forgotPasswordEmailHandler(userID uint, email string, trans func(string) string) {
@@ -1,33 +1,61 @@
 package login
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
-	"strings"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/challenge"
+	"github.com/uberswe/golang-base-project/emaildomain"
 	"github.com/uberswe/golang-base-project/infra"
-	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/lockout"
 	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
+	"github.com/uberswe/golang-base-project/passwords"
 	"github.com/uberswe/golang-base-project/routes"
-	"github.com/uberswe/golang-base-project/ulid"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type Service struct {
 	env infra.ILair
+	// challenge protects the public forms which send emails from bots
+	challenge *challenge.Adaptive
+	// domains checks the email domains of new users against the registration rules
+	domains *emaildomain.Checker
 }
 
 func NewService(env infra.ILair) *Service {
-	return &Service{env: env}
+	return &Service{env: env, challenge: challenge.New(env.GetConfig()), domains: emaildomain.New(net.DefaultResolver)}
+}
+
+// LoginPageData holds the additional data needed to render the login page
+type LoginPageData struct {
+	routes.PageData
+	Providers []infra.OIDCProvider
+	// RememberMe is true if users can choose to stay logged in
+	RememberMe bool
+}
+
+// renderLogin renders the login page together with the buttons for any configured OpenID Connect providers
+func (svc Service) renderLogin(c *gin.Context, pd routes.PageData, status int) {
+	c.HTML(status, "login.gohtml", LoginPageData{
+		PageData:   pd,
+		Providers:  svc.env.GetConfig().OIDCProviders,
+		RememberMe: svc.env.GetConfig().RememberMeDays > 0,
+	})
 }
 
 // Login renders the HTML of the login page
 func (svc Service) Login(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
 	pd.Title = pd.Trans("Login")
-	c.HTML(http.StatusOK, "login.gohtml", pd)
+	svc.renderLogin(c, pd, http.StatusOK)
 }
 
 // LoginPost handles login requests and returns the appropriate HTML and messages
@@ -41,6 +69,26 @@ func (svc Service) LoginPost(c *gin.Context) {
 
 	email := c.PostForm("email")
 
+	// Attempts are rejected before the password is checked when there have been too many failures
+	status, err := lockout.Check(db, email, c.ClientIP())
+	if err != nil {
+		pd.AddMessage(routes.Error, loginError)
+		slog.Error("LoginPost", "error", err)
+		svc.renderLogin(c, pd, http.StatusInternalServerError)
+		return
+	}
+	if !status.Allowed() {
+		if status.Locked {
+			pd.AddMessage(routes.Error, pd.Trans("Too many failed login attempts. Logins have been temporarily locked, if you have an account we have sent you an email with a link to unlock it."))
+		} else {
+			seconds := int(math.Ceil(status.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			pd.AddMessage(routes.Error, fmt.Sprintf(pd.Trans("Too many failed login attempts, please wait %d seconds before trying again."), seconds))
+		}
+		svc.renderLogin(c, pd, http.StatusTooManyRequests)
+		return
+	}
+
 	// load user and associated roles
 
 	//res := db.Model(&models.User{}).Preload("Roles").Find(&user)
@@ -49,78 +97,81 @@ func (svc Service) LoginPost(c *gin.Context) {
 	res := db.Preload("Roles").Where(&user).First(&user)
 
 	//res := db.Where(&user).First(&user)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		// Unknown email addresses are counted the same way as wrong passwords
+		pd.AddMessage(routes.Error, svc.loginFailed(c, pd, email, loginError))
+		svc.renderLogin(c, pd, http.StatusBadRequest)
+		return
+	}
 	if res.Error != nil {
 		pd.AddMessage(routes.Error, loginError)
 		slog.Error("LoginPost", "error", res.Error)
-		c.HTML(http.StatusInternalServerError, "login.gohtml", pd)
+		svc.renderLogin(c, pd, http.StatusInternalServerError)
 		return
 	}
 
 	if res.RowsAffected == 0 {
 		pd.AddMessage(routes.Error, loginError)
-		c.HTML(http.StatusBadRequest, "login.gohtml", pd)
+		svc.renderLogin(c, pd, http.StatusBadRequest)
 		return
 	}
 
 	if user.ActivatedAt == nil {
 		pd.AddMessage(routes.Error, pd.Trans("Account is not activated yet."))
-		c.HTML(http.StatusBadRequest, "login.gohtml", pd)
+		svc.renderLogin(c, pd, http.StatusBadRequest)
 		return
 	}
 
 	if len(user.Roles) == 0 {
 		pd.AddMessage(routes.Error, pd.Trans("Account does not contain role attributes."))
-		c.HTML(http.StatusBadRequest, "login.gohtml", pd)
+		svc.renderLogin(c, pd, http.StatusBadRequest)
 		return
 
 	}
 
 	password := c.PostForm("password")
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	match, err := passhash.Verify(user.Password, password)
 	if err != nil {
-		pd.AddMessage(routes.Error, loginError)
-		c.HTML(http.StatusBadRequest, "login.gohtml", pd)
+		slog.Error("LoginPost", "error", err)
+	}
+	if !match {
+		pd.AddMessage(routes.Error, svc.loginFailed(c, pd, email, loginError))
+		svc.renderLogin(c, pd, http.StatusBadRequest)
 		return
 	}
 
-	// Generate a ulid for the current session
-	sessionIdentifier := ulid.Generate()
-
-	ses := models.Session{
-		Identifier: sessionIdentifier,
+	if user.IsDisabled() {
+		audit.RecordUser(c, db, audit.LoginFailed, user.ID, "disabled")
+		pd.AddMessage(routes.Error, pd.Trans("This account has been disabled."))
+		svc.renderLogin(c, pd, http.StatusForbidden)
+		return
 	}
 
-	// Session is valid for 1 hour
-	ses.ExpiresAt = time.Now().Add(time.Hour)
-	ses.UserID = user.ID
-	var roles []string
-	for _, role := range user.Roles {
-		roles = append(roles, role.Name)
+	// Hashes created with bcrypt or with other parameters are replaced now that the password is known
+	params := passwords.Params(svc.env.GetConfig())
+	if passhash.NeedsRehash(user.Password, params) {
+		hash, err := passhash.Hash(password, params)
+		if err == nil {
+			err = db.Model(&user).Update("password", hash).Error
+		}
+		if err != nil {
+			slog.Error("LoginPost:Rehash", "error", err)
+		}
 	}
-	ses.Role = strings.Join(roles, ",") // comma seperated list of roles
-
-	slog.Debug("LoginPost", "session", ses)
 
-	res = db.Save(&ses)
-	if res.Error != nil {
-		pd.AddMessage(routes.Error, loginError)
-		slog.Error("LoginPost", "error", res.Error)
-		c.HTML(http.StatusInternalServerError, "login.gohtml", pd)
-		return
+	err = lockout.Succeed(db, email)
+	if err != nil {
+		slog.Error("LoginPost", "error", err)
 	}
 
-	session := middleware.DefaultSessionWithOptions(c)
-	session.Set(middleware.SessionIDKey, sessionIdentifier)
-	// Safari strictness requires the following
-
-	err = session.Save()
+	redirect, err := svc.completeLogin(c, user, c.PostForm("remember") == "true")
 	if err != nil {
 		pd.AddMessage(routes.Error, loginError)
 		slog.Error("LoginPost", "error", err)
-		c.HTML(http.StatusInternalServerError, "login.gohtml", pd)
+		svc.renderLogin(c, pd, http.StatusInternalServerError)
 		return
 	}
 
 	//c.Redirect(http.StatusTemporaryRedirect, "/admin")
-	c.Redirect(http.StatusMovedPermanently, "/")
+	c.Redirect(http.StatusMovedPermanently, redirect)
 }
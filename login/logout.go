@@ -5,15 +5,42 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
 	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/session"
 )
 
-// Logout deletes the current user session and redirects the user to the index page
+// Logout deletes the current user session and redirects the user to the index page. The session is also deleted from
+// the database so that a copy of the cookie can not be used afterwards.
 func (svc Service) Logout(c *gin.Context) {
-	session := middleware.DefaultSessionWithOptions(c)
+	cookie := middleware.DefaultSessionWithOptions(c)
 
-	session.Delete(middleware.SessionIDKey)
-	err := session.Save()
+	identifier, _ := cookie.Get(middleware.SessionIDKey).(string)
+	db := svc.env.GetDb()
+
+	// An admin who logs out while impersonating a user is logged out of their own session as well
+	if _, impersonating := c.Get(middleware.ImpersonatorIDKey); impersonating {
+		ses := models.Session{Identifier: identifier}
+		if db.Where(&ses).First(&ses).Error == nil {
+			err := session.Revoke(db, ses.ImpersonatorSession)
+			if err != nil {
+				slog.Error("Logout", "error", err)
+			}
+		}
+		audit.Record(c, db, audit.ImpersonationEnded, c.GetUint(middleware.UserIDKey), "logout")
+	} else {
+		audit.Record(c, db, audit.Logout, c.GetUint(middleware.UserIDKey), "")
+	}
+
+	err := session.Revoke(db, identifier)
+	if err != nil {
+		slog.Error("Logout", "error", err)
+	}
+
+	middleware.ClearRememberCookie(c, svc.env.GetConfig())
+	cookie.Delete(middleware.SessionIDKey)
+	err = cookie.Save()
 	if err != nil {
 		slog.Error("Logout", "error", err)
 	}
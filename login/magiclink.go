@@ -0,0 +1,146 @@
+package login
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// magicLinkLifetime is how long an emailed sign-in link can be used
+const magicLinkLifetime = 15 * time.Minute
+
+// MagicLinkPageData defines additional data needed to render the page which finishes signing in with an emailed link
+type MagicLinkPageData struct {
+	routes.PageData
+	Token string
+}
+
+// MagicLink renders the HTML page where users can request a sign-in link by email
+func (svc Service) MagicLink(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Email me a sign-in link")
+	svc.renderForm(c, "loginemail.gohtml", pd, http.StatusOK)
+}
+
+// MagicLinkPost sends a sign-in link to the email address if it belongs to an activated user
+func (svc Service) MagicLinkPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Email me a sign-in link")
+
+	if !svc.challengePassed(c, "loginemail.gohtml", pd) {
+		return
+	}
+
+	email := c.PostForm("email")
+	user := models.User{Email: email}
+	res := svc.env.GetDb().Where(&user).First(&user)
+	if email != "" && res.Error == nil && user.ActivatedAt != nil {
+		go svc.magicLinkEmailHandler(user.ID, email, pd.Trans)
+	}
+
+	// We always return a positive response here to prevent user enumeration
+	pd.AddMessage(routes.Success, pd.Trans("If we have an account with your email you will receive a sign-in link shortly."))
+	svc.renderForm(c, "loginemail.gohtml", pd, http.StatusOK)
+}
+
+func (svc Service) magicLinkEmailHandler(userID uint, email string, trans func(string) string) {
+	value, err := secureToken()
+	if err != nil {
+		slog.Error("magicLinkEmailHandler", "error", err)
+		return
+	}
+
+	magicLinkToken := models.Token{
+		Value:     value,
+		Type:      models.TokenMagicLink,
+		ModelID:   int(userID),
+		ModelType: "User",
+		ExpiresAt: time.Now().Add(magicLinkLifetime),
+	}
+
+	res := svc.env.GetDb().Save(&magicLinkToken)
+	if res.Error != nil || res.RowsAffected == 0 {
+		slog.Error("magicLinkEmailHandler", "error", res.Error)
+		return
+	}
+	svc.sendMagicLinkEmail(magicLinkToken.Value, email, trans)
+}
+
+func (svc Service) sendMagicLinkEmail(token string, email string, trans func(string) string) {
+	conf := svc.env.GetConfig()
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		slog.Error("sendMagicLinkEmail", "error", err)
+		return
+	}
+
+	u.Path = path.Join(u.Path, "/login/email/", token)
+
+	emailService := email2.New(conf)
+
+	emailService.Send(email, trans("Sign-in link"), fmt.Sprintf(trans("Use the following link to login. The link can only be used once and expires in 15 minutes. If this was not requested by you, please ignore this email.\n%s"), u.String()))
+}
+
+// MagicLinkConfirm renders a button which completes the login. Opening the link does not use the token so that
+// email security scanners which follow links can not use up the link before the user clicks it.
+func (svc Service) MagicLinkConfirm(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Login")
+	c.HTML(http.StatusOK, "loginemailconfirm.gohtml", MagicLinkPageData{PageData: pd, Token: c.Param("token")})
+}
+
+// MagicLinkConfirmPost uses the token and creates a session the same way LoginPost does
+func (svc Service) MagicLinkConfirmPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Email me a sign-in link")
+	linkError := pd.Trans("This sign-in link is invalid or has expired, please request a new one.")
+
+	db := svc.env.GetDb()
+
+	magicLinkToken := models.Token{
+		Value: c.Param("token"),
+		Type:  models.TokenMagicLink,
+	}
+	res := db.Where(&magicLinkToken).First(&magicLinkToken)
+	if magicLinkToken.Value == "" || res.Error != nil || magicLinkToken.HasExpired() {
+		pd.AddMessage(routes.Error, linkError)
+		svc.renderForm(c, "loginemail.gohtml", pd, http.StatusBadRequest)
+		return
+	}
+
+	// The token is deleted before it is used, only the request which deletes it may login
+	res = db.Unscoped().Delete(&magicLinkToken)
+	if res.Error != nil || res.RowsAffected != 1 {
+		pd.AddMessage(routes.Error, linkError)
+		svc.renderForm(c, "loginemail.gohtml", pd, http.StatusBadRequest)
+		return
+	}
+
+	user := models.User{}
+	user.ID = uint(magicLinkToken.ModelID)
+	res = db.Preload("Roles").Where(&user).First(&user)
+	if res.Error != nil || user.ActivatedAt == nil {
+		pd.AddMessage(routes.Error, linkError)
+		svc.renderForm(c, "loginemail.gohtml", pd, http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := svc.completeLogin(c, user, false)
+	if err != nil {
+		slog.Error("MagicLinkConfirmPost", "error", err)
+		pd.AddMessage(routes.Error, linkError)
+		svc.renderForm(c, "loginemail.gohtml", pd, http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("MagicLinkConfirmPost:Login", "user", user.ID)
+	c.Redirect(http.StatusFound, redirect)
+}
@@ -0,0 +1,260 @@
+package login
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/invite"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passwords"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/sso"
+	"gorm.io/gorm"
+)
+
+// OIDCStart redirects the user to an external OpenID Connect provider. Authenticated users are linking a new identity to their account.
+func (svc Service) OIDCStart(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Login")
+	conf := svc.env.GetConfig()
+
+	p, ok := sso.Find(conf, c.Param("provider"))
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	// Linking an identity lets it login to the account, so the user confirms their password or a code first
+	_, link := c.Get(middleware.UserIDKey)
+	if link && !middleware.Reauthenticated(c) {
+		c.Redirect(http.StatusFound, middleware.ConfirmPath+"?next="+url.QueryEscape(c.Request.URL.Path))
+		return
+	}
+	redirectURL, err := sso.Start(c.Request.Context(), conf, middleware.DefaultSessionWithOptions(c), p, link)
+	if err != nil {
+		slog.Error("OIDCStart", "error", err, "provider", p.Key)
+		pd.AddMessage(routes.Error, pd.Trans("Could not connect to the login provider, please try again later."))
+		svc.renderLogin(c, pd, http.StatusBadGateway)
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// OIDCCallback handles the redirect back from a provider. The identity is linked to the current user, used to login an
+// existing user or used to create a new user the same way RegisterPost does.
+func (svc Service) OIDCCallback(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Login")
+	loginError := pd.Trans("Could not login with the selected provider, please try again.")
+	conf := svc.env.GetConfig()
+	db := svc.env.GetDb()
+
+	p, ok := sso.Find(conf, c.Param("provider"))
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	claims, pending, err := sso.Finish(c.Request.Context(), conf, middleware.DefaultSessionWithOptions(c), p, c.Request.URL.Query())
+	if err != nil {
+		slog.Info("OIDCCallback", "error", err, "provider", p.Key)
+		pd.AddMessage(routes.Error, loginError)
+		svc.renderLogin(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	identity := models.Identity{Provider: p.Key, Subject: claims.Subject}
+	res := db.Where(&identity).First(&identity)
+	if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		slog.Error("OIDCCallback", "error", res.Error)
+		pd.AddMessage(routes.Error, loginError)
+		svc.renderLogin(c, pd, http.StatusInternalServerError)
+		return
+	}
+	found := res.Error == nil
+
+	// Linking a new identity to the account of the authenticated user
+	if userID, authenticated := c.Get(middleware.UserIDKey); authenticated && pending.Link {
+		if !middleware.Reauthenticated(c) {
+			c.Redirect(http.StatusFound, "/account/identities?linked=confirm")
+			return
+		}
+		if found && identity.UserID != userID.(uint) {
+			slog.Info("OIDCCallback:AlreadyLinked", "provider", p.Key, "user", userID)
+			c.Redirect(http.StatusFound, "/account/identities?linked=taken")
+			return
+		}
+		if !found {
+			identity.UserID = userID.(uint)
+			identity.Email = claims.Email
+			res = db.Save(&identity)
+			if res.Error != nil {
+				slog.Error("OIDCCallback", "error", res.Error)
+				c.Redirect(http.StatusFound, "/account/identities?linked=error")
+				return
+			}
+			audit.Record(c, db, audit.IdentityLinked, identity.UserID, fmt.Sprintf("provider=%s subject=%q email=%q", p.Key, identity.Subject, identity.Email))
+		}
+		c.Redirect(http.StatusFound, "/account/identities?linked=ok")
+		return
+	}
+
+	user := models.User{}
+	if found {
+		user.ID = identity.UserID
+		res = db.Preload("Roles").Where(&user).First(&user)
+	} else {
+		user, found, err = svc.userForClaims(c.Request.Context(), p, claims)
+		if errors.Is(err, errInviteOnly) {
+			pd.AddMessage(routes.Error, pd.Trans("Registration is by invitation only."))
+			svc.renderLogin(c, pd, http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, errDomain) {
+			slog.Info("OIDCCallback", "error", err, "provider", p.Key)
+			pd.AddMessage(routes.Error, pd.Trans("Could not register, please make sure the details you have provided are correct and that you do not already have an existing account."))
+			svc.renderLogin(c, pd, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.Info("OIDCCallback", "error", err, "provider", p.Key)
+			pd.AddMessage(routes.Error, pd.Trans("An account with this email address already exists. Please login with your password and link the provider from your account page."))
+			svc.renderLogin(c, pd, http.StatusBadRequest)
+			return
+		}
+		if !found {
+			audit.RecordUser(c, db, audit.UserRegistered, user.ID, "provider="+p.Key)
+		}
+		identity.UserID = user.ID
+		identity.Email = claims.Email
+		res = db.Save(&identity)
+	}
+	if res.Error != nil {
+		slog.Error("OIDCCallback", "error", res.Error)
+		pd.AddMessage(routes.Error, loginError)
+		svc.renderLogin(c, pd, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	db.Model(&identity).Update("last_used_at", &now)
+
+	if user.ActivatedAt == nil {
+		if !found {
+			// The provider did not verify the email so we send an activation email like RegisterPost does
+			go svc.activationEmailHandler(user.ID, user.Email, pd.Trans)
+			pd.AddMessage(routes.Success, pd.Trans("Thank you for registering. An activation email has been sent with steps describing how to activate your account."))
+			svc.renderLogin(c, pd, http.StatusOK)
+			return
+		}
+		pd.AddMessage(routes.Error, pd.Trans("Account is not activated yet."))
+		svc.renderLogin(c, pd, http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := svc.completeLogin(c, user, false)
+	if err != nil {
+		slog.Error("OIDCCallback", "error", err)
+		pd.AddMessage(routes.Error, loginError)
+		svc.renderLogin(c, pd, http.StatusInternalServerError)
+		return
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// errInviteOnly is returned when someone without an account logs in with a provider while registration is invite only
+var errInviteOnly = errors.New("registration is invite only")
+
+// errDomain is returned when the email domain of someone without an account is not allowed to register
+var errDomain = errors.New("email domain can not register")
+
+// userForClaims returns the user with the email of the claims if the provider is trusted to link accounts by email and
+// has verified it, or creates a new user. The returned bool is true if the user already existed.
+func (svc Service) userForClaims(ctx context.Context, p infra.OIDCProvider, claims sso.Claims) (models.User, bool, error) {
+	db := svc.env.GetDb()
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return models.User{}, false, errors.New("provider did not return an email")
+	}
+
+	user := models.User{Email: email}
+	res := db.Preload("Roles").Where(&user).First(&user)
+	if res.Error == nil {
+		// Existing accounts are linked from the account page unless the provider is trusted to link by email, which is
+		// only safe when the provider has verified that the email belongs to the user
+		if !p.TrustEmail {
+			return user, true, errors.New("email exists and the provider is not trusted to link accounts")
+		}
+		if !claims.EmailVerified {
+			return user, true, errors.New("email exists but is not verified by the provider")
+		}
+		if user.ActivatedAt == nil {
+			now := time.Now()
+			user.ActivatedAt = &now
+			res = db.Model(&user).Update("activated_at", &now)
+		}
+		return user, true, res.Error
+	}
+	if !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return user, false, res.Error
+	}
+
+	// New users need an invitation, which is accepted on the register page
+	if svc.env.GetConfig().RegistrationMode == invite.ModeInvite {
+		return user, false, errInviteOnly
+	}
+	err := svc.domains.Check(ctx, svc.env.GetConfig(), email)
+	if err != nil {
+		return user, false, errors.Join(errDomain, err)
+	}
+
+	user, err = svc.provisionUser(email, claims.EmailVerified)
+	return user, false, err
+}
+
+// provisionUser creates a user with the 'user' role and a random password for someone who arrived through a provider
+func (svc Service) provisionUser(email string, verified bool) (models.User, error) {
+	db := svc.env.GetDb()
+	user := models.User{Email: email}
+
+	// retrieve the 'user' role
+	role := models.Role{}
+	res := db.Where("name='user'").First(&role)
+	if res.Error != nil {
+		return user, res.Error
+	}
+	user.Roles = append(user.Roles, role)
+
+	// The password is random and never shown, users can set one with the forgot password flow
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return user, err
+	}
+	hashedPassword, err := passwords.Hash(svc.env.GetConfig(), hex.EncodeToString(b))
+	if err != nil {
+		return user, err
+	}
+	user.Password = hashedPassword
+
+	if verified {
+		now := time.Now()
+		user.ActivatedAt = &now
+	}
+
+	res = db.Save(&user)
+	return user, res.Error
+}
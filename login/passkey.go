@@ -0,0 +1,115 @@
+package login
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passkey"
+)
+
+// PasskeyLoginBegin starts a discoverable login ceremony and returns the credential request options as JSON
+func (svc Service) PasskeyLoginBegin(c *gin.Context) {
+	wa, err := passkey.New(svc.env.GetConfig())
+	if err != nil {
+		slog.Error("PasskeyLoginBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "passkeys are not configured"})
+		return
+	}
+
+	options, data, err := wa.BeginDiscoverableLogin()
+	if err != nil {
+		slog.Error("PasskeyLoginBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start login"})
+		return
+	}
+
+	err = passkey.SaveSessionData(middleware.DefaultSessionWithOptions(c), passkey.LoginSessionKey, data)
+	if err != nil {
+		slog.Error("PasskeyLoginBegin", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// PasskeyLoginFinish verifies the assertion of the authenticator and creates the same session as LoginPost
+func (svc Service) PasskeyLoginFinish(c *gin.Context) {
+	loginError := gin.H{"error": "the passkey could not be used to login"}
+	db := svc.env.GetDb()
+
+	data, err := passkey.TakeSessionData(middleware.DefaultSessionWithOptions(c), passkey.LoginSessionKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no login in progress"})
+		return
+	}
+
+	wa, err := passkey.New(svc.env.GetConfig())
+	if err != nil {
+		slog.Error("PasskeyLoginFinish", "error", err)
+		c.JSON(http.StatusInternalServerError, loginError)
+		return
+	}
+
+	var user passkey.User
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := passkey.UserIDFromHandle(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		user, err = passkey.LoadUser(db, userID)
+		return user, err
+	}
+
+	credential, err := wa.FinishDiscoverableLogin(handler, data, c.Request)
+	if err != nil {
+		slog.Info("PasskeyLoginFinish", "error", err)
+		c.JSON(http.StatusBadRequest, loginError)
+		return
+	}
+
+	if credential.Authenticator.CloneWarning {
+		slog.Warn("PasskeyLoginFinish:CloneWarning", "user", user.ID)
+		c.JSON(http.StatusBadRequest, loginError)
+		return
+	}
+
+	if user.ActivatedAt == nil || len(user.Roles) == 0 {
+		c.JSON(http.StatusBadRequest, loginError)
+		return
+	}
+
+	// The sign count and flags change with every use so the stored credential is updated
+	for _, p := range user.Passkeys {
+		stored, err := passkey.Decode(p)
+		if err != nil || !bytes.Equal(stored.ID, credential.ID) {
+			continue
+		}
+		updated, err := passkey.Encode(user.ID, p.Name, credential)
+		if err != nil {
+			break
+		}
+		now := time.Now()
+		res := db.Model(&p).Updates(models.Passkey{Credential: updated.Credential, LastUsedAt: &now})
+		if res.Error != nil {
+			slog.Error("PasskeyLoginFinish", "error", res.Error)
+		}
+		break
+	}
+
+	redirect := returnTo(c)
+	err = svc.startSession(c, user.User, false)
+	if err != nil {
+		slog.Error("PasskeyLoginFinish", "error", err)
+		c.JSON(http.StatusInternalServerError, loginError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect": redirect})
+}
@@ -7,50 +7,122 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/uberswe/golang-base-project/audit"
 	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/invite"
 	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passwords"
 	"github.com/uberswe/golang-base-project/routes"
 	"github.com/uberswe/golang-base-project/ulid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
-// Register renders the HTML content of the register page
+// RegisterPageData holds the additional data needed to render the register page
+type RegisterPageData struct {
+	ChallengePageData
+	// Invitation is set when the visitor opened a valid invitation link, InviteToken is the token of the link
+	Invitation  *models.Invitation
+	InviteToken string
+	// InviteOnly is true when registering needs an invitation
+	InviteOnly bool
+}
+
+func (svc Service) renderRegister(c *gin.Context, pd routes.PageData, invitation *models.Invitation, token string, status int) {
+	pd.Title = pd.Trans("Register")
+	rpd := RegisterPageData{
+		ChallengePageData: svc.challengeData(c, pd),
+		Invitation:        invitation,
+		InviteOnly:        svc.env.GetConfig().RegistrationMode == invite.ModeInvite,
+	}
+	if invitation != nil {
+		rpd.InviteToken = token
+	}
+	c.HTML(status, "register.gohtml", rpd)
+}
+
+// invitation returns the pending invitation of the token, or nil if there is no token. A message is added to the page
+// data and false is returned when the token is not valid.
+func (svc Service) invitation(pd *routes.PageData, token string) (*models.Invitation, bool) {
+	if token == "" {
+		return nil, true
+	}
+	invitation, err := invite.Find(svc.env.GetDb(), token)
+	if err != nil {
+		if !errors.Is(err, invite.ErrInvalid) {
+			slog.Error("invitation", "error", err)
+		}
+		pd.AddMessage(routes.Error, pd.Trans("This invitation is invalid or has expired."))
+		return nil, false
+	}
+	return &invitation, true
+}
+
+// Register renders the HTML content of the register page. While registration is invite only the form is only shown
+// to visitors who opened the link of an invitation.
 func (svc Service) Register(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
-	pd.Title = pd.Trans("Register")
-	c.HTML(http.StatusOK, "register.gohtml", pd)
+	token := c.Query("invite")
+	invitation, _ := svc.invitation(&pd, token)
+	svc.renderRegister(c, pd, invitation, token, http.StatusOK)
 }
 
-// RegisterPost handles requests to register users and returns appropriate messages as HTML content
+// RegisterPost handles requests to register users and returns appropriate messages as HTML content. Users who register
+// with an invitation get the role of the invitation and are activated right away, as the invitation was sent to their
+// email.
 func (svc Service) RegisterPost(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
-	passwordError := pd.Trans("Your password must be 8 characters in length or longer")
 	registerError := pd.Trans("Could not register, please make sure the details you have provided are correct and that you do not already have an existing account.")
 	registerSuccess := pd.Trans("Thank you for registering. An activation email has been sent with steps describing how to activate your account.")
-	pd.Title = pd.Trans("Register")
+	token := c.PostForm("invite")
+	if !svc.challengeAllowed(c, &pd) {
+		svc.renderRegister(c, pd, nil, "", http.StatusBadRequest)
+		return
+	}
+	invitation, ok := svc.invitation(&pd, token)
+	if !ok {
+		svc.renderRegister(c, pd, nil, "", http.StatusBadRequest)
+		return
+	}
+	if invitation == nil && svc.env.GetConfig().RegistrationMode == invite.ModeInvite {
+		pd.AddMessage(routes.Error, pd.Trans("Registration is by invitation only."))
+		svc.renderRegister(c, pd, nil, "", http.StatusForbidden)
+		return
+	}
+
+	email := c.PostForm("email")
+	if invitation != nil {
+		if !strings.EqualFold(email, invitation.Email) {
+			pd.AddMessage(routes.Error, pd.Trans("Please register with the email the invitation was sent to."))
+			svc.renderRegister(c, pd, invitation, token, http.StatusBadRequest)
+			return
+		}
+		email = invitation.Email
+	}
+
 	password := c.PostForm("password")
-	if len(password) < 8 {
-		pd.AddMessage(routes.Error, passwordError)
-		c.HTML(http.StatusBadRequest, "register.gohtml", pd)
+	violations := passwords.Check(svc.env.GetDb(), svc.env.GetConfig(), password, models.User{Email: email})
+	if len(violations) > 0 {
+		for _, v := range violations {
+			pd.AddMessage(routes.Error, v.Message(pd.Trans))
+		}
+		svc.renderRegister(c, pd, invitation, token, http.StatusBadRequest)
 		return
 	}
 
 	// The password is hashed as early as possible to make timing attacks that reveal registered users harder
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := passwords.Hash(svc.env.GetConfig(), password)
 	if err != nil {
 		pd.AddMessage(routes.Error, registerError)
-		slog.Error("RegisterPost:GenerateFromPassword", "error", err)
-		c.HTML(http.StatusInternalServerError, "register.gohtml", pd)
+		slog.Error("RegisterPost:Hash", "error", err)
+		svc.renderRegister(c, pd, invitation, token, http.StatusInternalServerError)
 		return
 	}
 
-	email := c.PostForm("email")
-
 	// Validate the email
 	validate := validator.New()
 	err = validate.Var(email, "required,email")
@@ -58,50 +130,98 @@ func (svc Service) RegisterPost(c *gin.Context) {
 	if err != nil {
 		pd.AddMessage(routes.Error, registerError)
 		slog.Error("RegisterPost:Validate", "error", err)
-		c.HTML(http.StatusInternalServerError, "register.gohtml", pd)
+		svc.renderRegister(c, pd, invitation, token, http.StatusInternalServerError)
 		return
 	}
 
+	// Admins chose who to invite, so the domain rules only apply to people registering on their own. The generic error
+	// is shown so that the rules can not be probed.
+	if invitation == nil {
+		err = svc.domains.Check(c.Request.Context(), svc.env.GetConfig(), email)
+		if err != nil {
+			pd.AddMessage(routes.Error, registerError)
+			slog.Info("RegisterPost:Domain", "error", err)
+			svc.renderRegister(c, pd, invitation, token, http.StatusBadRequest)
+			return
+		}
+	}
+
 	user := models.User{Email: email}
 	role := models.Role{}
 
 	db := svc.env.GetDb()
 
-	// retrieve the 'user' role
-	res := db.Where("name='user'").First(&role)
-	if (res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound)) || res.RowsAffected > 1 {
-		pd.AddMessage(routes.Error, registerError)
-		slog.Error("RegisterPost", "error", res.Error)
-		c.HTML(http.StatusInternalServerError, "register.gohtml", pd)
-		return
+	if invitation != nil {
+		role = invitation.Role
+	} else {
+		// retrieve the 'user' role
+		res := db.Where("name='user'").First(&role)
+		if (res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound)) || res.RowsAffected > 1 {
+			pd.AddMessage(routes.Error, registerError)
+			slog.Error("RegisterPost", "error", res.Error)
+			svc.renderRegister(c, pd, invitation, token, http.StatusInternalServerError)
+			return
+		}
 	}
 
 	user.Roles = append(user.Roles, role)
 
-	res = db.Where(&user).First(&user)
+	res := db.Where(&user).First(&user)
 	if (res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound)) || res.RowsAffected > 0 {
 		pd.AddMessage(routes.Error, registerError)
 		slog.Error("RegisterPost", "error", res.Error)
-		c.HTML(http.StatusInternalServerError, "register.gohtml", pd)
+		svc.renderRegister(c, pd, invitation, token, http.StatusInternalServerError)
 		return
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
+
+	if invitation != nil {
+		now := time.Now()
+		user.ActivatedAt = &now
+		// The invitation is accepted together with creating the user so that it can only be used once
+		err = db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Save(&user)
+			if res.Error != nil {
+				return res.Error
+			}
+			return invite.Accept(tx, *invitation, user.ID)
+		})
+		if err != nil {
+			if errors.Is(err, invite.ErrInvalid) {
+				pd.AddMessage(routes.Error, pd.Trans("This invitation is invalid or has expired."))
+			} else {
+				pd.AddMessage(routes.Error, registerError)
+			}
+			slog.Error("Register:SaveUser", "error", err)
+			svc.renderRegister(c, pd, nil, "", http.StatusBadRequest)
+			return
+		}
+
+		audit.RecordUser(c, db, audit.UserRegistered, user.ID, fmt.Sprintf("invitation=%d", invitation.ID))
+		audit.RecordUser(c, db, audit.InvitationAccepted, user.ID, fmt.Sprintf("invitation=%d role=%q", invitation.ID, role.Name))
+
+		pd.AddMessage(routes.Success, pd.Trans("Thank you for registering, you may now login."))
+		svc.renderRegister(c, pd, nil, "", http.StatusOK)
+		return
+	}
 
 	res = db.Save(&user)
 	if res.Error != nil || res.RowsAffected == 0 {
 		pd.AddMessage(routes.Error, registerError)
 		slog.Error("Register:SaveUser", "error", res.Error)
-		c.HTML(http.StatusInternalServerError, "register.gohtml", pd)
+		svc.renderRegister(c, pd, invitation, token, http.StatusInternalServerError)
 		return
 	}
 
+	audit.RecordUser(c, db, audit.UserRegistered, user.ID, "")
+
 	// Generate activation token and send activation email
 	go svc.activationEmailHandler(user.ID, email, pd.Trans)
 
 	pd.AddMessage(routes.Success, registerSuccess)
 
-	c.HTML(http.StatusOK, "register.gohtml", pd)
+	svc.renderRegister(c, pd, nil, "", http.StatusOK)
 }
 
 func (svc Service) activationEmailHandler(userID uint, email string, trans func(string) string) {
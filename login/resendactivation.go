@@ -13,7 +13,7 @@ import (
 func (svc Service) ResendActivation(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
 	pd.Title = pd.Trans("Resend Activation Email")
-	c.HTML(http.StatusOK, "resendactivation.gohtml", pd)
+	svc.renderForm(c, "resendactivation.gohtml", pd, http.StatusOK)
 }
 
 // ResendActivationPost handles the post request for requesting a new activation email
@@ -21,6 +21,9 @@ func (svc Service) ResendActivationPost(c *gin.Context) {
 	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
 	db := svc.env.GetDb()
 	pd.Title = pd.Trans("Resend Activation Email")
+	if !svc.challengePassed(c, "resendactivation.gohtml", pd) {
+		return
+	}
 	email := c.PostForm("email")
 	user := models.User{Email: email}
 	res := db.Where(&user).First(&user)
@@ -44,5 +47,5 @@ func (svc Service) ResendActivationPost(c *gin.Context) {
 
 	// We always return a positive response here to prevent user enumeration and other attacks
 	pd.AddMessage(routes.Success, pd.Trans("A new activation email has been sent if the account exists and is not already activated. Please remember to check your spam inbox in case the email is not showing in your inbox."))
-	c.HTML(http.StatusOK, "resendactivation.gohtml", pd)
+	svc.renderForm(c, "resendactivation.gohtml", pd, http.StatusOK)
 }
@@ -1,13 +1,17 @@
 package login
 
 import (
+	"fmt"
 	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/apitoken"
+	"github.com/uberswe/golang-base-project/audit"
 	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passwords"
 	"github.com/uberswe/golang-base-project/routes"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/uberswe/golang-base-project/session"
 )
 
 // ResetPasswordPageData defines additional data needed to render the reset password page
@@ -31,7 +35,6 @@ func (svc Service) ResetPassword(c *gin.Context) {
 // ResetPasswordPost handles post request used to reset users passwords
 func (svc Service) ResetPasswordPost(c *gin.Context) {
 	pdPre := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
-	passwordError := pdPre.Trans("Your password must be 8 characters in length or longer")
 	resetError := pdPre.Trans("Could not reset password, please try again")
 
 	token := c.Param("token")
@@ -42,12 +45,6 @@ func (svc Service) ResetPasswordPost(c *gin.Context) {
 	}
 	password := c.PostForm("password")
 
-	if len(password) < 8 {
-		pd.AddMessage(routes.Error, passwordError)
-		c.HTML(http.StatusBadRequest, "resetpassword.gohtml", pd)
-		return
-	}
-
 	forgotPasswordToken := models.Token{
 		Value: token,
 		Type:  models.TokenPasswordReset,
@@ -77,7 +74,22 @@ func (svc Service) ResetPasswordPost(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	// The policy is checked once the user is known so that previous passwords can be compared
+	violations := passwords.Check(db, svc.env.GetConfig(), password, user)
+	if len(violations) > 0 {
+		for _, v := range violations {
+			pd.AddMessage(routes.Error, v.Message(pd.Trans))
+		}
+		c.HTML(http.StatusBadRequest, "resetpassword.gohtml", pd)
+		return
+	}
+
+	err := passwords.Remember(db, svc.env.GetConfig(), user)
+	if err != nil {
+		slog.Error("ResetPasswordPost", "error", err)
+	}
+
+	hashedPassword, err := passwords.Hash(svc.env.GetConfig(), password)
 
 	if err != nil {
 		slog.Error("ResetPasswordPost", "error", err)
@@ -86,7 +98,7 @@ func (svc Service) ResetPasswordPost(c *gin.Context) {
 		return
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 
 	res = db.Save(&user)
 	if res.Error != nil {
@@ -102,6 +114,18 @@ func (svc Service) ResetPasswordPost(c *gin.Context) {
 		return
 	}
 
+	// Anyone who was logged in with the old password is logged out
+	count, err := session.RevokeAll(db, user.ID, "")
+	if err != nil {
+		slog.Error("ResetPasswordPost", "error", err)
+	}
+	err = apitoken.RevokeAll(db, user.ID)
+	if err != nil {
+		slog.Error("ResetPasswordPost", "error", err)
+	}
+	slog.Info("ResetPasswordPost:SessionsRevoked", "user", user.ID, "count", count)
+	audit.RecordUser(c, db, audit.PasswordReset, user.ID, fmt.Sprintf("sessions_revoked=%d", count))
+
 	pd.AddMessage(routes.Success, pd.Trans("Your password has been reset successfully."))
 
 	c.HTML(http.StatusOK, "resetpassword.gohtml", pd)
@@ -0,0 +1,87 @@
+package login
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/session"
+)
+
+// startSession creates a new session for the user and stores the session identifier in the session cookie.
+// The user needs to be loaded with its Roles. When remember is true a remember me token is stored in its own cookie
+// so that a new session is started once this one has ended.
+func (svc Service) startSession(c *gin.Context, user models.User, remember bool) error {
+	db := svc.env.GetDb()
+	conf := svc.env.GetConfig()
+
+	family := ""
+	rememberValue := ""
+	if remember && conf.RememberMeDays > 0 {
+		token, value, err := session.Remember(db, conf, user.ID, "")
+		if err != nil {
+			return err
+		}
+		family = token.Family
+		rememberValue = value
+	}
+
+	ses, err := session.Start(db, conf, user, c.ClientIP(), c.Request.UserAgent(), family)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("startSession", "session", ses)
+	audit.RecordUser(c, db, audit.Login, user.ID, fmt.Sprintf("route=%s session=%d remember=%t", c.FullPath(), ses.ID, family != ""))
+
+	if rememberValue != "" {
+		middleware.SetRememberCookie(c, conf, rememberValue)
+	}
+	cookie := middleware.DefaultSessionWithOptions(c)
+	cookie.Set(middleware.SessionIDKey, ses.Identifier)
+	return cookie.Save()
+}
+
+// completeLogin is called once a user has passed the first factor. Users with two-factor authentication
+// enabled are sent to the code step, everyone else gets a session. The url to redirect to is returned.
+// remember is true when the user asked to stay logged in.
+func (svc Service) completeLogin(c *gin.Context, user models.User, remember bool) (string, error) {
+	if user.IsDisabled() {
+		return "", session.ErrDisabled
+	}
+	if user.HasTwoFactor() {
+		return "/login/2fa", svc.requireSecondFactor(c, user, remember)
+	}
+	redirect := returnTo(c)
+	return redirect, svc.startSession(c, user, remember)
+}
+
+// returnTo removes and returns the path stored by a page which required the user to login, such as the
+// OpenID Connect authorize endpoint. The session is saved by startSession.
+func returnTo(c *gin.Context) string {
+	session := middleware.DefaultSessionWithOptions(c)
+	path, _ := session.Get(middleware.ReturnToKey).(string)
+	session.Delete(middleware.ReturnToKey)
+	// Only local paths are allowed so that the value can not be used as an open redirect
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// secureToken returns a token value for links which log a user in, these are generated from a cryptographically
+// secure source unlike the ulid values used for other tokens
+func secureToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
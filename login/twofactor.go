@@ -0,0 +1,147 @@
+package login
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	"github.com/uberswe/golang-base-project/lockout"
+	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+	"github.com/uberswe/golang-base-project/twofactor"
+)
+
+const (
+	// pendingUserIDKey holds the id of a user who has provided a correct password but still needs to provide a second factor
+	pendingUserIDKey = "PendingUserID"
+	// pendingExpiresKey holds the unix time after which the pending login is no longer valid
+	pendingExpiresKey = "PendingExpires"
+	// pendingRememberKey holds whether the user asked to stay logged in
+	pendingRememberKey = "PendingRemember"
+)
+
+// requireSecondFactor remembers that the user has passed the first factor so that the second factor can be requested
+func (svc Service) requireSecondFactor(c *gin.Context, user models.User, remember bool) error {
+	session := middleware.DefaultSessionWithOptions(c)
+	session.Set(pendingUserIDKey, user.ID)
+	session.Set(pendingRememberKey, remember)
+	session.Set(pendingExpiresKey, time.Now().Add(5*time.Minute).Unix())
+	return session.Save()
+}
+
+// pendingUser loads the user who is waiting to provide a second factor, if there is one
+func (svc Service) pendingUser(c *gin.Context) (models.User, bool) {
+	session := middleware.DefaultSessionWithOptions(c)
+	userID, ok := session.Get(pendingUserIDKey).(uint)
+	if !ok {
+		return models.User{}, false
+	}
+	expires, ok := session.Get(pendingExpiresKey).(int64)
+	if !ok || time.Now().Unix() > expires {
+		return models.User{}, false
+	}
+	user := models.User{}
+	user.ID = userID
+	res := svc.env.GetDb().Preload("Roles").Where(&user).First(&user)
+	if res.Error != nil {
+		slog.Error("pendingUser", "error", res.Error)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+func clearPendingUser(session sessions.Session) {
+	session.Delete(pendingUserIDKey)
+	session.Delete(pendingExpiresKey)
+	session.Delete(pendingRememberKey)
+}
+
+// TwoFactor renders the page where users with two-factor authentication enabled enter a code to finish logging in
+func (svc Service) TwoFactor(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Two-Factor Authentication")
+	if _, ok := svc.pendingUser(c); !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	c.HTML(http.StatusOK, "logintwofactor.gohtml", pd)
+}
+
+// TwoFactorPost verifies the TOTP or recovery code of a pending login and creates the session if it is valid
+func (svc Service) TwoFactorPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Two-Factor Authentication")
+	codeError := pd.Trans("The code you entered is not valid, please try again.")
+
+	user, ok := svc.pendingUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	session := middleware.DefaultSessionWithOptions(c)
+	db := svc.env.GetDb()
+
+	status, err := lockout.CheckSecondFactor(db, user.ID)
+	if err != nil {
+		slog.Error("TwoFactorPost", "error", err)
+		pd.AddMessage(routes.Error, pd.Trans("Something went wrong, please try again."))
+		c.HTML(http.StatusInternalServerError, "logintwofactor.gohtml", pd)
+		return
+	}
+	if status.Locked {
+		svc.endPendingLogin(c, pd, session, user)
+		return
+	}
+
+	if !twofactor.Verify(db, &user, c.PostForm("code")) {
+		audit.RecordUser(c, db, audit.LoginFailed, user.ID, "second factor")
+		// Invalid codes are counted in the database as the session cookie could simply be replaced by an older one
+		event, err := lockout.FailSecondFactor(db, svc.env.GetConfig(), user.ID, lockout.SecondFactorMaxAttempts, c.ClientIP())
+		if err != nil {
+			slog.Error("TwoFactorPost", "error", err)
+		}
+		if event != nil {
+			slog.Info("TwoFactorPost:Locked", "user", user.ID, "until", event.LockedUntil)
+			svc.endPendingLogin(c, pd, session, user)
+			return
+		}
+		pd.AddMessage(routes.Error, codeError)
+		c.HTML(http.StatusBadRequest, "logintwofactor.gohtml", pd)
+		return
+	}
+
+	err = lockout.SucceedSecondFactor(db, user.ID)
+	if err != nil {
+		slog.Error("TwoFactorPost", "error", err)
+	}
+	remember, _ := session.Get(pendingRememberKey).(bool)
+	clearPendingUser(session)
+	redirect := returnTo(c)
+	err = svc.startSession(c, user, remember)
+	if err != nil {
+		pd.AddMessage(routes.Error, codeError)
+		slog.Error("TwoFactorPost", "error", err)
+		c.HTML(http.StatusInternalServerError, "logintwofactor.gohtml", pd)
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// endPendingLogin forgets the pending login of a user whose second factor is locked, the user has to login again
+func (svc Service) endPendingLogin(c *gin.Context, pd routes.PageData, session sessions.Session, user models.User) {
+	clearPendingUser(session)
+	err := session.Save()
+	if err != nil {
+		slog.Error("endPendingLogin", "error", err)
+	}
+	slog.Info("TwoFactorPost:TooManyAttempts", "user", user.ID)
+	pd.Title = pd.Trans("Login")
+	pd.AddMessage(routes.Error, pd.Trans("Too many invalid codes were entered, please try again later."))
+	svc.renderLogin(c, pd, http.StatusBadRequest)
+}
@@ -0,0 +1,129 @@
+package login
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/audit"
+	email2 "github.com/uberswe/golang-base-project/email"
+	"github.com/uberswe/golang-base-project/lockout"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/routes"
+)
+
+// UnlockPageData defines additional data needed to render the account unlock page
+type UnlockPageData struct {
+	routes.PageData
+	Token string
+}
+
+// loginFailed records a failed login and emails an unlock link if the account became locked. The message to show is returned.
+func (svc Service) loginFailed(c *gin.Context, pd routes.PageData, email string, loginError string) string {
+	audit.RecordUser(c, svc.env.GetDb(), audit.LoginFailed, 0, fmt.Sprintf("email=%q", email))
+	events, err := lockout.Fail(svc.env.GetDb(), svc.env.GetConfig(), email, c.ClientIP())
+	if err != nil {
+		slog.Error("loginFailed", "error", err)
+	}
+	for _, e := range events {
+		slog.Warn("loginFailed:Locked", "key", e.LockKey, "ip", e.IP)
+		if e.UserID != 0 {
+			go svc.unlockEmailHandler(e.UserID, email, pd.Trans)
+		}
+	}
+	if len(events) > 0 {
+		return pd.Trans("Too many failed login attempts. Logins have been temporarily locked, if you have an account we have sent you an email with a link to unlock it.")
+	}
+	return loginError
+}
+
+func (svc Service) unlockEmailHandler(userID uint, email string, trans func(string) string) {
+	value, err := secureToken()
+	if err != nil {
+		slog.Error("unlockEmailHandler", "error", err)
+		return
+	}
+
+	unlockToken := models.Token{
+		Value:     value,
+		Type:      models.TokenAccountUnlock,
+		ModelID:   int(userID),
+		ModelType: "User",
+		ExpiresAt: time.Now().Add(time.Duration(svc.env.GetConfig().LockoutDuration) * time.Minute),
+	}
+
+	res := svc.env.GetDb().Save(&unlockToken)
+	if res.Error != nil || res.RowsAffected == 0 {
+		slog.Error("unlockEmailHandler", "error", res.Error)
+		return
+	}
+
+	conf := svc.env.GetConfig()
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		slog.Error("unlockEmailHandler", "error", err)
+		return
+	}
+	u.Path = path.Join(u.Path, "/unlock/", unlockToken.Value)
+
+	emailService := email2.New(conf)
+
+	emailService.Send(email, trans("Account locked"), fmt.Sprintf(trans("There were too many failed attempts to login to your account so it has been temporarily locked. If this was you, use the following link to unlock your account. If it was not you, consider changing your password.\n%s"), u.String()))
+}
+
+// Unlock renders a button which unlocks the account. Opening the link does not use the token so that email
+// security scanners which follow links can not use it.
+func (svc Service) Unlock(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Unlock account")
+	c.HTML(http.StatusOK, "unlock.gohtml", UnlockPageData{PageData: pd, Token: c.Param("token")})
+}
+
+// UnlockPost uses the unlock token and removes the lock and failed login attempts of the account
+func (svc Service) UnlockPost(c *gin.Context) {
+	pd := routes.DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Unlock account")
+	unlockError := pd.Trans("This unlock link is invalid or has expired.")
+
+	db := svc.env.GetDb()
+
+	unlockToken := models.Token{
+		Value: c.Param("token"),
+		Type:  models.TokenAccountUnlock,
+	}
+	res := db.Where(&unlockToken).First(&unlockToken)
+	if unlockToken.Value == "" || res.Error != nil || unlockToken.HasExpired() {
+		pd.AddMessage(routes.Error, unlockError)
+		c.HTML(http.StatusBadRequest, "unlock.gohtml", UnlockPageData{PageData: pd})
+		return
+	}
+
+	res = db.Unscoped().Delete(&unlockToken)
+	if res.Error != nil || res.RowsAffected != 1 {
+		pd.AddMessage(routes.Error, unlockError)
+		c.HTML(http.StatusBadRequest, "unlock.gohtml", UnlockPageData{PageData: pd})
+		return
+	}
+
+	user := models.User{}
+	user.ID = uint(unlockToken.ModelID)
+	res = db.Where(&user).First(&user)
+	if res.Error == nil {
+		res.Error = lockout.Unlock(db, lockout.EmailKey(user.Email), "email")
+	}
+	if res.Error != nil {
+		slog.Error("UnlockPost", "error", res.Error)
+		pd.AddMessage(routes.Error, unlockError)
+		c.HTML(http.StatusInternalServerError, "unlock.gohtml", UnlockPageData{PageData: pd})
+		return
+	}
+
+	slog.Info("UnlockPost:Unlocked", "user", user.ID)
+	pd.Title = pd.Trans("Login")
+	pd.AddMessage(routes.Success, pd.Trans("Your account has been unlocked, you may now login."))
+	svc.renderLogin(c, pd, http.StatusOK)
+}
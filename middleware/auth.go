@@ -4,15 +4,18 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/rbac"
 )
 
 // UserIDKey is the key used to set and get the user id in the context of the current request
 const UserIDKey = "UserID"
 const UserRoleKey = "UserRole"
 
+// UserPermissionsKey is the key used to set and get the permissions of the user in the context of the current request
+const UserPermissionsKey = "UserPermissions"
+
 // Auth middleware redirects to /login and aborts the current request if there is no authenticated user
 func Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -27,8 +30,8 @@ func Auth() gin.HandlerFunc {
 	}
 }
 
-// Admin middleware requires user to be logged in and have "admin" role assigned
-func Admin() gin.HandlerFunc {
+// RequirePermission middleware requires the user to be logged in and to have a role which grants the permission
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_, exists := c.Get(UserIDKey)
 		if !exists {
@@ -37,19 +40,23 @@ func Admin() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		role, exists := c.Get(UserRoleKey)
-		roleStr := role.(string)
-		if !exists {
-			slog.Debug("UserRoleKey does not exist in context", "keys", c.Keys)
-			c.Redirect(http.StatusTemporaryRedirect, "/login")
-			c.Abort()
-			return
-		}
-		if !strings.Contains(roleStr, "admin") {
-			slog.Debug("User does not have admin privileges", "role", c.Keys)
-			c.Redirect(http.StatusTemporaryRedirect, "/login")
+		if !Can(c, permission) {
+			slog.Debug("User does not have permission", "permission", permission, "user", c.GetUint(UserIDKey))
+			if _, token := c.Get(APITokenIDKey); token {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient_permission"})
+				return
+			}
+			// Users who are logged in are not sent to /login as it would send them back to /admin
+			c.Redirect(http.StatusTemporaryRedirect, "/")
 			c.Abort()
 			return
 		}
 	}
 }
+
+// Can returns true if the user of the current request has the permission
+func Can(c *gin.Context, permission string) bool {
+	permissions, _ := c.Get(UserPermissionsKey)
+	list, _ := permissions.([]string)
+	return rbac.Has(list, permission)
+}
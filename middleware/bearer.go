@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/apitoken"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/session"
+	"gorm.io/gorm"
+)
+
+// APITokenIDKey is the key used to set and get the id of the personal access token used for the current request
+const APITokenIDKey = "APITokenID"
+
+// lastUsedInterval limits how often the last used time of a token is written to the database
+const lastUsedInterval = time.Minute
+
+// Bearer middleware authenticates requests which have a personal access token in the Authorization header and sets
+// the same context values as the Session middleware. Other bearer tokens, such as OAuth access tokens, are left for
+// the routes which accept them.
+func Bearer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || !strings.HasPrefix(value, apitoken.Prefix) {
+			c.Next()
+			return
+		}
+
+		token := models.APIToken{TokenHash: apitoken.Hash(value)}
+		res := db.Where(&token).First(&token)
+		if res.Error != nil || token.HasExpired() {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+			return
+		}
+		scopes := token.ScopeList()
+		if !apitoken.Allows(scopes, c.Request.Method) {
+			c.Header("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+			return
+		}
+
+		// The roles are loaded for every request so that tokens never have more privileges than the user
+		user := models.User{}
+		user.ID = token.UserID
+		res = db.Preload("Roles").Where(&user).First(&user)
+		if res.Error != nil || user.ActivatedAt == nil || user.IsDisabled() {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+			return
+		}
+
+		var permissions []string
+		if slices.Contains(scopes, apitoken.ScopeAdmin) {
+			var err error
+			permissions, err = rbac.ForRoles(db, user.Roles)
+			if err != nil {
+				slog.Error("Bearer", "error", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+				return
+			}
+		}
+
+		c.Set(UserIDKey, user.ID)
+		c.Set(UserRoleKey, apitoken.Role(session.RoleString(user.Roles), scopes))
+		c.Set(UserPermissionsKey, permissions)
+		c.Set(APITokenIDKey, token.ID)
+
+		if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > lastUsedInterval {
+			res = db.Model(&token).UpdateColumn("last_used_at", time.Now())
+			if res.Error != nil {
+				slog.Error("Bearer", "error", res.Error)
+			}
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly middleware rejects requests which are authenticated with a personal access token, it is used for routes
+// such as managing tokens which should only be available to a logged in user
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get(APITokenIDKey); exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this route can not be used with a personal access token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// PreferencesKey is the key used to set and get the models.Preferences of the user of the current request
+const PreferencesKey = "Preferences"
+
+// Preferences middleware loads the profile preferences of the authenticated user. The preferred language is also set
+// with infra.LanguageKey so that translations use it instead of the Accept-Language header.
+func Preferences(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get(UserIDKey)
+		if !exists {
+			c.Next()
+			return
+		}
+		prefs := models.Preferences{}
+		res := db.Model(&models.User{}).Where("id = ?", userID).Limit(1).Find(&prefs)
+		if res.Error != nil {
+			slog.Error("Preferences", "error", res.Error)
+		}
+		c.Set(PreferencesKey, prefs)
+		if prefs.Language != "" {
+			c.Set(infra.LanguageKey, prefs.Language)
+		}
+		c.Next()
+	}
+}
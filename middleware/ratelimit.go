@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/ratelimit"
+)
+
+// RateLimit middleware counts requests with the named policy for each route and rejects requests over the limit. The
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers tell clients how many requests
+// are left. Requests are let through if the store can not be reached.
+func RateLimit(l *ratelimit.Limiter, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := l.Policy(policy)
+		if !ok {
+			slog.Error("RateLimit", "error", ratelimit.ErrUnknownPolicy, "policy", policy)
+			c.Next()
+			return
+		}
+		p, lctx, err := l.Take(c.Request.Context(), policy, c.FullPath()+":"+rateLimitKey(c, p.By))
+		if err != nil {
+			slog.Error("RateLimit", "error", err, "policy", policy)
+			c.Next()
+			return
+		}
+
+		reset := max(lctx.Reset-time.Now().Unix(), 0)
+		c.Header("RateLimit-Limit", strconv.FormatInt(lctx.Limit, 10))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(lctx.Remaining, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(reset, 10))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", p.Limit, int64(p.Period.Seconds())))
+		if !lctx.Reached {
+			c.Next()
+			return
+		}
+
+		slog.Warn("RateLimit", "policy", policy, "path", c.FullPath(), "ip", c.ClientIP())
+		c.Header("Retry-After", strconv.FormatInt(reset, 10))
+		if c.ContentType() == gin.MIMEJSON || strings.HasPrefix(c.FullPath(), "/api/") {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate_limited"})
+			return
+		}
+		c.String(http.StatusTooManyRequests, "Too many requests, please try again later.")
+		c.Abort()
+	}
+}
+
+// rateLimitKey returns the value which requests are counted by, requests without a user or email are counted by IP
+// address
+func rateLimitKey(c *gin.Context, by string) string {
+	switch by {
+	case ratelimit.ByUser:
+		if id := c.GetUint(UserIDKey); id != 0 {
+			return "user:" + strconv.FormatUint(uint64(id), 10)
+		}
+	case ratelimit.ByEmail:
+		if email := strings.ToLower(strings.TrimSpace(c.PostForm("email"))); email != "" {
+			return "email:" + email
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/ulid"
+)
+
+// RequestIDKey is the key used to set and get the id of the current request
+const RequestIDKey = "RequestID"
+
+// RequestIDHeader is the header which carries the request id to and from proxies
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID middleware gives every request an id which is returned in the X-Request-ID header and stored with audit
+// events. An id set by a proxy in front of the application is used if it looks like an id.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = ulid.Generate()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
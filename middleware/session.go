@@ -1,23 +1,49 @@
 package middleware
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/infra"
 	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/session"
 	"gorm.io/gorm"
 )
 
 // SessionIDKey is the key used to set and get the session id in the context of the current request
 const SessionIDKey = "SessionID"
 
-// Session middleware checks for an active session and sets the UserIDKey to the context of the current request if found
-func Session(db *gorm.DB) gin.HandlerFunc {
+// ReturnToKey is the key used to store the local path a user should be sent to once they have logged in
+const ReturnToKey = "ReturnTo"
+
+// ImpersonatorIDKey is the key used to set and get the id of the admin who is impersonating the user of the current request
+const ImpersonatorIDKey = "ImpersonatorID"
+
+// ReauthenticatedAtKey is the key used to set and get when the user of the current request last confirmed their password
+// or a second factor code
+const ReauthenticatedAtKey = "ReauthenticatedAt"
+
+// ConfirmPath is the page where users confirm their password or a second factor code before changing the security of
+// their account
+const ConfirmPath = "/account/confirm"
+
+// RememberCookie is the name of the cookie which holds the remember me token
+const RememberCookie = "remember_me"
+
+// Session middleware checks for an active session and sets the UserIDKey to the context of the current request if found.
+// Active sessions are renewed while they are used. When there is no active session a remember me token is used to
+// start a new one.
+func Session(db *gorm.DB, conf *infra.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session := DefaultSessionWithOptions(c)
-		sessionIdentifierInterface := session.Get(SessionIDKey)
+		cookie := DefaultSessionWithOptions(c)
+		sessionIdentifierInterface := cookie.Get(SessionIDKey)
 
 		if sessionIdentifier, ok := sessionIdentifierInterface.(string); ok {
 			ses := models.Session{
@@ -27,20 +53,136 @@ func Session(db *gorm.DB) gin.HandlerFunc {
 			if res.Error == nil && !ses.HasExpired() {
 				c.Set(UserIDKey, ses.UserID)
 				c.Set(UserRoleKey, ses.Role)
-			} else {
-				slog.Error("Session", "error", res.Error)
+				c.Set(UserPermissionsKey, rbac.Split(ses.Permissions))
+				c.Set(ReauthenticatedAtKey, ses.ReauthenticatedAt)
+				if ses.IsImpersonation() {
+					c.Set(ImpersonatorIDKey, ses.ImpersonatorID)
+				}
+				err := session.Touch(db, conf, ses)
+				if err != nil {
+					slog.Error("Session", "error", err)
+				}
+				c.Next()
+				return
 			}
+			slog.Error("Session", "error", res.Error)
+		}
+
+		if value, err := c.Cookie(RememberCookie); err == nil && value != "" {
+			remembered(c, db, conf, value)
 		}
 		c.Next()
 	}
 }
 
+// remembered starts a new session from a remember me token and replaces the token
+func remembered(c *gin.Context, db *gorm.DB, conf *infra.Config, value string) {
+	token, newValue, err := session.UseRememberToken(db, conf, value)
+	if errors.Is(err, session.ErrTokenReplaced) {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, session.ErrTokenReused) {
+			slog.Warn("Session:RememberTokenReused", "user", token.UserID, "family", token.Family, "ip", c.ClientIP())
+		} else if !errors.Is(err, session.ErrInvalidToken) {
+			slog.Error("Session", "error", err)
+		}
+		ClearRememberCookie(c, conf)
+		return
+	}
+
+	user := models.User{}
+	user.ID = token.UserID
+	res := db.Preload("Roles").Where(&user).First(&user)
+	if res.Error != nil || user.ActivatedAt == nil || len(user.Roles) == 0 {
+		err = session.Forget(db, token.Family)
+		if err != nil {
+			slog.Error("Session", "error", err)
+		}
+		ClearRememberCookie(c, conf)
+		return
+	}
+
+	ses, err := session.Start(db, conf, user, c.ClientIP(), c.Request.UserAgent(), token.Family)
+	if err == nil {
+		cookie := DefaultSessionWithOptions(c)
+		cookie.Set(SessionIDKey, ses.Identifier)
+		err = cookie.Save()
+	}
+	if err != nil {
+		slog.Error("Session", "error", err)
+		return
+	}
+	SetRememberCookie(c, conf, newValue)
+	c.Set(UserIDKey, ses.UserID)
+	c.Set(UserRoleKey, ses.Role)
+	c.Set(UserPermissionsKey, rbac.Split(ses.Permissions))
+	slog.Info("Session:Remembered", "user", user.ID)
+}
+
+// SetRememberCookie stores the value of a remember me token in its own long lived cookie
+func SetRememberCookie(c *gin.Context, conf *infra.Config, value string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(RememberCookie, value, conf.RememberMeDays*24*60*60, "/", "", strings.HasPrefix(conf.BaseURL, "https://"), true)
+}
+
+// ClearRememberCookie removes the remember me cookie
+func ClearRememberCookie(c *gin.Context, conf *infra.Config) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(RememberCookie, "", -1, "/", "", strings.HasPrefix(conf.BaseURL, "https://"), true)
+}
+
+// NotImpersonating middleware rejects requests made while an admin is impersonating a user, it is used for routes which
+// change the security settings of an account such as passwords, two-factor authentication and sessions
+func NotImpersonating() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get(ImpersonatorIDKey); exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this route can not be used while impersonating a user"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Reauthenticated returns true if the user of the current request confirmed their password or a second factor code
+// within the session.ReauthenticationWindow
+func Reauthenticated(c *gin.Context) bool {
+	at := c.GetTime(ReauthenticatedAtKey)
+	return !at.IsZero() && time.Since(at) < session.ReauthenticationWindow
+}
+
+// RequireReauthentication middleware sends users who have not recently confirmed their password or a second factor code
+// to the ConfirmPath. Pages are shown again once confirmed while form posts return to page, JSON requests get an error.
+func RequireReauthentication(page string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Reauthenticated(c) {
+			c.Next()
+			return
+		}
+		if c.ContentType() == "application/json" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "please reload the page and confirm your password again"})
+			return
+		}
+		next := page
+		if c.Request.Method == http.MethodGet {
+			next = c.Request.URL.RequestURI()
+		}
+		c.Redirect(http.StatusFound, ConfirmPath+"?next="+url.QueryEscape(next))
+		c.Abort()
+	}
+}
+
+// DefaultSessionWithOptions returns the cookie session with the options used by every route
 func DefaultSessionWithOptions(c *gin.Context) sessions.Session {
-	session := sessions.Default(c)
-	// safari strictness requires the SameSite option below
-	session.Options(sessions.Options{
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   60, // one minute for testing purposes
+	cookie := sessions.Default(c)
+	// safari strictness requires the SameSite option below. Lax is needed so that the cookie is sent when an
+	// OpenID Connect provider redirects back to the callback, state changing requests are all POST requests.
+	// The cookie lasts as long as a session can, the database session decides if the user is still logged in.
+	cookie.Options(sessions.Options{
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   infra.LairInstance().GetConfig().SessionLifetime * 60 * 60,
+		HttpOnly: true,
 	})
-	return session
+	return cookie
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIToken is a personal access token a user has created to call the application from scripts. Only a hash of the
+// token is stored, the start of the token is kept so that users can tell their tokens apart.
+type APIToken struct {
+	gorm.Model
+	UserID     uint `gorm:"index"`
+	Name       string
+	Hint       string
+	TokenHash  string `gorm:"uniqueIndex;size:64"`
+	Scopes     string // space separated
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// ScopeList returns the scopes of the token
+func (t APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasExpired returns true if the token has an expiry date which has passed
+func (t APIToken) HasExpired() bool {
+	return t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now())
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditAppendOnly is returned when an audit event would be changed or deleted
+var ErrAuditAppendOnly = errors.New("audit events can not be changed or deleted")
+
+// AuditEvent is a row of the append-only audit log of security and admin events. Each row stores the hash of the
+// previous row and its own hash, so changing or removing a row breaks the chain from that row onwards.
+type AuditEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	ActorID   uint      `gorm:"index"` // the user who took the action, 0 if not logged in
+	Action    string    `gorm:"index"`
+	SubjectID uint      `gorm:"index"` // the user or role the action was taken on, if any
+	Details   string
+	IP        string
+	UserAgent string
+	RequestID string `gorm:"index"`
+	PrevHash  string
+	Hash      string
+}
+
+// BeforeUpdate prevents audit events from being changed through gorm
+func (AuditEvent) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// BeforeDelete prevents audit events from being deleted through gorm
+func (AuditEvent) BeforeDelete(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailChange is a request by a user to change their email. The email is only changed once the new address has been
+// confirmed, the old address receives a link which reverts the change for a few days.
+type EmailChange struct {
+	gorm.Model
+	UserID      uint `gorm:"index"`
+	OldEmail    string
+	NewEmail    string
+	ConfirmedAt *time.Time
+	RevertedAt  *time.Time
+}
+
+// IsPending returns true if the new address has not been confirmed and the change has not been cancelled
+func (e EmailChange) IsPending() bool {
+	return e.ConfirmedAt == nil && e.RevertedAt == nil
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Identity links a user to an account at an external OpenID Connect provider
+type Identity struct {
+	gorm.Model
+	UserID     uint   `gorm:"index"`
+	Provider   string `gorm:"uniqueIndex:idx_identity_provider_subject;size:100"`
+	Subject    string `gorm:"uniqueIndex:idx_identity_provider_subject;size:255"`
+	Email      string
+	LastUsedAt *time.Time
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The states of an invitation
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation lets someone register with the email it was sent to, also while registration is invite only. The user is
+// given the role of the invitation instead of the default role. Only a hash of the token in the link is stored.
+type Invitation struct {
+	gorm.Model
+	Email       string `gorm:"index"`
+	RoleID      uint
+	Role        Role
+	TokenHash   string `gorm:"uniqueIndex;size:64"`
+	InvitedByID uint
+	ExpiresAt   time.Time
+	AcceptedAt  *time.Time
+	// UserID is the user who registered with the invitation
+	UserID    uint
+	RevokedAt *time.Time
+}
+
+// Status returns whether the invitation is pending, accepted, revoked or expired
+func (i Invitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case i.ExpiresAt.Before(time.Now()):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
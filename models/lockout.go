@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FailedLogin counts failed login attempts for an email address or an IP address
+type FailedLogin struct {
+	gorm.Model
+	LockKey       string `gorm:"uniqueIndex;size:255"` // "email:<address>" or "ip:<address>"
+	Count         int
+	LastFailedAt  time.Time
+	NextAttemptAt time.Time  // attempts before this time are rejected without checking the password
+	LockedUntil   *time.Time // set when the number of failures reaches the lockout threshold
+}
+
+// IsLocked returns true if the key is locked at the current time
+func (f FailedLogin) IsLocked() bool {
+	return f.LockedUntil != nil && f.LockedUntil.After(time.Now())
+}
+
+// LockoutEvent records an email address or IP address being locked after too many failed login attempts
+type LockoutEvent struct {
+	gorm.Model
+	LockKey     string `gorm:"index;size:255"`
+	UserID      uint   `gorm:"index"` // zero when the key is an IP address or an email without an account
+	IP          string
+	LockedUntil time.Time
+	UnlockedAt  *time.Time
+	UnlockedBy  string // "email" or the email address of the admin who unlocked the key
+}
+
+// IsActive returns true if the lock has not expired and has not been removed
+func (e LockoutEvent) IsActive() bool {
+	return e.UnlockedAt == nil && e.LockedUntil.After(time.Now())
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OAuthClient is an application registered by an administrator which can use this application as its OpenID Connect provider
+type OAuthClient struct {
+	gorm.Model
+	ClientID     string `gorm:"uniqueIndex;size:100"`
+	SecretHash   string // sha256 hex of the client secret, the secret is only shown when it is generated
+	Name         string
+	RedirectURIs string `gorm:"type:text"` // newline separated list of allowed redirect uris
+	Scopes       string // space separated list of scopes the client may request
+}
+
+// RedirectURIList returns the allowed redirect uris of the client
+func (c OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// ScopeList returns the scopes the client is allowed to request
+func (c OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// OAuthConsent remembers which scopes a user has granted to a client so that the consent screen is only shown once
+type OAuthConsent struct {
+	gorm.Model
+	UserID   uint `gorm:"uniqueIndex:idx_consent_user_client"`
+	ClientID uint `gorm:"uniqueIndex:idx_consent_user_client"`
+	Scopes   string
+}
+
+// OAuthAuthorization holds an authorization code issued to a client and the values needed to issue tokens for it
+type OAuthAuthorization struct {
+	gorm.Model
+	CodeHash      string `gorm:"uniqueIndex;size:64"`
+	ClientID      uint   `gorm:"index"`
+	UserID        uint   `gorm:"index"`
+	RedirectURI   string `gorm:"type:text"`
+	Scopes        string
+	Nonce         string `gorm:"type:text"`
+	CodeChallenge string
+	Role          string // comma separated roles of the session which authorized the client
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// HasExpired is a helper function that checks if the current time is after the code expiration time
+func (a OAuthAuthorization) HasExpired() bool {
+	return a.ExpiresAt.Before(time.Now())
+}
+
+// OAuthAccessToken is an access token issued to a client which can be used at the userinfo endpoint
+type OAuthAccessToken struct {
+	gorm.Model
+	TokenHash       string `gorm:"uniqueIndex;size:64"`
+	AuthorizationID uint   `gorm:"index"`
+	ClientID        uint   `gorm:"index"`
+	UserID          uint   `gorm:"index"`
+	Scopes          string
+	Role            string
+	ExpiresAt       time.Time
+}
+
+// HasExpired is a helper function that checks if the current time is after the token expiration time
+func (t OAuthAccessToken) HasExpired() bool {
+	return t.ExpiresAt.Before(time.Now())
+}
+
+// SigningKey is a private key used to sign ID tokens, the public keys are published at the JWKS endpoint
+type SigningKey struct {
+	gorm.Model
+	KeyID      string `gorm:"uniqueIndex;size:100"`
+	PrivateKey string `gorm:"type:text"` // PEM encoded PKCS #8 private key
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Passkey holds a WebAuthn credential registered by a user which can be used to login without a password
+type Passkey struct {
+	gorm.Model
+	UserID       uint `gorm:"index"`
+	Name         string
+	CredentialID string `gorm:"uniqueIndex;size:255"` // base64url encoded credential id
+	Credential   string `gorm:"type:text"`            // JSON encoded webauthn.Credential
+	LastUsedAt   *time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// PasswordHistory holds a previous password hash of a user so that passwords are not reused
+type PasswordHistory struct {
+	gorm.Model
+	UserID uint `gorm:"index"`
+	Hash   string
+}
@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// Permission is a named permission which can be granted to roles, routes require permissions rather than roles
+type Permission struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;size:100;not null"`
+	Description string
+	Roles       []Role `gorm:"many2many:role_permissions;"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RateLimitCounter counts the requests of one rate limit key when rate limits are stored in the database. The bucket
+// is a hash of the key so that long keys such as emails fit in the primary key.
+type RateLimitCounter struct {
+	Bucket    string `gorm:"primarykey;size:64"`
+	Hits      int64
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// RateLimitPolicy holds the limit of a policy which was changed on the configuration page, it overrides the limit from
+// the environment so that every instance uses it and it is kept after a restart
+type RateLimitPolicy struct {
+	Name      string `gorm:"primarykey;size:100"`
+	Requests  int
+	Period    time.Duration
+	UpdatedAt time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode holds a hashed one-time code which can be used instead of a TOTP code if a user loses their authenticator
+type RecoveryCode struct {
+	gorm.Model
+	UserID uint `gorm:"index"`
+	Hash   string
+	UsedAt *time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RememberToken keeps a user logged in after their session has ended when they chose "remember me" at login. The
+// cookie holds the selector and a validator of which only a hash is stored. Every use replaces the token with a new
+// one in the same family, a token which is used again after it has been replaced invalidates the whole family.
+type RememberToken struct {
+	gorm.Model
+	UserID        uint   `gorm:"index"`
+	Family        string `gorm:"index"`
+	Selector      string `gorm:"uniqueIndex;size:64"`
+	ValidatorHash string
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// HasExpired returns true if the token can no longer be used
+func (t RememberToken) HasExpired() bool {
+	return t.ExpiresAt.Before(time.Now())
+}
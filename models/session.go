@@ -12,10 +12,33 @@ type Session struct {
 	Identifier string
 	UserID     uint
 	Role       string
-	ExpiresAt  time.Time
+	// Permissions are the permissions of the roles, resolved when the session starts or the roles change
+	Permissions string
+	// ExpiresAt is moved forward while the session is used, AbsoluteExpiresAt is when the session ends regardless
+	ExpiresAt         time.Time
+	AbsoluteExpiresAt time.Time
+	// RememberFamily is the family of the remember me token which belongs to the same login, if any
+	RememberFamily string
+	// IP and UserAgent are recorded when the user logs in so that users can recognize their sessions
+	IP         string
+	UserAgent  string
+	LastSeenAt time.Time
+	// ImpersonatorID is the admin who started the session to see the website as the user, ImpersonatorSession is the
+	// identifier of the admin's own session which is used again when the impersonation ends
+	ImpersonatorID      uint
+	ImpersonatorSession string
+	// ReauthenticatedAt is when the user last confirmed their password or a second factor code in this session, some
+	// changes to the security of an account are only allowed shortly after
+	ReauthenticatedAt time.Time
+}
+
+// IsImpersonation returns true if the session was started by an admin impersonating the user
+func (s Session) IsImpersonation() bool {
+	return s.ImpersonatorID != 0
 }
 
 // HasExpired is a helper function that checks if the current time is after the session expire datetime
 func (s Session) HasExpired() bool {
-	return s.ExpiresAt.Before(time.Now())
+	now := time.Now()
+	return s.ExpiresAt.Before(now) || (!s.AbsoluteExpiresAt.IsZero() && s.AbsoluteExpiresAt.Before(now))
 }
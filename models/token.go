@@ -25,4 +25,14 @@ const (
 	TokenUserActivation string = "user_activation"
 	// TokenPasswordReset is a constant used to identify tokens used for password resets
 	TokenPasswordReset string = "password_reset"
+	// TokenMagicLink is a constant used to identify single-use tokens which are emailed to users to login without a password
+	TokenMagicLink string = "magic_link"
+	// TokenAccountUnlock is a constant used to identify tokens emailed to users to unlock their account after a lockout
+	TokenAccountUnlock string = "account_unlock"
+	// TokenEmailChange is a constant used to identify tokens emailed to a new address to confirm an email change
+	TokenEmailChange string = "email_change"
+	// TokenEmailRevert is a constant used to identify tokens emailed to the old address to revert an email change
+	TokenEmailRevert string = "email_revert"
+	// TokenAccountRestore is a constant used to identify tokens emailed to users to restore their account after deleting it
+	TokenAccountRestore string = "account_restore"
 )
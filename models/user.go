@@ -10,12 +10,41 @@ import (
 // User holds information relating to users that use the application
 type User struct {
 	gorm.Model
-	Email       string
-	Password    string
-	ActivatedAt *time.Time
-	Roles       []Role  `gorm:"many2many:user_roles;"` // Many-to-many relationship with Role
-	Tokens      []Token `gorm:"polymorphic:Model;"`
-	Sessions    []Session
+	Email         string
+	Password      string
+	ActivatedAt   *time.Time
+	TOTPSecret    string
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64
+	// DisabledAt is set when an admin has disabled the account, disabled users can not log in
+	DisabledAt *time.Time
+	// PurgedAt is set once the sessions, tokens and credentials of a deleted user have been removed for good
+	PurgedAt *time.Time
+	// Preferences are chosen by the user on their profile
+	Preferences   `gorm:"embedded"`
+	Roles         []Role  `gorm:"many2many:user_roles;"` // Many-to-many relationship with Role
+	Tokens        []Token `gorm:"polymorphic:Model;"`
+	Sessions      []Session
+	Identities    []Identity
+	RecoveryCodes []RecoveryCode
+}
+
+// Preferences are the profile settings a user chooses for themselves
+type Preferences struct {
+	DisplayName string
+	Language    string // a language tag such as "sv", empty uses the languages of the browser
+	TimeZone    string // an IANA time zone such as "Europe/Stockholm", empty uses the time zone of the server
+	Theme       string // "light", "dark" or "auto" which follows the browser, empty is light
+}
+
+// HasTwoFactor returns true if the user has confirmed a TOTP secret and must provide a code when logging in
+func (u User) HasTwoFactor() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != ""
+}
+
+// IsDisabled returns true if an admin has disabled the account
+func (u User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
 
 // Role represents a user role (user,admin,etc)
@@ -23,5 +52,6 @@ type Role struct {
 	gorm.Model
 	Name        string `gorm:"uniqueIndex;not null"`
 	Description string
-	Users       []User `gorm:"many2many:user_roles;"` // Many-to-many relationship with User
+	Users       []User       `gorm:"many2many:user_roles;"`       // Many-to-many relationship with User
+	Permissions []Permission `gorm:"many2many:role_permissions;"` // Many-to-many relationship with Permission
 }
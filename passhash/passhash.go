@@ -0,0 +1,119 @@
+// Package passhash hashes passwords with Argon2id and encodes the hashes as PHC strings so that the algorithm and its
+// parameters are stored with each hash. Bcrypt hashes created before Argon2id was used are still verified.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2idPrefix starts every hash created by Hash
+const argon2idPrefix = "$argon2id$"
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// ErrInvalidHash is returned when a hash is not a valid PHC string or bcrypt hash
+var ErrInvalidHash = errors.New("passhash: invalid hash")
+
+// b64 is the unpadded base64 encoding used by PHC strings
+var b64 = base64.RawStdEncoding
+
+// Params are the Argon2id parameters used for new hashes
+type Params struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultParams follow the OWASP recommendation for Argon2id
+var DefaultParams = Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}
+
+// ParamsOf returns the parameters of the configured values. Memory and iterations have to be at least 1 and parallelism
+// between 1 and 255, otherwise DefaultParams and false are returned as the values can not be used by Argon2id.
+func ParamsOf(memory int, iterations int, parallelism int) (Params, bool) {
+	if memory < 1 || int64(memory) > math.MaxUint32 || iterations < 1 || int64(iterations) > math.MaxUint32 || parallelism < 1 || parallelism > math.MaxUint8 {
+		return DefaultParams, false
+	}
+	return Params{Memory: uint32(memory), Iterations: uint32(iterations), Parallelism: uint8(parallelism)}, true
+}
+
+// Hash returns an Argon2id hash of the password as a PHC string, for example
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func Hash(password string, p Params) (string, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, keyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, p.Memory, p.Iterations,
+		p.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify returns true if the password matches the hash. Both Argon2id PHC strings and legacy bcrypt hashes are supported.
+func Verify(hash string, password string) (bool, error) {
+	if IsLegacy(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	p, salt, key, err := decode(hash)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash returns true if the hash is a legacy hash or was created with different parameters
+func NeedsRehash(hash string, p Params) bool {
+	current, _, _, err := decode(hash)
+	return err != nil || current != p
+}
+
+// IsLegacy returns true if the hash is a bcrypt hash
+func IsLegacy(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+// Argon2idPattern is a SQL LIKE pattern matching columns which contain an Argon2id hash
+const Argon2idPattern = argon2idPrefix + "%"
+
+// decode parses an Argon2id PHC string
+func decode(hash string) (p Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || "$"+parts[1]+"$" != argon2idPrefix {
+		return p, nil, nil, ErrInvalidHash
+	}
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	salt, err = b64.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	key, err = b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidHash
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,119 @@
+package passhash
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams are cheap parameters so that the tests run quickly
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestVerify(t *testing.T) {
+	argon, err := Hash("correct horse", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		err      error
+	}{
+		{"argon2id match", argon, "correct horse", true, nil},
+		{"argon2id mismatch", argon, "battery staple", false, nil},
+		{"argon2id empty password", argon, "", false, nil},
+		{"bcrypt match", string(legacy), "correct horse", true, nil},
+		{"bcrypt mismatch", string(legacy), "battery staple", false, nil},
+		{"unknown format", "plain text", "plain text", false, ErrInvalidHash},
+		{"wrong version", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", "x", false, ErrInvalidHash},
+		{"missing key", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$", "x", false, ErrInvalidHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.hash, tt.password)
+			if got != tt.want {
+				t.Errorf("Verify = %t, want %t", got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHashIsSalted(t *testing.T) {
+	a, err := Hash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Hash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current, err := Hash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		p    Params
+		want bool
+	}{
+		{"same parameters", current, testParams, false},
+		{"more memory", current, Params{Memory: 128, Iterations: 1, Parallelism: 1}, true},
+		{"more iterations", current, Params{Memory: 64, Iterations: 2, Parallelism: 1}, true},
+		{"more parallelism", current, Params{Memory: 64, Iterations: 1, Parallelism: 2}, true},
+		{"bcrypt", string(legacy), testParams, true},
+		{"invalid", "invalid", testParams, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash, tt.p); got != tt.want {
+				t.Errorf("NeedsRehash = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsOf(t *testing.T) {
+	tests := []struct {
+		name                            string
+		memory, iterations, parallelism int
+		want                            Params
+		ok                              bool
+	}{
+		{"valid", 1024, 3, 2, Params{Memory: 1024, Iterations: 3, Parallelism: 2}, true},
+		{"no memory", 0, 3, 2, DefaultParams, false},
+		{"no iterations", 1024, 0, 2, DefaultParams, false},
+		{"negative parallelism", 1024, 3, -1, DefaultParams, false},
+		{"too much parallelism", 1024, 3, 256, DefaultParams, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParamsOf(tt.memory, tt.iterations, tt.parallelism)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ParamsOf = %+v, %t, want %+v, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package passkey adapts users and their stored credentials to the WebAuthn library used for passkey registration and login
+package passkey
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// RegistrationSessionKey holds the WebAuthn session data of an ongoing registration ceremony
+	RegistrationSessionKey = "PasskeyRegistration"
+	// LoginSessionKey holds the WebAuthn session data of an ongoing login ceremony
+	LoginSessionKey = "PasskeyLogin"
+)
+
+// New returns a WebAuthn instance where the relying party is derived from the configured BaseURL.
+// BaseURL can be changed at runtime so a new instance is created for every ceremony.
+func New(conf *infra.Config) (*webauthn.WebAuthn, error) {
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("base url has no host: %s", conf.BaseURL)
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: u.Hostname(),
+		RPOrigins:     []string{fmt.Sprintf("%s://%s", u.Scheme, u.Host)},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login: webauthn.TimeoutConfig{
+				Enforce: true,
+				Timeout: 5 * time.Minute,
+			},
+			Registration: webauthn.TimeoutConfig{
+				Enforce: true,
+				Timeout: 5 * time.Minute,
+			},
+		},
+	})
+}
+
+// User wraps a models.User together with its passkeys and implements webauthn.User
+type User struct {
+	models.User
+	Passkeys []models.Passkey
+}
+
+// WebAuthnID returns the user handle, the user id encoded as 8 bytes
+func (u User) WebAuthnID() []byte {
+	return UserHandle(u.ID)
+}
+
+// WebAuthnName returns the email of the user
+func (u User) WebAuthnName() string {
+	return u.Email
+}
+
+// WebAuthnDisplayName returns the email of the user
+func (u User) WebAuthnDisplayName() string {
+	return u.Email
+}
+
+// WebAuthnCredentials decodes the stored credentials of the user, credentials which can not be decoded are skipped
+func (u User) WebAuthnCredentials() []webauthn.Credential {
+	var credentials []webauthn.Credential
+	for _, p := range u.Passkeys {
+		credential, err := Decode(p)
+		if err != nil {
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials
+}
+
+// UserHandle encodes a user id as the opaque user handle stored on authenticators
+func UserHandle(id uint) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+// UserIDFromHandle decodes a user handle created by UserHandle
+func UserIDFromHandle(handle []byte) (uint, error) {
+	if len(handle) != 8 {
+		return 0, errors.New("invalid user handle")
+	}
+	return uint(binary.BigEndian.Uint64(handle)), nil
+}
+
+// LoadUser loads the user and its passkeys, roles are preloaded so that a session can be created from the result
+func LoadUser(db *gorm.DB, id uint) (User, error) {
+	u := User{}
+	u.ID = id
+	res := db.Preload("Roles").Where(&u.User).First(&u.User)
+	if res.Error != nil {
+		return u, res.Error
+	}
+	res = db.Where(&models.Passkey{UserID: id}).Find(&u.Passkeys)
+	return u, res.Error
+}
+
+// EncodeID returns the credential id in the format stored in models.Passkey.CredentialID
+func EncodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// Encode converts a credential into a passkey model which can be saved
+func Encode(userID uint, name string, credential *webauthn.Credential) (models.Passkey, error) {
+	b, err := json.Marshal(credential)
+	if err != nil {
+		return models.Passkey{}, err
+	}
+	return models.Passkey{
+		UserID:       userID,
+		Name:         name,
+		CredentialID: EncodeID(credential.ID),
+		Credential:   string(b),
+	}, nil
+}
+
+// Decode returns the webauthn.Credential stored in a passkey model
+func Decode(p models.Passkey) (webauthn.Credential, error) {
+	credential := webauthn.Credential{}
+	err := json.Unmarshal([]byte(p.Credential), &credential)
+	return credential, err
+}
+
+// SaveSessionData stores the data of a ceremony in the cookie session under key
+func SaveSessionData(session sessions.Session, key string, data *webauthn.SessionData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	session.Set(key, string(b))
+	return session.Save()
+}
+
+// TakeSessionData loads and removes the data of a ceremony from the cookie session so that it can only be used once
+func TakeSessionData(session sessions.Session, key string) (webauthn.SessionData, error) {
+	data := webauthn.SessionData{}
+	s, ok := session.Get(key).(string)
+	if !ok {
+		return data, errors.New("no ceremony in progress")
+	}
+	session.Delete(key)
+	err := session.Save()
+	if err != nil {
+		return data, err
+	}
+	err = json.Unmarshal([]byte(s), &data)
+	return data, err
+}
@@ -0,0 +1,105 @@
+package passkey
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+)
+
+// memorySession keeps the session values in a map, the methods which are not used by this package are left unimplemented
+type memorySession struct {
+	sessions.Session
+	values map[interface{}]interface{}
+}
+
+func (s *memorySession) Get(key interface{}) interface{}      { return s.values[key] }
+func (s *memorySession) Set(key interface{}, val interface{}) { s.values[key] = val }
+func (s *memorySession) Delete(key interface{})               { delete(s.values, key) }
+func (s *memorySession) Save() error                          { return nil }
+
+func TestUserHandle(t *testing.T) {
+	for _, id := range []uint{1, 42, 1 << 40} {
+		got, err := UserIDFromHandle(UserHandle(id))
+		if err != nil || got != id {
+			t.Errorf("UserIDFromHandle(UserHandle(%d)) = %d, %v", id, got, err)
+		}
+	}
+	for _, handle := range [][]byte{nil, {1, 2, 3}, make([]byte, 16)} {
+		if _, err := UserIDFromHandle(handle); err == nil {
+			t.Errorf("UserIDFromHandle(%x) accepted an invalid handle", handle)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	credential := &webauthn.Credential{ID: []byte{0xfb, 0xff, 0x01}, PublicKey: []byte("public key")}
+	credential.Authenticator.SignCount = 7
+
+	p, err := Encode(3, "Laptop", credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserID != 3 || p.Name != "Laptop" || p.CredentialID != "-_8B" {
+		t.Errorf("Encode = %+v", p)
+	}
+	got, err := Decode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.ID, credential.ID) || !bytes.Equal(got.PublicKey, credential.PublicKey) || got.Authenticator.SignCount != 7 {
+		t.Errorf("Decode = %+v, want %+v", got, credential)
+	}
+
+	// Credentials which can not be decoded are skipped
+	u := User{Passkeys: []models.Passkey{p, {Credential: "not json"}}}
+	if n := len(u.WebAuthnCredentials()); n != 1 {
+		t.Errorf("%d credentials, want 1", n)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		rpID    string
+		origin  string
+		wantErr bool
+	}{
+		{"https://example.com", "example.com", "https://example.com", false},
+		{"http://localhost:8080/app", "localhost", "http://localhost:8080", false},
+		{"example.com", "", "", true},
+		{"://", "", "", true},
+	}
+	for _, tt := range tests {
+		w, err := New(&infra.Config{BaseURL: tt.baseURL})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%q) error = %v, want an error %t", tt.baseURL, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (w.Config.RPID != tt.rpID || len(w.Config.RPOrigins) != 1 || w.Config.RPOrigins[0] != tt.origin) {
+			t.Errorf("New(%q) = %s %v, want %s %s", tt.baseURL, w.Config.RPID, w.Config.RPOrigins, tt.rpID, tt.origin)
+		}
+	}
+}
+
+func TestTakeSessionData(t *testing.T) {
+	session := &memorySession{values: map[interface{}]interface{}{}}
+	err := SaveSessionData(session, RegistrationSessionKey, &webauthn.SessionData{Challenge: "challenge", UserID: UserHandle(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TakeSessionData(session, LoginSessionKey); err == nil {
+		t.Error("the registration ceremony was returned for a login")
+	}
+	data, err := TakeSessionData(session, RegistrationSessionKey)
+	if err != nil || data.Challenge != "challenge" || !bytes.Equal(data.UserID, UserHandle(5)) {
+		t.Errorf("TakeSessionData = %+v, %v", data, err)
+	}
+	// A ceremony can only be finished once
+	if _, err := TakeSessionData(session, RegistrationSessionKey); err == nil {
+		t.Error("the ceremony was returned a second time")
+	}
+}
@@ -0,0 +1,138 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// prefixLength is the number of hex characters of the SHA-1 hash used to look up a range, the same as the
+// k-anonymity range API of Have I Been Pwned
+const prefixLength = 5
+
+// BreachList looks up passwords in a local list of breached password hashes. The file contains one uppercase SHA-1
+// hash per line, optionally followed by :count, sorted by hash. This is the format of the Have I Been Pwned downloader
+// and the ranges of the k-anonymity API concatenated with their prefix. The file is searched without loading it into memory.
+type BreachList struct {
+	path string
+}
+
+// NewBreachList returns a BreachList for the file at path
+func NewBreachList(path string) BreachList {
+	return BreachList{path: path}
+}
+
+// Contains returns true if the SHA-1 hash of the password is in the list
+func (b BreachList) Contains(password string) (bool, error) {
+	h := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(h[:]))
+	suffixes, err := b.Range(hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, s := range suffixes {
+		if s == hash[prefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Range returns the hash suffixes in the list which start with the prefix, like a response from the k-anonymity range API
+func (b BreachList) Range(prefix string) ([]string, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.ToUpper(prefix)
+
+	// Binary search for the smallest offset where the next line starts with a hash greater than or equal to the prefix
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := lineAfter(f, mid)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" || hashOf(line) >= prefix {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	// Read the lines of the range from there
+	start, err := lineStart(f, lo)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	var suffixes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash := hashOf(scanner.Text())
+		if hash == "" {
+			continue
+		}
+		if !strings.HasPrefix(hash, prefix) {
+			if hash > prefix {
+				break
+			}
+			continue
+		}
+		suffixes = append(suffixes, hash[len(prefix):])
+	}
+	return suffixes, scanner.Err()
+}
+
+// hashOf returns the uppercase hash of a line without the count
+func hashOf(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
+
+// lineStart returns the offset of the first line which starts at or after off
+func lineStart(f *os.File, off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	// Read from the byte before off so that a line starting exactly at off is found
+	_, err := f.Seek(off-1, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+	skipped, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return off - 1 + int64(len(skipped)), nil
+}
+
+// lineAfter returns the first line which starts at or after off, an empty string is returned at the end of the file
+func lineAfter(f *os.File, off int64) (string, error) {
+	start, err := lineStart(f, off)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Seek(start, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return line, nil
+}
@@ -0,0 +1,17 @@
+package passwords
+
+import (
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/passhash"
+)
+
+// Params returns the configured Argon2id parameters for new password hashes, or the defaults if they are invalid
+func Params(conf *infra.Config) passhash.Params {
+	p, _ := passhash.ParamsOf(conf.PasswordArgon2Memory, conf.PasswordArgon2Iterations, conf.PasswordArgon2Parallelism)
+	return p
+}
+
+// Hash returns a hash of the password using the configured parameters
+func Hash(conf *infra.Config, password string) (string, error) {
+	return passhash.Hash(password, Params(conf))
+}
@@ -0,0 +1,152 @@
+// Package passwords checks new passwords against the configured password policy
+package passwords
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/passhash"
+	"gorm.io/gorm"
+)
+
+// Violation is a rule of the password policy which a password does not follow
+type Violation struct {
+	message string // english message used as the translation key, may contain a %d verb for arg
+	arg     int
+}
+
+// Message returns the translated message describing the violation
+func (v Violation) Message(trans func(string) string) string {
+	if strings.Contains(v.message, "%d") {
+		return fmt.Sprintf(trans(v.message), v.arg)
+	}
+	return trans(v.message)
+}
+
+// Check returns every rule of the password policy the password violates. The user is the user the password is for,
+// the email is used for the similarity check and the password history is checked if the user has an id.
+func Check(db *gorm.DB, conf *infra.Config, password string, user models.User) []Violation {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if length < conf.PasswordMinLength {
+		violations = append(violations, Violation{"Your password must be at least %d characters long.", conf.PasswordMinLength})
+	}
+	if conf.PasswordMaxLength > 0 && length > conf.PasswordMaxLength {
+		violations = append(violations, Violation{"Your password can not be longer than %d characters.", conf.PasswordMaxLength})
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if conf.PasswordRequireLower && !lower {
+		violations = append(violations, Violation{message: "Your password must contain a lowercase letter."})
+	}
+	if conf.PasswordRequireUpper && !upper {
+		violations = append(violations, Violation{message: "Your password must contain an uppercase letter."})
+	}
+	if conf.PasswordRequireDigit && !digit {
+		violations = append(violations, Violation{message: "Your password must contain a number."})
+	}
+	if conf.PasswordRequireSymbol && !symbol {
+		violations = append(violations, Violation{message: "Your password must contain a symbol."})
+	}
+
+	if similarToEmail(password, user.Email) {
+		violations = append(violations, Violation{message: "Your password is too similar to your email address."})
+	}
+
+	if conf.PasswordBreachedList != "" {
+		breached, err := NewBreachList(conf.PasswordBreachedList).Contains(password)
+		if err != nil {
+			slog.Error("passwords.Check", "error", err)
+		}
+		if breached {
+			violations = append(violations, Violation{message: "This password has appeared in a data breach, please choose a different password."})
+		}
+	}
+
+	if user.ID != 0 && conf.PasswordHistory > 0 {
+		reused, err := reused(db, conf, password, user)
+		if err != nil {
+			slog.Error("passwords.Check", "error", err)
+		}
+		if reused {
+			violations = append(violations, Violation{"Your password can not be the same as any of your last %d passwords.", conf.PasswordHistory})
+		}
+	}
+
+	return violations
+}
+
+// similarToEmail returns true if the password contains the email address or the part before the @, or the other way around
+func similarToEmail(password string, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, _ := strings.Cut(email, "@")
+	if len(local) < 3 || len(password) < 3 {
+		return false
+	}
+	return strings.Contains(password, local) || strings.Contains(email, password)
+}
+
+// reused returns true if the password matches the current password of the user or one in the password history. The
+// current password counts as one of the last PasswordHistory passwords.
+func reused(db *gorm.DB, conf *infra.Config, password string, user models.User) (bool, error) {
+	hashes := []string{user.Password}
+	var history []models.PasswordHistory
+	res := db.Where(&models.PasswordHistory{UserID: user.ID}).Order("created_at desc").Limit(conf.PasswordHistory - 1).Find(&history)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	for _, h := range history {
+		hashes = append(hashes, h.Hash)
+	}
+	for _, h := range hashes {
+		if h == "" {
+			continue
+		}
+		match, err := passhash.Verify(h, password)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Remember adds the current password hash of the user to the password history before it is changed and removes
+// entries which are older than the configured history size
+func Remember(db *gorm.DB, conf *infra.Config, user models.User) error {
+	if conf.PasswordHistory <= 1 || user.Password == "" {
+		return nil
+	}
+	res := db.Save(&models.PasswordHistory{UserID: user.ID, Hash: user.Password})
+	if res.Error != nil {
+		return res.Error
+	}
+	var keep []uint
+	res = db.Model(&models.PasswordHistory{}).Where(&models.PasswordHistory{UserID: user.ID}).
+		Order("created_at desc").Limit(conf.PasswordHistory-1).Pluck("id", &keep)
+	if res.Error != nil {
+		return res.Error
+	}
+	return db.Unscoped().Where("user_id = ? AND id NOT IN ?", user.ID, keep).Delete(&models.PasswordHistory{}).Error
+}
@@ -0,0 +1,171 @@
+// Package ratelimit limits requests with named policies. Each policy counts requests by IP address, user or email for
+// each route, the counts are kept in a store which can be shared by several instances of the application and the
+// limits of the policies can be changed while the application runs. Changed limits are stored in the database so that
+// every instance uses them.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/ulule/limiter/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The values a policy can count requests by
+const (
+	ByIP    = "ip"
+	ByUser  = "user"
+	ByEmail = "email"
+)
+
+// The policies used by the routes
+const (
+	// Forms limits the public forms, such as login and register, by IP address
+	Forms = "forms"
+	// Email limits the public forms which take an email by the email, so that an account can not be targeted from many
+	// IP addresses
+	Email = "email"
+	// API limits the JSON API by user
+	API = "api"
+	// Account limits the account forms which check the password or send emails by user, so that a stolen session can
+	// not be used to guess the password
+	Account = "account"
+)
+
+var (
+	// ErrUnknownPolicy is returned when a policy does not exist
+	ErrUnknownPolicy = errors.New("ratelimit: unknown policy")
+	// ErrInvalidLimit is returned when a limit or period is not positive
+	ErrInvalidLimit = errors.New("ratelimit: limit and period must be positive")
+)
+
+// Policy is a named limit of requests per period
+type Policy struct {
+	Name   string
+	By     string
+	Limit  int
+	Period time.Duration
+}
+
+// Limiter checks requests against the policies
+type Limiter struct {
+	store    limiter.Store
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+// New returns a limiter which keeps its counts in the store
+func New(store limiter.Store, policies []Policy) *Limiter {
+	l := &Limiter{store: store, policies: map[string]Policy{}}
+	for _, p := range policies {
+		l.policies[p.Name] = p
+	}
+	return l
+}
+
+// Policies returns the policies sorted by name
+func (l *Limiter) Policies() []Policy {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var list []Policy
+	for _, p := range l.policies {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Policy returns the policy with the name
+func (l *Limiter) Policy(name string) (Policy, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	p, ok := l.policies[name]
+	return p, ok
+}
+
+// Set changes the limit and period of a policy, counts which have already started keep their period
+func (l *Limiter) Set(name string, limit int, period time.Duration) error {
+	if limit < 1 || period <= 0 {
+		return ErrInvalidLimit
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p, ok := l.policies[name]
+	if !ok {
+		return ErrUnknownPolicy
+	}
+	p.Limit = limit
+	p.Period = period
+	l.policies[name] = p
+	return nil
+}
+
+// Save changes a policy like Set and stores the change in the database, other instances use it once they Load the
+// policies again
+func (l *Limiter) Save(db *gorm.DB, name string, limit int, period time.Duration) error {
+	if limit < 1 || period <= 0 {
+		return ErrInvalidLimit
+	}
+	if _, ok := l.Policy(name); !ok {
+		return ErrUnknownPolicy
+	}
+	err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.RateLimitPolicy{Name: name, Requests: limit, Period: period}).Error
+	if err != nil {
+		return err
+	}
+	return l.Set(name, limit, period)
+}
+
+// Load changes the policies to the limits stored in the database, stored policies which no longer exist are skipped
+func (l *Limiter) Load(db *gorm.DB) error {
+	var stored []models.RateLimitPolicy
+	res := db.Find(&stored)
+	if res.Error != nil {
+		return res.Error
+	}
+	for _, p := range stored {
+		err := l.Set(p.Name, p.Requests, p.Period)
+		if err != nil {
+			slog.Warn("ratelimit:Load", "policy", p.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// reloadInterval is how often Watch loads the policies again
+const reloadInterval = time.Minute
+
+// Watch runs Load in the background every minute until the application stops, so that changes made on another instance
+// apply to this one
+func (l *Limiter) Watch(db *gorm.DB) {
+	go func() {
+		for {
+			time.Sleep(reloadInterval)
+			err := l.Load(db)
+			if err != nil {
+				slog.Error("ratelimit:Load", "error", err)
+			}
+		}
+	}()
+}
+
+// Take counts a request for the key and returns the state of the limit, the key should contain the route and the
+// value the policy counts by
+func (l *Limiter) Take(ctx context.Context, name string, key string) (Policy, limiter.Context, error) {
+	p, ok := l.Policy(name)
+	if !ok {
+		return p, limiter.Context{}, ErrUnknownPolicy
+	}
+	rate := limiter.Rate{Limit: int64(p.Limit), Period: p.Period}
+	lctx, err := l.store.Get(ctx, name+":"+key, rate)
+	return p, lctx, err
+}
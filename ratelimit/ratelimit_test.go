@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+func TestLimiterSet(t *testing.T) {
+	l := New(memory.NewStore(), []Policy{{Name: Forms, By: ByIP, Limit: 5, Period: time.Minute}})
+	tests := []struct {
+		name   string
+		policy string
+		limit  int
+		period time.Duration
+		want   error
+	}{
+		{"valid", Forms, 10, time.Hour, nil},
+		{"unknown policy", "unknown", 10, time.Hour, ErrUnknownPolicy},
+		{"no requests", Forms, 0, time.Hour, ErrInvalidLimit},
+		{"no period", Forms, 10, 0, ErrInvalidLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.Set(tt.policy, tt.limit, tt.period)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Set = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if p, _ := l.Policy(Forms); p.Limit != 10 || p.Period != time.Hour || p.By != ByIP {
+		t.Errorf("policy is %+v after the changes", p)
+	}
+}
+
+func TestLimiterSaveAndLoad(t *testing.T) {
+	db := testDB(t)
+	policies := []Policy{
+		{Name: Forms, By: ByIP, Limit: 5, Period: time.Minute},
+		{Name: Email, By: ByEmail, Limit: 10, Period: 15 * time.Minute},
+	}
+	a := New(memory.NewStore(), policies)
+	b := New(memory.NewStore(), policies)
+
+	err := a.Save(db, Email, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Save(db, Email, 0, time.Hour); !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("Save with no requests = %v, want %v", err, ErrInvalidLimit)
+	}
+	if err = a.Save(db, "unknown", 1, time.Hour); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("Save of an unknown policy = %v, want %v", err, ErrUnknownPolicy)
+	}
+
+	// Another instance uses the saved limit once it loads the policies
+	err = b.Load(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []*Limiter{a, b} {
+		if p, _ := l.Policy(Email); p.Limit != 3 || p.Period != time.Hour {
+			t.Errorf("email policy is %+v, want 3/1h", p)
+		}
+		if p, _ := l.Policy(Forms); p.Limit != 5 || p.Period != time.Minute {
+			t.Errorf("forms policy is %+v, want it unchanged", p)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/common"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+	sredis "github.com/ulule/limiter/v3/drivers/store/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The stores which can keep the counts
+const (
+	BackendMemory = "memory"
+	BackendSQL    = "sql"
+	BackendRedis  = "redis"
+)
+
+// storePrefix is added to every key so that the counts can share a Redis database with other data
+const storePrefix = "ratelimit"
+
+// NewStore returns the store of the backend. The memory store is only seen by one instance of the application, the
+// database and Redis stores are shared by all instances.
+func NewStore(backend string, redisURL string, db *gorm.DB) (limiter.Store, error) {
+	switch backend {
+	case "", BackendMemory:
+		return memory.NewStoreWithOptions(limiter.StoreOptions{
+			Prefix:          storePrefix,
+			CleanUpInterval: limiter.DefaultCleanUpInterval,
+		}), nil
+	case BackendSQL:
+		return &SQLStore{db: db}, nil
+	case BackendRedis:
+		opt, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, err
+		}
+		return sredis.NewStoreWithOptions(redis.NewClient(opt), limiter.StoreOptions{Prefix: storePrefix})
+	}
+	return nil, fmt.Errorf("ratelimit: unknown backend %q", backend)
+}
+
+// sqlCleanUpInterval is how often expired counters are deleted from the database
+const sqlCleanUpInterval = 5 * time.Minute
+
+// SQLStore keeps the counts in the rate_limit_counters table
+type SQLStore struct {
+	db      *gorm.DB
+	mu      sync.Mutex
+	cleaned time.Time
+}
+
+// Get counts a request and returns the state of the limit
+func (s *SQLStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.Increment(ctx, key, 1, rate)
+}
+
+// Increment adds count requests and returns the state of the limit
+func (s *SQLStore) Increment(ctx context.Context, key string, count int64, rate limiter.Rate) (limiter.Context, error) {
+	now := time.Now()
+	counter := models.RateLimitCounter{Bucket: bucket(key)}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RateLimitCounter{}).
+			Where("bucket = ? AND expires_at > ?", counter.Bucket, now).
+			UpdateColumn("hits", gorm.Expr("hits + ?", count))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// There is no counter for the current period, an expired counter is replaced. Another request may have
+			// created the counter since the update, in which case the hits are added to it. The hits are assigned
+			// first as MySQL evaluates the assignments in order.
+			expiresAt := now.Add(rate.Period)
+			counter.Hits = count
+			counter.ExpiresAt = expiresAt
+			res = tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "bucket"}},
+				DoUpdates: clause.Set{
+					{Column: clause.Column{Name: "hits"}, Value: gorm.Expr("CASE WHEN rate_limit_counters.expires_at > ? THEN rate_limit_counters.hits + ? ELSE ? END", now, count, count)},
+					{Column: clause.Column{Name: "expires_at"}, Value: gorm.Expr("CASE WHEN rate_limit_counters.expires_at > ? THEN rate_limit_counters.expires_at ELSE ? END", now, expiresAt)},
+				},
+			}).Create(&counter)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return tx.Where("bucket = ?", counter.Bucket).First(&counter).Error
+	})
+	s.cleanUp(now)
+	return common.GetContextFromState(now, rate, counter.ExpiresAt, counter.Hits), err
+}
+
+// Peek returns the state of the limit without counting a request
+func (s *SQLStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	now := time.Now()
+	counter := models.RateLimitCounter{}
+	res := s.db.WithContext(ctx).Where("bucket = ? AND expires_at > ?", bucket(key), now).Limit(1).Find(&counter)
+	if counter.Bucket == "" {
+		counter.ExpiresAt = now.Add(rate.Period)
+	}
+	return common.GetContextFromState(now, rate, counter.ExpiresAt, counter.Hits), res.Error
+}
+
+// Reset removes the count of the key
+func (s *SQLStore) Reset(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	now := time.Now()
+	res := s.db.WithContext(ctx).Where("bucket = ?", bucket(key)).Delete(&models.RateLimitCounter{})
+	return common.GetContextFromState(now, rate, now.Add(rate.Period), 0), res.Error
+}
+
+// cleanUp deletes expired counters every few minutes so that the table does not grow
+func (s *SQLStore) cleanUp(now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.cleaned) < sqlCleanUpInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.cleaned = now
+	s.mu.Unlock()
+	res := s.db.Where("expires_at <= ?", now).Delete(&models.RateLimitCounter{})
+	if res.Error != nil {
+		slog.Error("SQLStore:cleanUp", "error", res.Error)
+	}
+}
+
+func bucket(key string) string {
+	sum := sha256.Sum256([]byte(storePrefix + ":" + key))
+	return hex.EncodeToString(sum[:])
+}
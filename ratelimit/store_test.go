@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/ulule/limiter/v3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns an in-memory database with the rate limit tables, it uses one connection as every connection to an
+// in-memory database has its own data
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	err = db.AutoMigrate(&models.RateLimitCounter{}, &models.RateLimitPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLStoreIncrement(t *testing.T) {
+	ctx := context.Background()
+	rate := limiter.Rate{Limit: 3, Period: time.Minute}
+
+	tests := []struct {
+		name      string
+		counts    []int64
+		remaining int64
+		reached   bool
+	}{
+		{"first request", []int64{1}, 2, false},
+		{"at the limit", []int64{1, 1, 1}, 0, false},
+		{"over the limit", []int64{1, 1, 1, 1}, 0, true},
+		{"several at once", []int64{2, 2}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &SQLStore{db: testDB(t)}
+			var lctx limiter.Context
+			var err error
+			for _, count := range tt.counts {
+				lctx, err = store.Increment(ctx, "forms:/login:192.0.2.1", count, rate)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if lctx.Remaining != tt.remaining || lctx.Reached != tt.reached {
+				t.Errorf("Remaining = %d, Reached = %t, want %d, %t", lctx.Remaining, lctx.Reached, tt.remaining, tt.reached)
+			}
+		})
+	}
+}
+
+func TestSQLStoreKeysAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	rate := limiter.Rate{Limit: 2, Period: time.Minute}
+	db := testDB(t)
+	store := &SQLStore{db: db}
+
+	for i := 0; i < 3; i++ {
+		_, err := store.Get(ctx, "a", rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lctx, err := store.Get(ctx, "b", rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lctx.Remaining != 1 {
+		t.Errorf("another key has %d remaining, want 1", lctx.Remaining)
+	}
+
+	// Once the period has passed counting starts over
+	err = db.Model(&models.RateLimitCounter{}).Where("bucket = ?", bucket("a")).Update("expires_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	lctx, err = store.Peek(ctx, "a", rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lctx.Remaining != 2 {
+		t.Errorf("expired key peeks %d remaining, want 2", lctx.Remaining)
+	}
+	lctx, err = store.Get(ctx, "a", rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lctx.Remaining != 1 || lctx.Reached {
+		t.Errorf("expired key has %d remaining, reached %t, want 1, false", lctx.Remaining, lctx.Reached)
+	}
+
+	_, err = store.Reset(ctx, "a", rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lctx, err = store.Peek(ctx, "a", rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lctx.Remaining != 2 {
+		t.Errorf("reset key peeks %d remaining, want 2", lctx.Remaining)
+	}
+}
+
+func TestSQLStoreConcurrentIncrements(t *testing.T) {
+	ctx := context.Background()
+	rate := limiter.Rate{Limit: 100, Period: time.Minute}
+	store := &SQLStore{db: testDB(t)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Get(ctx, "key", rate)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	lctx, err := store.Peek(ctx, "key", rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lctx.Remaining != 80 {
+		t.Errorf("%d remaining after 20 requests, want 80", lctx.Remaining)
+	}
+}
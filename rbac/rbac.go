@@ -0,0 +1,102 @@
+// Package rbac defines the permissions of the application. Roles are granted permissions and routes require a
+// permission instead of a role, so that roles can be changed without changing any code.
+package rbac
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// AdminRole is the built in role which is always granted every permission
+const AdminRole = "admin"
+
+// The permissions which routes can require
+const (
+	AdminDashboard = "admin.dashboard"
+	AdminConfig    = "admin.config"
+	AdminClients   = "admin.clients"
+	AdminLockouts  = "admin.lockouts"
+	AdminTwoFactor = "admin.2fa"
+	AdminUsers     = "admin.users"
+	AdminRoles     = "admin.roles"
+	// AdminImpersonate allows logging in as another user, only users with fewer permissions can be impersonated
+	AdminImpersonate = "admin.impersonate"
+	AdminAudit       = "admin.audit"
+	AdminInvites     = "admin.invites"
+)
+
+// Permission describes a permission which can be granted to roles
+type Permission struct {
+	Name        string
+	Description string
+}
+
+// Permissions are all the permissions of the application, they are created when the database is migrated
+var Permissions = []Permission{
+	{AdminDashboard, "View the admin dashboard"},
+	{AdminConfig, "Change the server configuration and logging level"},
+	{AdminClients, "Manage OpenID Connect clients"},
+	{AdminLockouts, "Remove login lockouts"},
+	{AdminTwoFactor, "Reset two-factor authentication of users"},
+	{AdminUsers, "Manage users, their roles and account state"},
+	{AdminRoles, "Create, change and delete roles and their members"},
+	{AdminImpersonate, "Log in as another user to see what they see"},
+	{AdminAudit, "View and export the audit log"},
+	{AdminInvites, "Invite users and revoke invitations"},
+}
+
+// Seed creates any missing permissions and grants all permissions to the admin role
+func Seed(db *gorm.DB) error {
+	var all []models.Permission
+	for _, p := range Permissions {
+		permission := models.Permission{Name: p.Name}
+		res := db.Where(&permission).Attrs(models.Permission{Description: p.Description}).FirstOrCreate(&permission)
+		if res.Error != nil {
+			return res.Error
+		}
+		all = append(all, permission)
+	}
+	role := models.Role{Name: AdminRole}
+	res := db.Where(&role).First(&role)
+	if res.Error != nil {
+		return res.Error
+	}
+	return db.Model(&role).Association("Permissions").Append(all)
+}
+
+// ForRoles returns the names of the permissions granted to any of the roles
+func ForRoles(db *gorm.DB, roles []models.Role) ([]string, error) {
+	var ids []uint
+	for _, r := range roles {
+		ids = append(ids, r.ID)
+	}
+	var names []string
+	if len(ids) == 0 {
+		return names, nil
+	}
+	res := db.Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Where("role_permissions.role_id IN ?", ids).
+		Order("permissions.name").
+		Pluck("permissions.name", &names)
+	return names, res.Error
+}
+
+// Join returns the permissions as the space separated string which is cached in sessions
+func Join(permissions []string) string {
+	return strings.Join(permissions, " ")
+}
+
+// Split returns the permissions of a string created by Join
+func Split(permissions string) []string {
+	return strings.Fields(permissions)
+}
+
+// Has returns true if the permission is in the list
+func Has(permissions []string, permission string) bool {
+	return slices.Contains(permissions, permission)
+}
@@ -0,0 +1,191 @@
+package rbac
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// UserRole is the built in role which new users are given when they register
+const UserRole = "user"
+
+var (
+	// ErrBuiltInRole is returned when a built in role would be renamed or deleted, or the permissions of the admin role changed
+	ErrBuiltInRole = errors.New("built in roles can not be renamed or deleted")
+	// ErrRoleName is returned when the name of a role is empty or already used by another role
+	ErrRoleName = errors.New("role name is empty or already in use")
+	// ErrLastAdmin is returned when a change would leave no active user who can manage roles
+	ErrLastAdmin = errors.New("change would remove the last admin")
+)
+
+// IsBuiltIn returns true for the roles which are created when the database is migrated and which the code depends on
+func IsBuiltIn(name string) bool {
+	return name == AdminRole || name == UserRole
+}
+
+// SaveRole creates the role if it has no id or updates it otherwise. The role is granted exactly the named permissions,
+// unknown names are ignored. The ids of the users whose permissions changed are returned so that their sessions can be
+// refreshed.
+func SaveRole(db *gorm.DB, role *models.Role, name string, description string, permissions []string) ([]uint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrRoleName
+	}
+	if role.ID != 0 && IsBuiltIn(role.Name) && name != role.Name {
+		return nil, ErrBuiltInRole
+	}
+
+	var members []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		res := tx.Model(&models.Role{}).Where("name = ? AND id <> ?", name, role.ID).Count(&count)
+		if res.Error != nil {
+			return res.Error
+		}
+		if count > 0 {
+			return ErrRoleName
+		}
+
+		role.Name = name
+		role.Description = strings.TrimSpace(description)
+		res = tx.Omit("Users", "Permissions").Save(role)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// The admin role always has every permission so its permissions are not changed
+		if role.Name != AdminRole {
+			var granted []models.Permission
+			if len(permissions) > 0 {
+				res = tx.Where("name IN ?", permissions).Find(&granted)
+				if res.Error != nil {
+					return res.Error
+				}
+			}
+			err := tx.Model(role).Association("Permissions").Replace(granted)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := EnsureAdmin(tx)
+		if err != nil {
+			return err
+		}
+		members, err = memberIDs(tx, role.ID)
+		return err
+	})
+	return members, err
+}
+
+// DeleteRole removes the role from all its members and deletes it. The ids of the former members are returned.
+func DeleteRole(db *gorm.DB, role models.Role) ([]uint, error) {
+	if IsBuiltIn(role.Name) {
+		return nil, ErrBuiltInRole
+	}
+	var members []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		members, err = memberIDs(tx, role.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&role).Association("Users").Clear()
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&role).Association("Permissions").Clear()
+		if err != nil {
+			return err
+		}
+		// The role is deleted permanently so that its name can be used again
+		res := tx.Unscoped().Delete(&role)
+		if res.Error != nil {
+			return res.Error
+		}
+		return EnsureAdmin(tx)
+	})
+	return members, err
+}
+
+// AddMembers gives the role to the users, users who already have the role are skipped. The ids of the users who were
+// given the role are returned.
+func AddMembers(db *gorm.DB, role models.Role, userIDs []uint) ([]uint, error) {
+	var added []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := memberIDs(tx, role.ID)
+		if err != nil {
+			return err
+		}
+		var users []models.User
+		if len(userIDs) > 0 {
+			res := tx.Where("id IN ? AND id NOT IN ?", userIDs, append(existing, 0)).Find(&users)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		err = tx.Model(&role).Association("Users").Append(users)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			added = append(added, u.ID)
+		}
+		return nil
+	})
+	return added, err
+}
+
+// RemoveMembers takes the role from the users. The ids of the users who had the role are returned.
+func RemoveMembers(db *gorm.DB, role models.Role, userIDs []uint) ([]uint, error) {
+	var removed []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(userIDs) == 0 {
+			return nil
+		}
+		res := tx.Table("user_roles").Where("role_id = ? AND user_id IN ?", role.ID, userIDs).Pluck("user_id", &removed)
+		if res.Error != nil {
+			return res.Error
+		}
+		res = tx.Exec("DELETE FROM user_roles WHERE role_id = ? AND user_id IN ?", role.ID, userIDs)
+		if res.Error != nil {
+			return res.Error
+		}
+		return EnsureAdmin(tx)
+	})
+	return removed, err
+}
+
+// EnsureAdmin returns ErrLastAdmin unless at least one user who is not disabled or deleted has a role which grants
+// AdminRoles. It is called inside transactions which change roles or users so that the change can be rolled back
+// before nobody is left who can manage roles.
+func EnsureAdmin(tx *gorm.DB) error {
+	var count int64
+	res := tx.Model(&models.User{}).
+		Where("disabled_at IS NULL").
+		Where("id IN (?)", tx.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+			Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+			Where("permissions.name = ?", AdminRoles)).
+		Count(&count)
+	if res.Error != nil {
+		return res.Error
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// memberIDs returns the ids of the users who have the role
+func memberIDs(db *gorm.DB, roleID uint) ([]uint, error) {
+	var ids []uint
+	res := db.Table("user_roles").Where("role_id = ?", roleID).Pluck("user_id", &ids)
+	return ids, res.Error
+}
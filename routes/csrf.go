@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFFailed renders the page shown when a form is sent without a valid anti-forgery token, usually because the page
+// was open for a long time or the form was sent from another website
+func (svc Service) CSRFFailed(c *gin.Context) {
+	pd := DefaultPageData(c, svc.env.GetBundle(), svc.env.GetConfig().CacheParameter)
+	pd.Title = pd.Trans("Form expired")
+	c.HTML(http.StatusForbidden, "csrf.gohtml", pd)
+}
@@ -2,12 +2,16 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/uberswe/golang-base-project/infra"
 	// "github.com/uberswe/golang-base-project/config"
 
 	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
 )
 
 // PageData holds the default data needed for HTML pages to render
@@ -15,9 +19,18 @@ type PageData struct {
 	Title           string
 	Messages        []Message
 	IsAuthenticated bool
-	IsAdminRole     bool
-	CacheParameter  string
-	Trans           func(s string) string
+	Permissions     []string
+	// Impersonating is true when an admin is viewing the website as another user
+	Impersonating  bool
+	CacheParameter string
+	// CSRFToken is sent back with every form so that the CSRF middleware can tell the form came from this website
+	CSRFToken string
+	// DisplayName, Theme and Location come from the preferences of the user, templates show times with
+	// {{ ($t.In $.Location).Format "2006-01-02 15:04" }}
+	DisplayName string
+	Theme       string
+	Location    *time.Location
+	Trans       func(s string) string
 }
 
 // Define an enum using iota
@@ -64,17 +77,22 @@ func isAuthenticated(c *gin.Context) bool {
 	return exists
 }
 
-func isAdminRole(c *gin.Context) bool {
-	role, ret := c.Get(middleware.UserRoleKey)
-	if !ret { // does not exist
-		return false
-	}
-	// ret is true
-	if role.(string) != "admin" {
-		ret = false
-	}
+// isImpersonating checks if an admin is impersonating the current user
+func isImpersonating(c *gin.Context) bool {
+	_, exists := c.Get(middleware.ImpersonatorIDKey)
+	return exists
+}
 
-	return ret
+// permissions returns the permissions of the current user
+func permissions(c *gin.Context) []string {
+	p, _ := c.Get(middleware.UserPermissionsKey)
+	list, _ := p.([]string)
+	return list
+}
+
+// Can returns true if the current user has the permission, templates use it as {{ if .Can "admin.config" }}
+func (pd PageData) Can(permission string) bool {
+	return rbac.Has(pd.Permissions, permission)
 }
 
 func getUserId(c *gin.Context) uint {
@@ -85,14 +103,34 @@ func getUserId(c *gin.Context) uint {
 	return 0
 }
 
+// preferences returns the preferences of the current user and the location of their time zone
+func preferences(c *gin.Context) (models.Preferences, *time.Location) {
+	p, _ := c.Get(middleware.PreferencesKey)
+	prefs, _ := p.(models.Preferences)
+	location := time.Local
+	if prefs.TimeZone != "" {
+		loc, err := time.LoadLocation(prefs.TimeZone)
+		if err == nil {
+			location = loc
+		}
+	}
+	return prefs, location
+}
+
 func DefaultPageData(c *gin.Context, bundle *i18n.Bundle, cacheParameter string) PageData {
 	langService := infra.NewLangService(c, bundle)
+	prefs, location := preferences(c)
 	return PageData{
 		Title:           "Home",
 		Messages:        nil,
 		IsAuthenticated: isAuthenticated(c),
-		IsAdminRole:     isAdminRole(c),
+		Permissions:     permissions(c),
+		Impersonating:   isImpersonating(c),
 		CacheParameter:  cacheParameter,
+		CSRFToken:       c.GetString(middleware.CSRFTokenKey),
+		DisplayName:     prefs.DisplayName,
+		Theme:           prefs.Theme,
+		Location:        location,
 		Trans:           langService.Trans,
 	}
 }
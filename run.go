@@ -7,14 +7,21 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	// Time zones are embedded so that users can choose one when the system has no time zone database
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"github.com/uberswe/golang-base-project/account"
 	"github.com/uberswe/golang-base-project/admin"
+	"github.com/uberswe/golang-base-project/deletion"
+	"github.com/uberswe/golang-base-project/idp"
 	"github.com/uberswe/golang-base-project/infra"
 	"github.com/uberswe/golang-base-project/login"
 	"github.com/uberswe/golang-base-project/middleware"
+	"github.com/uberswe/golang-base-project/ratelimit"
+	"github.com/uberswe/golang-base-project/rbac"
 	"github.com/uberswe/golang-base-project/routes"
 )
 
@@ -42,8 +49,16 @@ func Run() {
 		os.Exit(2)
 	}
 
+	// Rate limit counts are kept in memory, the database or Redis depending on the configuration
+	rateStore, err := ratelimit.NewStore(conf.RateLimitBackend, conf.RateLimitRedisURL, db)
+	if err != nil {
+		slog.Error("Run", "error", err)
+		os.Exit(8)
+	}
+	limiter := ratelimit.New(rateStore, conf.RateLimits)
+
 	// set global app-wide cfg parms
-	infra.InitLair(db, conf, bundle, logLevelVar)
+	infra.InitLair(db, conf, bundle, logLevelVar, limiter)
 
 	// Once a database connection is established we run any needed migrations
 	err = infra.MigrateDatabase(db)
@@ -51,6 +66,15 @@ func Run() {
 		slog.Error("Run", "error", err)
 		os.Exit(3)
 	}
+	// Rate limits changed on the configuration page are kept in the database and shared by every instance
+	err = limiter.Load(db)
+	if err != nil {
+		slog.Error("Run", "error", err)
+	}
+	limiter.Watch(db)
+	// Deleted users can be restored during a grace period, after it their sessions and tokens are purged in the background
+	deletion.Start(db, conf)
+
 	// t will hold all our html templates used to render pages
 	var t *template.Template
 
@@ -73,6 +97,9 @@ func Run() {
 	// We create a new cookie store with a key used to secure cookies with HMAC
 	store := cookie.NewStore([]byte(conf.CookieSecret))
 
+	// Every request gets an id which is returned in a header and stored with audit events
+	r.Use(middleware.RequestID())
+
 	// We define our session middleware to be used globally on all routes
 	r.Use(sessions.Sessions("golang_base_project_session", store))
 
@@ -100,15 +127,28 @@ func Run() {
 	assets.StaticFS("/", http.FS(subFS))
 
 	// Session middleware is applied to all groups after this point.
-	r.Use(middleware.Session(db))
+	r.Use(middleware.Session(db, conf))
+
+	// Personal access tokens authenticate requests from scripts in the same way as sessions
+	r.Use(middleware.Bearer(db))
+
+	// The preferences of the user choose the language, time zone and theme of the pages
+	r.Use(middleware.Preferences(db))
 
 	// A General middleware is defined to add default headers to improve site security
 	r.Use(middleware.General())
 
 	ctx := infra.LairInstance()
+	routeSvc := routes.NewService(ctx)
+
+	// State changing requests must send back the anti-forgery token of the session, the token endpoint authenticates
+	// OAuth clients with their secret and the userinfo endpoint uses an access token instead of cookies
+	r.Use(middleware.CSRF(routeSvc.CSRFFailed, "/oauth2/token", "/oauth2/userinfo"))
+
 	loginSvc := login.NewService(ctx)
 	adminSvc := admin.NewService(ctx)
-	routeSvc := routes.NewService(ctx)
+	accountSvc := account.NewService(ctx)
+	idpSvc := idp.NewService(ctx)
 
 	// Any request to / will call controller.Index
 	r.GET("/", routeSvc.Index)
@@ -122,6 +162,19 @@ func Run() {
 	// We define our 404 handler for when a page can not be found
 	r.NoRoute(routeSvc.NoRoute)
 
+	// OpenID Connect login is available to everyone, authenticated users link the provider to their account. An admin who
+	// is impersonating a user can not link an identity which they could later use to login as that user.
+	r.GET("/login/oidc/:provider", middleware.NotImpersonating(), loginSvc.OIDCStart)
+	r.GET("/login/oidc/:provider/callback", middleware.NotImpersonating(), loginSvc.OIDCCallback)
+
+	// The OpenID Connect provider endpoints used by other applications, the authorize endpoint asks users to login if needed
+	r.GET("/.well-known/openid-configuration", idpSvc.Discovery)
+	r.GET("/oauth2/jwks", idpSvc.JWKS)
+	r.GET("/oauth2/authorize", middleware.Sensitive(), middleware.SessionOnly(), middleware.NotImpersonating(), idpSvc.Authorize)
+	r.POST("/oauth2/token", idpSvc.Token)
+	r.GET("/oauth2/userinfo", idpSvc.UserInfo)
+	r.POST("/oauth2/userinfo", idpSvc.UserInfo)
+
 	// noAuth is a group for routes which should only be accessed if the user is not authenticated
 	noAuth := r.Group("/")
 	noAuth.Use(middleware.NoAuth())
@@ -130,39 +183,138 @@ func Run() {
 	// use different implementation for testing
 
 	noAuth.GET("/login", loginSvc.Login)
+	noAuth.GET("/login/2fa", loginSvc.TwoFactor)
+	noAuth.GET("/login/email", loginSvc.MagicLink)
+	noAuth.GET("/login/email/:token", loginSvc.MagicLinkConfirm)
 	noAuth.GET("/register", loginSvc.Register)
 	noAuth.GET("/activate/resend", loginSvc.ResendActivation)
 	noAuth.GET("/activate/:token", loginSvc.Activate)
 	noAuth.GET("/user/password/forgot", loginSvc.ForgotPassword)
 	noAuth.GET("/user/password/reset/:token", loginSvc.ResetPassword)
+	noAuth.GET("/unlock/:token", loginSvc.Unlock)
 
 	// We make a separate group for our post requests on the same endpoints so that we can define our throttling middleware on POST requests only.
 	noAuthPost := noAuth.Group("/")
-	noAuthPost.Use(middleware.Throttle(conf.RequestsPerMinute))
-
-	noAuthPost.POST("/loglevel", adminSvc.LoggingRouteHandlerPost)
-	noAuthPost.POST("/login", loginSvc.LoginPost)
-	noAuthPost.POST("/register", loginSvc.RegisterPost)
-	noAuthPost.POST("/activate/resend", loginSvc.ResendActivationPost)
-	noAuthPost.POST("/user/password/forgot", loginSvc.ForgotPasswordPost)
+	noAuthPost.Use(middleware.RateLimit(limiter, ratelimit.Forms))
+
+	// Forms which take an email are also limited by the email so that one account can not be targeted from many addresses
+	byEmail := middleware.RateLimit(limiter, ratelimit.Email)
+
+	noAuthPost.POST("/login", byEmail, loginSvc.LoginPost)
+	noAuthPost.POST("/login/2fa", loginSvc.TwoFactorPost)
+	noAuthPost.POST("/login/email", byEmail, loginSvc.MagicLinkPost)
+	noAuthPost.POST("/login/email/:token", loginSvc.MagicLinkConfirmPost)
+	noAuthPost.POST("/login/passkey/begin", loginSvc.PasskeyLoginBegin)
+	noAuthPost.POST("/login/passkey/finish", loginSvc.PasskeyLoginFinish)
+	noAuthPost.POST("/register", byEmail, loginSvc.RegisterPost)
+	noAuthPost.POST("/activate/resend", byEmail, loginSvc.ResendActivationPost)
+	noAuthPost.POST("/user/password/forgot", byEmail, loginSvc.ForgotPasswordPost)
 	noAuthPost.POST("/user/password/reset/:token", loginSvc.ResetPasswordPost)
+	noAuthPost.POST("/unlock/:token", loginSvc.UnlockPost)
+
+	// Email change links are opened from an email, the user may or may not be logged in on the device
+	r.GET("/account/email/confirm/:token", accountSvc.EmailConfirm)
+	r.POST("/account/email/confirm/:token", middleware.RateLimit(limiter, ratelimit.Forms), accountSvc.EmailConfirmPost)
+	r.GET("/account/email/revert/:token", accountSvc.EmailRevert)
+	r.POST("/account/email/revert/:token", middleware.RateLimit(limiter, ratelimit.Forms), accountSvc.EmailRevertPost)
+	r.GET("/account/restore/:token", accountSvc.Restore)
+	r.POST("/account/restore/:token", middleware.RateLimit(limiter, ratelimit.Forms), accountSvc.RestorePost)
 
-	// the adminGroup group handles routes that should only be accessible to authenticated Admin users
+	// the adminGroup group handles admin routes, each route declares the permission it requires
 	adminGroup := r.Group("/")
-	adminGroup.Use(middleware.Admin())
+	adminGroup.Use(middleware.Auth())
 	adminGroup.Use(middleware.Sensitive())
 
-	adminGroup.GET("/config", adminSvc.ConfigRouteHandler)
-	adminGroup.POST("/config", adminSvc.ConfigRouteHandlerPost)
-	adminGroup.GET("/admin", adminSvc.Admin)
+	adminGroup.GET("/config", middleware.RequirePermission(rbac.AdminConfig), adminSvc.ConfigRouteHandler)
+	adminGroup.POST("/config", middleware.RequirePermission(rbac.AdminConfig), adminSvc.ConfigRouteHandlerPost)
+	adminGroup.POST("/loglevel", middleware.RequirePermission(rbac.AdminConfig), adminSvc.LoggingRouteHandlerPost)
+	adminGroup.GET("/admin", middleware.RequirePermission(rbac.AdminDashboard), adminSvc.Admin)
 	// We need to handle post from the login redirect
-	adminGroup.POST("/admin", adminSvc.Admin)
+	adminGroup.POST("/admin", middleware.RequirePermission(rbac.AdminDashboard), adminSvc.Admin)
+	adminGroup.POST("/admin/2fa/reset", middleware.RequirePermission(rbac.AdminTwoFactor), adminSvc.TwoFactorResetPost)
+	adminGroup.POST("/admin/lockouts/:id/unlock", middleware.RequirePermission(rbac.AdminLockouts), adminSvc.LockoutUnlockPost)
+	adminGroup.GET("/admin/users", middleware.RequirePermission(rbac.AdminUsers), adminSvc.Users)
+	adminGroup.GET("/admin/users/:id", middleware.RequirePermission(rbac.AdminUsers), adminSvc.User)
+	adminGroup.POST("/admin/users/:id/roles", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserRolesPost)
+	adminGroup.POST("/admin/users/:id/activate", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserActivatePost)
+	adminGroup.POST("/admin/users/:id/reset", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserResetPasswordPost)
+	adminGroup.POST("/admin/users/:id/disable", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserDisablePost)
+	adminGroup.POST("/admin/users/:id/enable", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserEnablePost)
+	adminGroup.POST("/admin/users/:id/delete", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserDeletePost)
+	adminGroup.POST("/admin/users/:id/restore", middleware.RequirePermission(rbac.AdminUsers), adminSvc.UserRestorePost)
+	adminGroup.POST("/admin/users/:id/impersonate", middleware.SessionOnly(), middleware.RequirePermission(rbac.AdminImpersonate), adminSvc.ImpersonatePost)
+	adminGroup.GET("/admin/roles", middleware.RequirePermission(rbac.AdminRoles), adminSvc.Roles)
+	adminGroup.POST("/admin/roles", middleware.RequirePermission(rbac.AdminRoles), adminSvc.RoleCreatePost)
+	adminGroup.GET("/admin/roles/:id", middleware.RequirePermission(rbac.AdminRoles), adminSvc.Role)
+	adminGroup.POST("/admin/roles/:id", middleware.RequirePermission(rbac.AdminRoles), adminSvc.RoleUpdatePost)
+	adminGroup.POST("/admin/roles/:id/delete", middleware.RequirePermission(rbac.AdminRoles), adminSvc.RoleDeletePost)
+	adminGroup.POST("/admin/roles/:id/members/add", middleware.RequirePermission(rbac.AdminRoles), adminSvc.RoleMembersAddPost)
+	adminGroup.POST("/admin/roles/:id/members/remove", middleware.RequirePermission(rbac.AdminRoles), adminSvc.RoleMembersRemovePost)
+	adminGroup.GET("/admin/invites", middleware.RequirePermission(rbac.AdminInvites), adminSvc.Invites)
+	adminGroup.POST("/admin/invites", middleware.RequirePermission(rbac.AdminInvites), adminSvc.InvitesPost)
+	adminGroup.POST("/admin/invites/:id/revoke", middleware.RequirePermission(rbac.AdminInvites), adminSvc.InviteRevokePost)
+	adminGroup.GET("/admin/audit", middleware.RequirePermission(rbac.AdminAudit), adminSvc.Audit)
+	adminGroup.GET("/admin/audit/export", middleware.RequirePermission(rbac.AdminAudit), adminSvc.AuditExport)
+	adminGroup.GET("/admin/clients", middleware.RequirePermission(rbac.AdminClients), adminSvc.Clients)
+	adminGroup.POST("/admin/clients", middleware.RequirePermission(rbac.AdminClients), adminSvc.ClientCreatePost)
+	adminGroup.POST("/admin/clients/:id", middleware.RequirePermission(rbac.AdminClients), adminSvc.ClientUpdatePost)
+	adminGroup.POST("/admin/clients/:id/secret", middleware.RequirePermission(rbac.AdminClients), adminSvc.ClientSecretPost)
+	adminGroup.POST("/admin/clients/:id/delete", middleware.RequirePermission(rbac.AdminClients), adminSvc.ClientDeletePost)
+
+	// The role API is used with a personal access token which has the admin scope, it is limited per user
+	api := adminGroup.Group("/api")
+	api.Use(middleware.RateLimit(limiter, ratelimit.API))
+	api.GET("/roles", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRoles)
+	api.POST("/roles", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRoleCreate)
+	api.GET("/roles/:id", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRole)
+	api.PUT("/roles/:id", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRoleUpdate)
+	api.DELETE("/roles/:id", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRoleDelete)
+	api.POST("/roles/:id/members", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRoleMembersAdd)
+	api.DELETE("/roles/:id/members", middleware.RequirePermission(rbac.AdminRoles), adminSvc.APIRoleMembersRemove)
 
 	// this group is for the main application which does not require admin privs
 	authGroup := r.Group("/")
 	authGroup.Use(middleware.Auth())
 	authGroup.Use(middleware.Sensitive())
 	authGroup.GET("/logout", loginSvc.Logout)
+	authGroup.POST("/impersonate/stop", middleware.SessionOnly(), adminSvc.StopImpersonatingPost)
+
+	// Account settings can only be changed by a logged in user, not with a personal access token or by an admin who is
+	// impersonating the user
+	accountGroup := authGroup.Group("/")
+	accountGroup.Use(middleware.SessionOnly())
+	accountGroup.Use(middleware.NotImpersonating())
+	// Forms which check the password or create credentials are limited per user so that a stolen session can not be
+	// used to guess the password or to create tokens in bulk
+	byUser := middleware.RateLimit(limiter, ratelimit.Account)
+	accountGroup.POST("/oauth2/authorize", idpSvc.AuthorizePost)
+	accountGroup.GET("/account", accountSvc.Profile)
+	accountGroup.POST("/account", accountSvc.ProfilePost)
+	accountGroup.POST("/account/password", byUser, accountSvc.PasswordPost)
+	accountGroup.GET("/account/email", accountSvc.Email)
+	accountGroup.POST("/account/email", byUser, accountSvc.EmailPost)
+	accountGroup.GET("/account/2fa", accountSvc.TwoFactor)
+	accountGroup.POST("/account/2fa/enable", accountSvc.TwoFactorEnablePost)
+	accountGroup.POST("/account/2fa/disable", accountSvc.TwoFactorDisablePost)
+	accountGroup.POST("/account/2fa/recovery", accountSvc.RecoveryCodesPost)
+	accountGroup.GET("/account/confirm", accountSvc.Confirm)
+	accountGroup.POST("/account/confirm", byUser, accountSvc.ConfirmPost)
+	accountGroup.GET("/account/passkeys", accountSvc.Passkeys)
+	accountGroup.POST("/account/passkeys/register/begin", middleware.RequireReauthentication("/account/passkeys"), accountSvc.PasskeyRegisterBegin)
+	accountGroup.POST("/account/passkeys/register/finish", middleware.RequireReauthentication("/account/passkeys"), accountSvc.PasskeyRegisterFinish)
+	accountGroup.POST("/account/passkeys/:id/rename", accountSvc.PasskeyRenamePost)
+	accountGroup.POST("/account/passkeys/:id/delete", accountSvc.PasskeyDeletePost)
+	accountGroup.GET("/account/identities", accountSvc.Identities)
+	accountGroup.POST("/account/identities/:id/delete", middleware.RequireReauthentication("/account/identities"), accountSvc.IdentityDeletePost)
+	accountGroup.GET("/account/sessions", accountSvc.Sessions)
+	accountGroup.POST("/account/sessions/revoke-others", accountSvc.SessionRevokeOthersPost)
+	accountGroup.POST("/account/sessions/:id/revoke", accountSvc.SessionRevokePost)
+	accountGroup.GET("/account/tokens", accountSvc.Tokens)
+	accountGroup.POST("/account/tokens", byUser, accountSvc.TokenCreatePost)
+	accountGroup.POST("/account/tokens/:id/revoke", byUser, accountSvc.TokenRevokePost)
+	accountGroup.GET("/account/data", accountSvc.Data)
+	accountGroup.GET("/account/export", accountSvc.Export)
+	accountGroup.POST("/account/delete", byUser, accountSvc.DeletePost)
 
 	// This starts our webserver, our application will not stop running or go past this point unless
 	// an error occurs or the web server is stopped for some reason. It is designed to run forever.
@@ -0,0 +1,131 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// reuseGrace is how long a replaced remember me token is ignored instead of treated as stolen, so that requests the
+// browser sent at the same time as the one which replaced the token do not log the user out
+const reuseGrace = 10 * time.Second
+
+var (
+	// ErrInvalidToken is returned when a remember me token does not exist, has expired or is malformed
+	ErrInvalidToken = errors.New("invalid remember me token")
+	// ErrTokenReplaced is returned when a remember me token was replaced moments ago by a concurrent request
+	ErrTokenReplaced = errors.New("remember me token was just replaced")
+	// ErrTokenReused is returned when a replaced remember me token is used again, the family is revoked when this happens
+	ErrTokenReused = errors.New("remember me token was reused")
+)
+
+// Remember creates a remember me token for the user and returns the value for the cookie. An empty family starts a
+// new family.
+func Remember(db *gorm.DB, conf *infra.Config, userID uint, family string) (models.RememberToken, string, error) {
+	selector, err := randomString(16)
+	if err != nil {
+		return models.RememberToken{}, "", err
+	}
+	validator, err := randomString(32)
+	if err != nil {
+		return models.RememberToken{}, "", err
+	}
+	if family == "" {
+		family, err = randomString(16)
+		if err != nil {
+			return models.RememberToken{}, "", err
+		}
+	}
+	token := models.RememberToken{
+		UserID:        userID,
+		Family:        family,
+		Selector:      selector,
+		ValidatorHash: hashValidator(validator),
+		ExpiresAt:     time.Now().AddDate(0, 0, conf.RememberMeDays),
+	}
+	res := db.Save(&token)
+	if res.Error != nil {
+		return models.RememberToken{}, "", res.Error
+	}
+	// Replaced tokens are kept until they expire so that reuse can be detected
+	res = db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RememberToken{})
+	return token, selector + ":" + validator, res.Error
+}
+
+// UseRememberToken checks the value of a remember me cookie and replaces the token with a new one in the same family.
+// The new token and its cookie value are returned.
+func UseRememberToken(db *gorm.DB, conf *infra.Config, value string) (models.RememberToken, string, error) {
+	selector, validator, ok := strings.Cut(value, ":")
+	if !ok || selector == "" {
+		return models.RememberToken{}, "", ErrInvalidToken
+	}
+	token := models.RememberToken{Selector: selector}
+	res := db.Where(&token).First(&token)
+	if res.Error != nil {
+		return models.RememberToken{}, "", ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashValidator(validator)), []byte(token.ValidatorHash)) != 1 {
+		return models.RememberToken{}, "", ErrInvalidToken
+	}
+	if token.HasExpired() {
+		return models.RememberToken{}, "", ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.UsedAt != nil {
+		if now.Sub(*token.UsedAt) < reuseGrace {
+			return models.RememberToken{}, "", ErrTokenReplaced
+		}
+		err := Forget(db, token.Family)
+		if err != nil {
+			return models.RememberToken{}, "", err
+		}
+		return token, "", ErrTokenReused
+	}
+
+	// Only one request can mark the token as used, any other request sees it as replaced
+	res = db.Model(&models.RememberToken{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", now)
+	if res.Error != nil {
+		return models.RememberToken{}, "", res.Error
+	}
+	if res.RowsAffected != 1 {
+		return models.RememberToken{}, "", ErrTokenReplaced
+	}
+	return Remember(db, conf, token.UserID, token.Family)
+}
+
+// Forget deletes every remember me token in the family together with the sessions they created
+func Forget(db *gorm.DB, family string) error {
+	if family == "" {
+		return nil
+	}
+	res := db.Unscoped().Where(&models.RememberToken{Family: family}).Delete(&models.RememberToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+	return db.Where(&models.Session{RememberFamily: family}).Delete(&models.Session{}).Error
+}
+
+// hashValidator returns the hash of a validator which is stored instead of the validator
+func hashValidator(validator string) string {
+	h := sha256.Sum256([]byte(validator))
+	return hex.EncodeToString(h[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
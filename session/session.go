@@ -0,0 +1,167 @@
+// Package session manages the database sessions of users. A session is valid as long as its row exists, so sessions are
+// revoked by deleting the row and the roles cached in a session are refreshed whenever the roles of a user change.
+package session
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/uberswe/golang-base-project/infra"
+	"github.com/uberswe/golang-base-project/models"
+	"github.com/uberswe/golang-base-project/rbac"
+	"github.com/uberswe/golang-base-project/ulid"
+	"gorm.io/gorm"
+)
+
+// ErrDisabled is returned when a session is started for a user whose account has been disabled
+var ErrDisabled = errors.New("account is disabled")
+
+// impersonationLifetime is the longest an admin can impersonate a user before having to start again
+const impersonationLifetime = time.Hour
+
+// ReauthenticationWindow is how long a confirmed password or second factor code allows changes to the security of an account
+const ReauthenticationWindow = 10 * time.Minute
+
+// touchInterval limits how often the last seen time and expiry of a session are written to the database
+const touchInterval = time.Minute
+
+// RoleString returns the comma separated list of role names which is stored in a session
+func RoleString(roles []models.Role) string {
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// Start creates a new session for the user, who needs to be loaded with its Roles. The family of the remember me token
+// created for the same login is stored with the session so that both can be revoked together.
+func Start(db *gorm.DB, conf *infra.Config, user models.User, ip string, userAgent string, rememberFamily string) (models.Session, error) {
+	if user.IsDisabled() {
+		return models.Session{}, ErrDisabled
+	}
+	permissions, err := rbac.ForRoles(db, user.Roles)
+	if err != nil {
+		return models.Session{}, err
+	}
+	now := time.Now()
+	ses := models.Session{
+		Identifier:        ulid.Generate(),
+		UserID:            user.ID,
+		Role:              RoleString(user.Roles), // comma seperated list of roles
+		Permissions:       rbac.Join(permissions),
+		AbsoluteExpiresAt: now.Add(time.Duration(conf.SessionLifetime) * time.Hour),
+		RememberFamily:    rememberFamily,
+		IP:                ip,
+		UserAgent:         userAgent,
+		LastSeenAt:        now,
+	}
+	ses.ExpiresAt = idleExpiry(conf, ses, now)
+	res := db.Save(&ses)
+	return ses, res.Error
+}
+
+// Impersonate starts a session as the user for the admin whose own session is given. The admin's session is not changed
+// so that it can be used again when the impersonation ends.
+func Impersonate(db *gorm.DB, conf *infra.Config, user models.User, admin models.Session, ip string, userAgent string) (models.Session, error) {
+	ses, err := Start(db, conf, user, ip, userAgent, "")
+	if err != nil {
+		return ses, err
+	}
+	ses.ImpersonatorID = admin.UserID
+	ses.ImpersonatorSession = admin.Identifier
+	if limit := time.Now().Add(impersonationLifetime); ses.AbsoluteExpiresAt.After(limit) {
+		ses.AbsoluteExpiresAt = limit
+	}
+	ses.ExpiresAt = idleExpiry(conf, ses, time.Now())
+	res := db.Model(&ses).Updates(models.Session{
+		ImpersonatorID:      ses.ImpersonatorID,
+		ImpersonatorSession: ses.ImpersonatorSession,
+		AbsoluteExpiresAt:   ses.AbsoluteExpiresAt,
+		ExpiresAt:           ses.ExpiresAt,
+	})
+	return ses, res.Error
+}
+
+// idleExpiry returns when the session expires if it is not used again, which is never after the absolute expiry
+func idleExpiry(conf *infra.Config, ses models.Session, now time.Time) time.Time {
+	expires := now.Add(time.Duration(conf.SessionIdleTimeout) * time.Minute)
+	if expires.After(ses.AbsoluteExpiresAt) {
+		return ses.AbsoluteExpiresAt
+	}
+	return expires
+}
+
+// Touch records that the session has been used and moves the idle expiry forward
+func Touch(db *gorm.DB, conf *infra.Config, ses models.Session) error {
+	now := time.Now()
+	if now.Sub(ses.LastSeenAt) < touchInterval {
+		return nil
+	}
+	return db.Model(&ses).UpdateColumns(map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   idleExpiry(conf, ses, now),
+	}).Error
+}
+
+// Reauthenticate records that the user of the session has just confirmed their password or a second factor code
+func Reauthenticate(db *gorm.DB, identifier string) error {
+	return db.Model(&models.Session{}).Where(&models.Session{Identifier: identifier}).UpdateColumn("reauthenticated_at", time.Now()).Error
+}
+
+// Revoke deletes the session with the identifier together with the remember me tokens of the same login
+func Revoke(db *gorm.DB, identifier string) error {
+	if identifier == "" {
+		return nil
+	}
+	ses := models.Session{Identifier: identifier}
+	res := db.Where(&ses).Find(&ses)
+	if res.Error != nil {
+		return res.Error
+	}
+	err := Forget(db, ses.RememberFamily)
+	if err != nil {
+		return err
+	}
+	return db.Where(&models.Session{Identifier: identifier}).Delete(&models.Session{}).Error
+}
+
+// RevokeAll deletes every session and remember me token of the user except the session with the identifier given as
+// except and its remember me tokens. except can be empty to revoke everything. The number of revoked sessions is returned.
+func RevokeAll(db *gorm.DB, userID uint, except string) (int64, error) {
+	keep := ""
+	if except != "" {
+		ses := models.Session{Identifier: except}
+		res := db.Where(&ses).Find(&ses)
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		keep = ses.RememberFamily
+	}
+	res := db.Unscoped().Where("user_id = ? AND family <> ?", userID, keep).Delete(&models.RememberToken{})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	res = db.Where("user_id = ? AND identifier <> ?", userID, except).Delete(&models.Session{})
+	return res.RowsAffected, res.Error
+}
+
+// RefreshRoles updates the roles and permissions stored in the sessions of the user, it should be called after the
+// roles of a user have been changed so that the change applies to users who are already logged in
+func RefreshRoles(db *gorm.DB, userID uint) error {
+	user := models.User{}
+	user.ID = userID
+	res := db.Preload("Roles").Where(&user).First(&user)
+	if res.Error != nil {
+		return res.Error
+	}
+	permissions, err := rbac.ForRoles(db, user.Roles)
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.Session{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+		"role":        RoleString(user.Roles),
+		"permissions": rbac.Join(permissions),
+	}).Error
+}
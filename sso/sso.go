@@ -0,0 +1,201 @@
+// Package sso implements login with external OpenID Connect providers using discovery, PKCE, state and nonce checks
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-contrib/sessions"
+	"github.com/uberswe/golang-base-project/infra"
+	"golang.org/x/oauth2"
+)
+
+// pendingKey holds the state of a login which has been redirected to a provider
+const pendingKey = "OIDCPending"
+
+// pendingLifetime is how long a user has to complete the login at the provider
+const pendingLifetime = 10 * time.Minute
+
+// discovered caches providers by issuer so that discovery documents are only fetched once
+var discovered sync.Map
+
+// Claims holds the claims of a verified ID token which are used to find or create a user
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Pending holds the values generated when a login is started which are checked when the provider redirects back
+type Pending struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string
+	Link     bool
+	Expires  time.Time
+}
+
+// Find returns the configured provider with the given key
+func Find(conf *infra.Config, key string) (infra.OIDCProvider, bool) {
+	for _, p := range conf.OIDCProviders {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return infra.OIDCProvider{}, false
+}
+
+// discover fetches and caches the discovery document of the issuer
+func discover(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	if p, ok := discovered.Load(issuer); ok {
+		return p.(*oidc.Provider), nil
+	}
+	p, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	discovered.Store(issuer, p)
+	return p, nil
+}
+
+// oauth2Config returns the client configuration for a provider, the redirect url is derived from BaseURL
+func oauth2Config(conf *infra.Config, p infra.OIDCProvider, provider *oidc.Provider) (*oauth2.Config, error) {
+	u, err := url.Parse(conf.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "/login/oidc/", p.Key, "callback")
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  u.String(),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Start generates state, nonce and a PKCE verifier, stores them in the session and returns the url of the provider to redirect to.
+// When link is true the identity is linked to the currently authenticated user once the provider redirects back.
+func Start(ctx context.Context, conf *infra.Config, session sessions.Session, p infra.OIDCProvider, link bool) (string, error) {
+	provider, err := discover(ctx, p.Issuer)
+	if err != nil {
+		return "", err
+	}
+	oc, err := oauth2Config(conf, p, provider)
+	if err != nil {
+		return "", err
+	}
+
+	pending := Pending{
+		Provider: p.Key,
+		Verifier: oauth2.GenerateVerifier(),
+		Link:     link,
+		Expires:  time.Now().Add(pendingLifetime),
+	}
+	pending.State, err = randomString()
+	if err != nil {
+		return "", err
+	}
+	pending.Nonce, err = randomString()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(pending)
+	if err != nil {
+		return "", err
+	}
+	session.Set(pendingKey, string(b))
+	err = session.Save()
+	if err != nil {
+		return "", err
+	}
+
+	return oc.AuthCodeURL(pending.State, oidc.Nonce(pending.Nonce), oauth2.S256ChallengeOption(pending.Verifier)), nil
+}
+
+// Finish validates the callback against the pending login stored in the session, exchanges the code and verifies the ID token.
+// The pending login is removed from the session so that a callback can only be used once.
+func Finish(ctx context.Context, conf *infra.Config, session sessions.Session, p infra.OIDCProvider, query url.Values) (Claims, Pending, error) {
+	claims := Claims{}
+	pending := Pending{}
+
+	s, ok := session.Get(pendingKey).(string)
+	if !ok {
+		return claims, pending, errors.New("no login in progress")
+	}
+	session.Delete(pendingKey)
+	err := session.Save()
+	if err != nil {
+		return claims, pending, err
+	}
+	err = json.Unmarshal([]byte(s), &pending)
+	if err != nil {
+		return claims, pending, err
+	}
+
+	if pending.Provider != p.Key || time.Now().After(pending.Expires) {
+		return claims, pending, errors.New("login has expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(pending.State), []byte(query.Get("state"))) != 1 {
+		return claims, pending, errors.New("state does not match")
+	}
+	if e := query.Get("error"); e != "" {
+		return claims, pending, fmt.Errorf("provider returned an error: %s %s", e, query.Get("error_description"))
+	}
+
+	provider, err := discover(ctx, p.Issuer)
+	if err != nil {
+		return claims, pending, err
+	}
+	oc, err := oauth2Config(conf, p, provider)
+	if err != nil {
+		return claims, pending, err
+	}
+
+	token, err := oc.Exchange(ctx, query.Get("code"), oauth2.VerifierOption(pending.Verifier))
+	if err != nil {
+		return claims, pending, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return claims, pending, errors.New("no id_token in token response")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return claims, pending, err
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(pending.Nonce)) != 1 {
+		return claims, pending, errors.New("nonce does not match")
+	}
+
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return claims, pending, err
+	}
+	if claims.Subject == "" {
+		return claims, pending, errors.New("id token has no subject")
+	}
+	return claims, pending, nil
+}
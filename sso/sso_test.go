@@ -0,0 +1,192 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/uberswe/golang-base-project/infra"
+)
+
+// memorySession keeps the session values in a map, the methods which are not used by this package are left unimplemented
+type memorySession struct {
+	sessions.Session
+	values map[interface{}]interface{}
+}
+
+func (s *memorySession) Get(key interface{}) interface{}      { return s.values[key] }
+func (s *memorySession) Set(key interface{}, val interface{}) { s.values[key] = val }
+func (s *memorySession) Delete(key interface{})               { delete(s.values, key) }
+func (s *memorySession) Save() error                          { return nil }
+
+// testProvider is an OpenID Connect provider which issues an ID token for any code and remembers the PKCE verifier
+type testProvider struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	nonce    string
+	verifier string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &p.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.verifier = r.PostFormValue("code_verifier")
+		idToken, err := p.sign(map[string]interface{}{
+			"iss":   p.URL,
+			"sub":   "subject",
+			"aud":   "client",
+			"email": "user@example.com",
+			"nonce": p.nonce,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "token_type": "Bearer", "id_token": idToken})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testProvider) sign(claims map[string]interface{}) (string, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: p.key, KeyID: "test"}}, nil)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return jws.CompactSerialize()
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   bool
+		key     string
+		expired bool
+		query   func(url.Values)
+		nonce   func(string) string
+		wantErr bool
+	}{
+		{name: "valid", start: true, key: "test"},
+		{name: "no login in progress", key: "test", wantErr: true},
+		{name: "other provider", start: true, key: "other", wantErr: true},
+		{name: "expired", start: true, key: "test", expired: true, wantErr: true},
+		{name: "state does not match", start: true, key: "test", query: func(q url.Values) { q.Set("state", "forged") }, wantErr: true},
+		{name: "provider error", start: true, key: "test", query: func(q url.Values) { q.Set("error", "access_denied") }, wantErr: true},
+		{name: "nonce does not match", start: true, key: "test", nonce: func(n string) string { return n + "x" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			provider := newTestProvider(t)
+			p := infra.OIDCProvider{Key: "test", Issuer: provider.URL, ClientID: "client", ClientSecret: "secret"}
+			other := p
+			other.Key = "other"
+			conf := &infra.Config{BaseURL: "https://example.com", OIDCProviders: []infra.OIDCProvider{p, other}}
+			session := &memorySession{values: map[interface{}]interface{}{}}
+
+			query := url.Values{"code": {"code"}}
+			var challenge string
+			if tt.start {
+				redirect, err := Start(ctx, conf, session, p, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				u, err := url.Parse(redirect)
+				if err != nil {
+					t.Fatal(err)
+				}
+				query.Set("state", u.Query().Get("state"))
+				challenge = u.Query().Get("code_challenge")
+				provider.nonce = u.Query().Get("nonce")
+				if tt.nonce != nil {
+					provider.nonce = tt.nonce(provider.nonce)
+				}
+			}
+			if tt.expired {
+				pending := Pending{}
+				json.Unmarshal([]byte(session.values[pendingKey].(string)), &pending)
+				pending.Expires = time.Now().Add(-time.Minute)
+				b, _ := json.Marshal(pending)
+				session.values[pendingKey] = string(b)
+			}
+			if tt.query != nil {
+				tt.query(query)
+			}
+
+			finishWith, _ := Find(conf, tt.key)
+			claims, _, err := Finish(ctx, conf, session, finishWith, query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Finish error = %v, want an error %t", err, tt.wantErr)
+			}
+			if _, ok := session.values[pendingKey]; ok {
+				t.Error("the pending login was not removed from the session")
+			}
+			if tt.wantErr {
+				return
+			}
+			if claims.Subject != "subject" || claims.Email != "user@example.com" {
+				t.Errorf("claims = %+v", claims)
+			}
+			h := sha256.Sum256([]byte(provider.verifier))
+			if base64.RawURLEncoding.EncodeToString(h[:]) != challenge {
+				t.Error("the code verifier sent to the token endpoint does not match the code challenge")
+			}
+
+			// The callback can only be used once
+			_, _, err = Finish(ctx, conf, session, p, query)
+			if err == nil {
+				t.Error("a second Finish with the same callback succeeded")
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	conf := &infra.Config{OIDCProviders: []infra.OIDCProvider{{Key: "google"}, {Key: "github"}}}
+	if p, ok := Find(conf, "github"); !ok || p.Key != "github" {
+		t.Errorf("Find(github) = %+v, %t", p, ok)
+	}
+	if _, ok := Find(conf, "gitlab"); ok {
+		t.Error("Find(gitlab) found a provider which is not configured")
+	}
+}
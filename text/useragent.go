@@ -0,0 +1,64 @@
+package text
+
+import "strings"
+
+// UserAgent is the browser and operating system parsed from a User-Agent header
+type UserAgent struct {
+	Browser string
+	OS      string
+}
+
+// String returns a short description such as "Firefox on Windows", or an empty string if nothing is known
+func (ua UserAgent) String() string {
+	switch {
+	case ua.Browser != "" && ua.OS != "":
+		return ua.Browser + " on " + ua.OS
+	case ua.Browser != "":
+		return ua.Browser
+	case ua.OS != "":
+		return ua.OS
+	}
+	return ""
+}
+
+// browserTokens and osTokens are checked in order as most browsers include the tokens of the browsers they are based on
+var (
+	browserTokens = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	osTokens = []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// ParseUserAgent returns the browser and operating system of a User-Agent header, unknown values are left empty
+func ParseUserAgent(header string) UserAgent {
+	ua := UserAgent{}
+	for _, b := range browserTokens {
+		if strings.Contains(header, b.token) {
+			ua.Browser = b.name
+			break
+		}
+	}
+	for _, o := range osTokens {
+		if strings.Contains(header, o.token) {
+			ua.OS = o.name
+			break
+		}
+	}
+	return ua
+}
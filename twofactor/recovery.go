@@ -0,0 +1,105 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeCount is the number of recovery codes generated for a user at a time
+const RecoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes returns RecoveryCodeCount new random codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes() ([]string, error) {
+	var codes []string
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, fmt.Sprintf("%s-%s", s[:5], s[5:]))
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode normalizes and hashes a recovery code so that it can be stored and looked up.
+// Recovery codes have enough entropy that a fast hash is sufficient.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReplaceRecoveryCodes deletes any existing recovery codes for the user and stores new ones, the plain text codes are returned so they can be shown once
+func ReplaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Unscoped().Where(&models.RecoveryCode{UserID: userID}).Delete(&models.RecoveryCode{})
+		if res.Error != nil {
+			return res.Error
+		}
+		for _, code := range codes {
+			res = tx.Save(&models.RecoveryCode{
+				UserID: userID,
+				Hash:   HashRecoveryCode(code),
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or an unused recovery code for the user. On success the
+// used time step or recovery code is persisted so that the same code can not be used again.
+func Verify(db *gorm.DB, user *models.User, code string) bool {
+	if step, ok := ValidateCode(user.TOTPSecret, code, user.TOTPLastStep); ok {
+		// The step is only stored if no concurrent request has already used it
+		res := db.Model(user).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+		return res.Error == nil && res.RowsAffected == 1
+	}
+
+	recoveryCode := models.RecoveryCode{}
+	res := db.Where("user_id = ? AND hash = ? AND used_at IS NULL", user.ID, HashRecoveryCode(code)).First(&recoveryCode)
+	if res.Error != nil {
+		return false
+	}
+	now := time.Now()
+	// Only mark the code as used if it has not been used by a concurrent request
+	res = db.Model(&recoveryCode).Where("used_at IS NULL").Update("used_at", &now)
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// Reset disables two-factor authentication for the user and removes all recovery codes
+func Reset(db *gorm.DB, user *models.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		return tx.Unscoped().Where(&models.RecoveryCode{UserID: user.ID}).Delete(&models.RecoveryCode{}).Error
+	})
+}
@@ -0,0 +1,92 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uberswe/golang-base-project/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB returns an in-memory database with the user and recovery code tables, it uses one connection as every
+// connection to an in-memory database has its own data
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	err = db.AutoMigrate(&models.User{}, &models.RecoveryCode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestVerifyRejectsReplayedCodes(t *testing.T) {
+	db := testDB(t)
+	key, err := GenerateKey("example.com", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "user@example.com", TOTPSecret: key.Secret()}
+	err = db.Create(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	awayFromStepEnd()
+	code := codeAt(t, user.TOTPSecret, time.Now())
+
+	// A copy of the user loaded before the code was used, as a concurrent request would have
+	stale := user
+	if !Verify(db, &user, code) {
+		t.Fatal("a valid code was rejected")
+	}
+	if Verify(db, &stale, code) {
+		t.Error("a code was accepted twice by concurrent requests")
+	}
+	db.First(&user, user.ID)
+	if Verify(db, &user, code) {
+		t.Error("a code was accepted twice")
+	}
+}
+
+func TestVerifyUsesRecoveryCodesOnce(t *testing.T) {
+	db := testDB(t)
+	user := models.User{Email: "user@example.com"}
+	err := db.Create(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := ReplaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Verify(db, &user, codes[0]) {
+		t.Fatal("a recovery code was rejected")
+	}
+	if Verify(db, &user, codes[0]) {
+		t.Error("a recovery code was accepted twice")
+	}
+	if !Verify(db, &user, codes[1]) {
+		t.Error("another recovery code was rejected")
+	}
+
+	// Replacing the codes invalidates the old ones
+	_, err = ReplaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Verify(db, &user, codes[2]) {
+		t.Error("a replaced recovery code was accepted")
+	}
+}
@@ -0,0 +1,70 @@
+// Package twofactor handles TOTP (RFC 6238) second factor authentication and one-time recovery codes
+package twofactor
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// period is the number of seconds each TOTP code is valid for
+const period = 30
+
+// GenerateKey creates a new random TOTP secret for the provided account
+func GenerateKey(issuer string, account string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+		Period:      period,
+	})
+}
+
+// QRCode renders the otpauth URI of the key as a PNG image and returns it as a data URI which can be used in an img tag
+func QRCode(key *otp.Key) (template.URL, error) {
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = png.Encode(&b, img)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes())), nil
+}
+
+// ValidateCode checks the code against the secret allowing one step of clock drift in either direction.
+// The matching time step is returned so that it can be stored, steps at or before lastStep are rejected to prevent a code from being used twice.
+func ValidateCode(secret string, code string, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return 0, false
+	}
+	now := time.Now()
+	for skew := -1; skew <= 1; skew++ {
+		t := now.Add(time.Duration(skew*period) * time.Second)
+		step := t.Unix() / period
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, t, totp.ValidateOpts{
+			Period:    period,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
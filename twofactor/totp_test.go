@@ -0,0 +1,67 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// codeAt returns the code of the secret at the time
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{Period: period, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// awayFromStepEnd waits until the current time step has a few seconds left, so that a step does not end while a test
+// expects the code of a neighbouring step to be accepted
+func awayFromStepEnd() {
+	if left := period - time.Now().Unix()%period; left < 3 {
+		time.Sleep(time.Duration(left+1) * time.Second)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	key, err := GenerateKey("example.com", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := key.Secret()
+	awayFromStepEnd()
+	now := time.Now()
+	step := now.Unix() / period
+	drift := period * time.Second
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     bool
+		wantStep int64
+	}{
+		{"current", codeAt(t, secret, now), 0, true, step},
+		{"with spaces", " " + codeAt(t, secret, now) + " ", 0, true, step},
+		{"previous step", codeAt(t, secret, now.Add(-drift)), 0, true, step - 1},
+		{"next step", codeAt(t, secret, now.Add(drift)), 0, true, step + 1},
+		{"too old", codeAt(t, secret, now.Add(-3*drift)), 0, false, 0},
+		{"too new", codeAt(t, secret, now.Add(3*drift)), 0, false, 0},
+		{"empty", "", 0, false, 0},
+		{"wrong", "000000", 0, false, 0},
+		{"replayed", codeAt(t, secret, now), step, false, 0},
+		{"older than last used", codeAt(t, secret, now.Add(-drift)), step, false, 0},
+		{"newer than last used", codeAt(t, secret, now.Add(drift)), step, true, step + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateCode(secret, tt.code, tt.lastStep)
+			if ok != tt.want || got != tt.wantStep {
+				t.Errorf("ValidateCode = %d, %t, want %d, %t", got, ok, tt.wantStep, tt.want)
+			}
+		})
+	}
+}